@@ -0,0 +1,78 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+
+	"templater/internal/executor"
+	"templater/internal/template"
+)
+
+const (
+	exitFailure     = 1
+	exitConflict    = 2
+	exitTimeout     = 124
+	exitInterrupted = 130
+)
+
+func exitCode(err error) int {
+	var conflict *template.PatchConflictError
+	var overlap *template.OverlapError
+	switch {
+	case errors.Is(err, context.Canceled):
+		return exitInterrupted
+	case errors.Is(err, executor.ErrTimeout):
+		return exitTimeout
+	case errors.As(err, &conflict), errors.As(err, &overlap):
+		return exitConflict
+	}
+	return exitFailure
+}
+
+func printError(w io.Writer, err error) {
+	var rollbackErr *template.RollbackError
+	if errors.Is(err, context.Canceled) && !errors.As(err, &rollbackErr) {
+		fmt.Fprintln(w, "Error: interrupted, rolled back all features applied in this run")
+		return
+	}
+
+	fmt.Fprintf(w, "Error: %v\n", err)
+
+	var conflict *template.PatchConflictError
+	if errors.As(err, &conflict) && conflict.Hunk != nil {
+		printConflict(w, conflict)
+	}
+
+	var timeout *template.TimeoutError
+	if errors.As(err, &timeout) {
+		fmt.Fprintf(w, "\nThe command did not finish within %s:\n    %s\n", timeout.Timeout, timeout.Command)
+		fmt.Fprintln(w, "Raise the limit with --timeout, TEMPLATER_TIMEOUT, or `timeout:` in the feature's feature.yml.")
+	}
+}
+
+func printConflict(w io.Writer, conflict *template.PatchConflictError) {
+	location := conflict.File
+	if conflict.Line > 0 {
+		location = fmt.Sprintf("%s:%d", conflict.File, conflict.Line)
+	}
+
+	fmt.Fprintf(w, "\nRejected hunk for %s (from %s):\n", location, conflict.PatchPath)
+	for _, line := range strings.Split(strings.TrimSuffix(conflict.Hunk.String(), "\n"), "\n") {
+		fmt.Fprintf(w, "    %s\n", line)
+	}
+
+	if len(conflict.Found) == 0 {
+		if conflict.Reason != "" {
+			fmt.Fprintf(w, "\nTarget: %s\n", conflict.Reason)
+		}
+		return
+	}
+
+	fmt.Fprintln(w, "\nFound in target:")
+	for i, line := range conflict.Found {
+		fmt.Fprintf(w, "    %4d | %s\n", conflict.Line+i, line)
+	}
+}
@@ -0,0 +1,68 @@
+package config
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"templater/internal/fs"
+
+	"gopkg.in/yaml.v3"
+)
+
+const (
+	PathEnv    = "TEMPLATER_CONFIG"
+	TimeoutEnv = "TEMPLATER_TIMEOUT"
+)
+
+type Config struct {
+	Timeout string `yaml:"timeout"`
+}
+
+type Setting struct {
+	Value  string
+	Source string
+}
+
+func Path() string {
+	if path := os.Getenv(PathEnv); path != "" {
+		return path
+	}
+	dir, err := os.UserConfigDir()
+	if err != nil {
+		return ""
+	}
+	return filepath.Join(dir, "templater", "config.yml")
+}
+
+func Load(fileSystem fs.FileSystem, path string) (*Config, error) {
+	if path == "" {
+		return &Config{}, nil
+	}
+
+	data, err := fileSystem.ReadFile(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return &Config{}, nil
+		}
+		return nil, err
+	}
+
+	var cfg Config
+	if err := yaml.Unmarshal(data, &cfg); err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	return &cfg, nil
+}
+
+func (c *Config) ResolveTimeout(flag, env, path string) Setting {
+	switch {
+	case flag != "":
+		return Setting{Value: flag, Source: "--timeout"}
+	case env != "":
+		return Setting{Value: env, Source: TimeoutEnv}
+	case c.Timeout != "":
+		return Setting{Value: c.Timeout, Source: path}
+	}
+	return Setting{}
+}
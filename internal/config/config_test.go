@@ -0,0 +1,44 @@
+package config
+
+import (
+	"testing"
+
+	"templater/internal/testutil/fs"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLoad_MissingFile(t *testing.T) {
+	memfs := fs.NewMemoryFS()
+
+	cfg, err := Load(memfs, "home/.config/templater/config.yml")
+	require.NoError(t, err)
+	assert.Equal(t, &Config{}, cfg)
+}
+
+func TestLoad_ReadsTimeout(t *testing.T) {
+	memfs := fs.NewMemoryFS()
+	memfs.AddFile("config.yml", []byte("timeout: 2m\n"))
+
+	cfg, err := Load(memfs, "config.yml")
+	require.NoError(t, err)
+	assert.Equal(t, "2m", cfg.Timeout)
+}
+
+func TestLoad_InvalidYaml(t *testing.T) {
+	memfs := fs.NewMemoryFS()
+	memfs.AddFile("config.yml", []byte("timeout: [\n"))
+
+	_, err := Load(memfs, "config.yml")
+	assert.ErrorContains(t, err, "config.yml:")
+}
+
+func TestResolveTimeout_Precedence(t *testing.T) {
+	cfg := &Config{Timeout: "1m"}
+
+	assert.Equal(t, Setting{Value: "5s", Source: "--timeout"}, cfg.ResolveTimeout("5s", "10s", "config.yml"))
+	assert.Equal(t, Setting{Value: "10s", Source: TimeoutEnv}, cfg.ResolveTimeout("", "10s", "config.yml"))
+	assert.Equal(t, Setting{Value: "1m", Source: "config.yml"}, cfg.ResolveTimeout("", "", "config.yml"))
+	assert.Equal(t, Setting{}, (&Config{}).ResolveTimeout("", "", "config.yml"))
+}
@@ -4,6 +4,8 @@ import (
 	"bytes"
 	"context"
 	"errors"
+	"fmt"
+	"io"
 	"os"
 	"os/exec"
 	"strings"
@@ -12,9 +14,19 @@ import (
 
 var ErrTimeout = errors.New("command timed out")
 
+type Stream int
+
+const (
+	Stdout Stream = iota
+	Stderr
+)
+
+type OutputFunc func(stream Stream, line string)
+
 type Executor interface {
-	Execute(command string, timeout string, env map[string]string) (stdout, stderr string, exitCode int, err error)
-	ExecuteWithStdin(command string, timeout string, env map[string]string, stdin string) (stdout, stderr string, exitCode int, err error)
+	Execute(ctx context.Context, command string, timeout string, env map[string]string) (stdout, stderr string, exitCode int, err error)
+	ExecuteWithStdin(ctx context.Context, command string, timeout string, env map[string]string, stdin string) (stdout, stderr string, exitCode int, err error)
+	ExecuteStreaming(ctx context.Context, command string, timeout string, env map[string]string, output OutputFunc) (stdout, stderr string, exitCode int, err error)
 }
 
 type ShellExecutor struct{}
@@ -23,29 +35,55 @@ func NewShellExecutor() *ShellExecutor {
 	return &ShellExecutor{}
 }
 
-func (e *ShellExecutor) Execute(command string, timeout string, env map[string]string) (string, string, int, error) {
-	return e.ExecuteWithStdin(command, timeout, env, "")
+func (e *ShellExecutor) Execute(ctx context.Context, command string, timeout string, env map[string]string) (string, string, int, error) {
+	return e.ExecuteWithStdin(ctx, command, timeout, env, "")
 }
 
-func (e *ShellExecutor) ExecuteWithStdin(command string, timeout string, env map[string]string, stdin string) (string, string, int, error) {
-	duration := parseDuration(timeout)
-	ctx, cancel := context.WithTimeout(context.Background(), duration)
+func (e *ShellExecutor) ExecuteWithStdin(ctx context.Context, command string, timeout string, env map[string]string, stdin string) (string, string, int, error) {
+	return run(ctx, timeout, stdin, nil, func(ctx context.Context) *exec.Cmd {
+		return buildCommand(ctx, command, env)
+	})
+}
+
+func (e *ShellExecutor) ExecuteStreaming(ctx context.Context, command string, timeout string, env map[string]string, output OutputFunc) (string, string, int, error) {
+	return run(ctx, timeout, "", output, func(ctx context.Context) *exec.Cmd {
+		return buildCommand(ctx, command, env)
+	})
+}
+
+func run(parent context.Context, timeout string, stdin string, output OutputFunc, build func(ctx context.Context) *exec.Cmd) (string, string, int, error) {
+	duration, err := ParseTimeout(timeout)
+	if err != nil {
+		return "", "", -1, err
+	}
+	ctx, cancel := context.WithTimeout(parent, duration)
 	defer cancel()
-	cmd := buildCommand(ctx, command, env)
+	cmd := build(ctx)
 	var stdout, stderr bytes.Buffer
 	cmd.Stdin = strings.NewReader(stdin)
 	cmd.Stdout = &stdout
 	cmd.Stderr = &stderr
-	err := cmd.Run()
+	if output != nil {
+		lines := newLineSplitter(output)
+		defer lines.flush()
+		cmd.Stdout = io.MultiWriter(&stdout, lines.writer(Stdout))
+		cmd.Stderr = io.MultiWriter(&stderr, lines.writer(Stderr))
+	}
+	err = cmd.Run()
 	return buildResult(ctx, err, stdout.String(), stderr.String())
 }
 
-func parseDuration(timeout string) time.Duration {
+// ParseTimeout parses a command timeout, rejecting anything that is not a
+// positive duration.
+func ParseTimeout(timeout string) (time.Duration, error) {
 	duration, err := time.ParseDuration(timeout)
 	if err != nil {
-		return time.Hour
+		return 0, fmt.Errorf("invalid timeout %q: expected a duration such as 30s or 2m", timeout)
+	}
+	if duration <= 0 {
+		return 0, fmt.Errorf("invalid timeout %q: must be greater than zero", timeout)
 	}
-	return duration
+	return duration, nil
 }
 
 func buildCommand(ctx context.Context, command string, env map[string]string) *exec.Cmd {
@@ -64,6 +102,9 @@ func buildResult(ctx context.Context, err error, stdout string, stderr string) (
 	if ctx.Err() == context.DeadlineExceeded {
 		return stdout, stderr, -1, ErrTimeout
 	}
+	if ctx.Err() != nil {
+		return stdout, stderr, -1, ctx.Err()
+	}
 	if exitErr, ok := err.(*exec.ExitError); ok {
 		return stdout, stderr, exitErr.ExitCode(), nil
 	}
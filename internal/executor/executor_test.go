@@ -1,8 +1,10 @@
 package executor
 
 import (
+	"context"
 	"errors"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
@@ -11,7 +13,7 @@ import (
 func TestShellExecutor_CapturesStdout(t *testing.T) {
 	executor := NewShellExecutor()
 
-	stdout, _, _, err := executor.Execute("echo hello", "10s", nil)
+	stdout, _, _, err := executor.Execute(context.Background(), "echo hello", "10s", nil)
 
 	require.NoError(t, err)
 	assert.Equal(t, "hello\n", stdout)
@@ -20,7 +22,7 @@ func TestShellExecutor_CapturesStdout(t *testing.T) {
 func TestShellExecutor_CapturesStderr(t *testing.T) {
 	executor := NewShellExecutor()
 
-	_, stderr, _, err := executor.Execute("echo error >&2", "10s", nil)
+	_, stderr, _, err := executor.Execute(context.Background(), "echo error >&2", "10s", nil)
 
 	require.NoError(t, err)
 	assert.Equal(t, "error\n", stderr)
@@ -29,7 +31,7 @@ func TestShellExecutor_CapturesStderr(t *testing.T) {
 func TestShellExecutor_ReturnsExitCode(t *testing.T) {
 	executor := NewShellExecutor()
 
-	_, _, exitCode, err := executor.Execute("exit 42", "10s", nil)
+	_, _, exitCode, err := executor.Execute(context.Background(), "exit 42", "10s", nil)
 
 	assert.NoError(t, err)
 	assert.Equal(t, 42, exitCode)
@@ -39,7 +41,7 @@ func TestShellExecutor_PassesEnvVars(t *testing.T) {
 	executor := NewShellExecutor()
 	env := map[string]string{"MY_VAR": "hello"}
 
-	stdout, _, _, err := executor.Execute("echo $MY_VAR", "10s", env)
+	stdout, _, _, err := executor.Execute(context.Background(), "echo $MY_VAR", "10s", env)
 
 	require.NoError(t, err)
 	assert.Equal(t, "hello\n", stdout)
@@ -49,15 +51,33 @@ func TestShellExecutor_PassesEnvVars(t *testing.T) {
 func TestShellExecutor_ReturnsErrTimeoutWhenCommandExceedsTimeout(t *testing.T) {
 	executor := NewShellExecutor()
 
-	_, _, _, err := executor.Execute("sleep 2", "100ms", nil)
+	_, _, _, err := executor.Execute(context.Background(), "sleep 2", "100ms", nil)
 
 	assert.True(t, errors.Is(err, ErrTimeout))
 }
 
+func TestShellExecutor_RejectsInvalidTimeout(t *testing.T) {
+	executor := NewShellExecutor()
+
+	_, _, exitCode, err := executor.Execute(context.Background(), "echo hello", "soon", nil)
+
+	assert.EqualError(t, err, `invalid timeout "soon": expected a duration such as 30s or 2m`)
+	assert.Equal(t, -1, exitCode)
+}
+
+func TestParseTimeout(t *testing.T) {
+	duration, err := ParseTimeout("90s")
+	require.NoError(t, err)
+	assert.Equal(t, 90*time.Second, duration)
+
+	_, err = ParseTimeout("0s")
+	assert.EqualError(t, err, `invalid timeout "0s": must be greater than zero`)
+}
+
 func TestExecuteWithStdin_PassesStdinToCommand(t *testing.T) {
 	exec := NewShellExecutor()
 
-	stdout, stderr, exitCode, err := exec.ExecuteWithStdin("cat", "5s", nil, "hello from stdin")
+	stdout, stderr, exitCode, err := exec.ExecuteWithStdin(context.Background(), "cat", "5s", nil, "hello from stdin")
 
 	require.NoError(t, err)
 	assert.Equal(t, 0, exitCode)
@@ -68,10 +88,49 @@ func TestExecuteWithStdin_PassesStdinToCommand(t *testing.T) {
 func TestExecuteWithStdin_CommandCanProcessStdin(t *testing.T) {
 	exec := NewShellExecutor()
 
-	stdout, stderr, exitCode, err := exec.ExecuteWithStdin("wc -c", "5s", nil, "12345")
+	stdout, stderr, exitCode, err := exec.ExecuteWithStdin(context.Background(), "wc -c", "5s", nil, "12345")
 
 	require.NoError(t, err)
 	assert.Equal(t, 0, exitCode)
 	assert.Contains(t, stdout, "5")
 	assert.Empty(t, stderr)
 }
+
+func TestShellExecutor_ReturnsContextErrorWhenCancelled(t *testing.T) {
+	executor := NewShellExecutor()
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	_, _, _, err := executor.Execute(ctx, "sleep 2", "10s", nil)
+
+	assert.True(t, errors.Is(err, context.Canceled))
+	assert.False(t, errors.Is(err, ErrTimeout))
+}
+
+func TestExecuteStreaming_DeliversLinesAsTheyArrive(t *testing.T) {
+	exec := NewShellExecutor()
+	var lines []string
+
+	stdout, _, exitCode, err := exec.ExecuteStreaming(context.Background(), "echo one; echo two", "5s", nil, func(stream Stream, line string) {
+		lines = append(lines, line)
+	})
+
+	require.NoError(t, err)
+	assert.Equal(t, 0, exitCode)
+	assert.Equal(t, "one\ntwo\n", stdout)
+	assert.Equal(t, []string{"one", "two"}, lines)
+}
+
+func TestExecuteStreaming_SeparatesStreamsAndFlushesPartialLines(t *testing.T) {
+	exec := NewShellExecutor()
+	received := map[Stream][]string{}
+
+	_, stderr, _, err := exec.ExecuteStreaming(context.Background(), "printf out; printf err >&2", "5s", nil, func(stream Stream, line string) {
+		received[stream] = append(received[stream], line)
+	})
+
+	require.NoError(t, err)
+	assert.Equal(t, "err", stderr)
+	assert.Equal(t, []string{"out"}, received[Stdout])
+	assert.Equal(t, []string{"err"}, received[Stderr])
+}
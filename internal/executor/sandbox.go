@@ -0,0 +1,109 @@
+package executor
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/exec"
+	"strings"
+)
+
+const sandboxHelperArg = "__templater-sandbox"
+
+var DefaultAllowedEnv = []string{"PATH", "HOME", "USER", "LANG", "LC_ALL", "TERM", "TMPDIR"}
+
+// SandboxExecutor runs commands inside Target with a scrubbed environment
+// and, where landlock is available, lets them write only beneath Target, a
+// temporary directory of their own and the paths in Writable.
+type SandboxExecutor struct {
+	Target     string
+	AllowedEnv []string
+	Writable   []string
+	helper     string
+}
+
+func NewSandboxExecutor(target string) *SandboxExecutor {
+	e := &SandboxExecutor{
+		Target:     target,
+		AllowedEnv: DefaultAllowedEnv,
+		Writable:   []string{os.DevNull},
+	}
+	if self, err := os.Executable(); err == nil && landlockAvailable() {
+		e.helper = self
+	}
+	return e
+}
+
+func (e *SandboxExecutor) Isolation() string {
+	if e.helper != "" {
+		return "landlock"
+	}
+	return "environment"
+}
+
+func (e *SandboxExecutor) Execute(ctx context.Context, command string, timeout string, env map[string]string) (string, string, int, error) {
+	return e.ExecuteWithStdin(ctx, command, timeout, env, "")
+}
+
+func (e *SandboxExecutor) ExecuteWithStdin(ctx context.Context, command string, timeout string, env map[string]string, stdin string) (string, string, int, error) {
+	return e.run(ctx, command, timeout, env, stdin, nil)
+}
+
+func (e *SandboxExecutor) ExecuteStreaming(ctx context.Context, command string, timeout string, env map[string]string, output OutputFunc) (string, string, int, error) {
+	return e.run(ctx, command, timeout, env, "", output)
+}
+
+func (e *SandboxExecutor) run(ctx context.Context, command string, timeout string, env map[string]string, stdin string, output OutputFunc) (string, string, int, error) {
+	tmp, err := os.MkdirTemp("", "templater-sandbox-")
+	if err != nil {
+		return "", "", -1, err
+	}
+	defer os.RemoveAll(tmp)
+
+	return run(ctx, timeout, stdin, output, func(ctx context.Context) *exec.Cmd {
+		return e.buildCommand(ctx, command, env, tmp)
+	})
+}
+
+func (e *SandboxExecutor) buildCommand(ctx context.Context, command string, env map[string]string, tmp string) *exec.Cmd {
+	var cmd *exec.Cmd
+	if e.helper != "" {
+		args := append([]string{sandboxHelperArg, command, e.Target, tmp}, e.Writable...)
+		cmd = exec.CommandContext(ctx, e.helper, args...)
+	} else {
+		cmd = exec.CommandContext(ctx, "sh", "-c", command)
+	}
+	cmd.Dir = e.Target
+	cmd.Env = append(scrubEnv(os.Environ(), e.AllowedEnv, env), "TMPDIR="+tmp)
+	return cmd
+}
+
+func scrubEnv(environ []string, allowed []string, env map[string]string) []string {
+	allowedSet := make(map[string]bool, len(allowed))
+	for _, key := range allowed {
+		allowedSet[key] = true
+	}
+
+	var scrubbed []string
+	for _, entry := range environ {
+		key, _, _ := strings.Cut(entry, "=")
+		if allowedSet[key] {
+			scrubbed = append(scrubbed, entry)
+		}
+	}
+	for key, value := range env {
+		scrubbed = append(scrubbed, key+"="+value)
+	}
+	return scrubbed
+}
+
+func RunSandboxHelper() {
+	if len(os.Args) < 4 || os.Args[1] != sandboxHelperArg {
+		return
+	}
+	command, writable := os.Args[2], os.Args[3:]
+	if err := execRestricted(command, writable); err != nil {
+		fmt.Fprintf(os.Stderr, "sandbox: %v\n", err)
+		os.Exit(126)
+	}
+}
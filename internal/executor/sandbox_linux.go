@@ -0,0 +1,129 @@
+package executor
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"runtime"
+	"syscall"
+	"unsafe"
+)
+
+const (
+	sysLandlockCreateRuleset = 444
+	sysLandlockAddRule       = 445
+	sysLandlockRestrictSelf  = 446
+
+	landlockCreateRulesetVersion = 1 << 0
+	landlockRulePathBeneath      = 1
+
+	landlockWriteFile  = 1 << 1
+	landlockRemoveDir  = 1 << 4
+	landlockRemoveFile = 1 << 5
+	landlockMakeChar   = 1 << 6
+	landlockMakeDir    = 1 << 7
+	landlockMakeReg    = 1 << 8
+	landlockMakeSock   = 1 << 9
+	landlockMakeFifo   = 1 << 10
+	landlockMakeBlock  = 1 << 11
+	landlockMakeSym    = 1 << 12
+	landlockRefer      = 1 << 13
+	landlockTruncate   = 1 << 14
+
+	landlockWriteAccess = landlockWriteFile | landlockRemoveDir | landlockRemoveFile |
+		landlockMakeChar | landlockMakeDir | landlockMakeReg | landlockMakeSock |
+		landlockMakeFifo | landlockMakeBlock | landlockMakeSym
+
+	prSetNoNewPrivs = 38
+)
+
+func landlockABI() int {
+	abi, _, errno := syscall.Syscall(sysLandlockCreateRuleset, 0, 0, landlockCreateRulesetVersion)
+	if errno != 0 {
+		return 0
+	}
+	return int(abi)
+}
+
+func landlockAvailable() bool {
+	return landlockABI() >= 1
+}
+
+func writeAccessFor(abi int) uint64 {
+	access := uint64(landlockWriteAccess)
+	if abi >= 2 {
+		access |= landlockRefer
+	}
+	if abi >= 3 {
+		access |= landlockTruncate
+	}
+	return access
+}
+
+func execRestricted(command string, writable []string) error {
+	runtime.LockOSThread()
+
+	if err := restrictWrites(writable); err != nil {
+		return err
+	}
+
+	shell, err := exec.LookPath("sh")
+	if err != nil {
+		return err
+	}
+	return syscall.Exec(shell, []string{"sh", "-c", command}, os.Environ())
+}
+
+func restrictWrites(writable []string) error {
+	handled := writeAccessFor(landlockABI())
+	rulesetFd, _, errno := syscall.Syscall(sysLandlockCreateRuleset, uintptr(unsafe.Pointer(&handled)), unsafe.Sizeof(handled), 0)
+	if errno != 0 {
+		return fmt.Errorf("landlock_create_ruleset: %w", errno)
+	}
+	defer syscall.Close(int(rulesetFd))
+
+	for _, dir := range writable {
+		if err := allowWrites(int(rulesetFd), dir, handled); err != nil {
+			return err
+		}
+	}
+
+	if _, _, errno := syscall.Syscall6(syscall.SYS_PRCTL, prSetNoNewPrivs, 1, 0, 0, 0, 0); errno != 0 {
+		return fmt.Errorf("prctl(PR_SET_NO_NEW_PRIVS): %w", errno)
+	}
+	if _, _, errno := syscall.Syscall(sysLandlockRestrictSelf, rulesetFd, 0, 0); errno != 0 {
+		return fmt.Errorf("landlock_restrict_self: %w", errno)
+	}
+	return nil
+}
+
+// allowWrites grants write access beneath dir, or to the file itself when it
+// is not a directory: landlock only accepts file rights on a file.
+func allowWrites(rulesetFd int, dir string, access uint64) error {
+	fd, err := syscall.Open(dir, syscall.O_RDONLY|syscall.O_CLOEXEC, 0)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("open %s: %w", dir, err)
+	}
+	defer syscall.Close(fd)
+
+	var stat syscall.Stat_t
+	if err := syscall.Fstat(fd, &stat); err != nil {
+		return fmt.Errorf("stat %s: %w", dir, err)
+	}
+	if stat.Mode&syscall.S_IFMT != syscall.S_IFDIR {
+		access &= landlockWriteFile | landlockTruncate
+	}
+
+	// struct landlock_path_beneath_attr is packed: u64 allowed_access, s32 parent_fd.
+	var attr [12]byte
+	*(*uint64)(unsafe.Pointer(&attr[0])) = access
+	*(*int32)(unsafe.Pointer(&attr[8])) = int32(fd)
+
+	if _, _, errno := syscall.Syscall6(sysLandlockAddRule, uintptr(rulesetFd), landlockRulePathBeneath, uintptr(unsafe.Pointer(&attr[0])), 0, 0, 0); errno != 0 {
+		return fmt.Errorf("landlock_add_rule %s: %w", dir, errno)
+	}
+	return nil
+}
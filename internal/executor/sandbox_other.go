@@ -0,0 +1,13 @@
+//go:build !linux
+
+package executor
+
+import "errors"
+
+func landlockAvailable() bool {
+	return false
+}
+
+func execRestricted(command string, writable []string) error {
+	return errors.New("filesystem isolation is only supported on Linux")
+}
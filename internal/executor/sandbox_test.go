@@ -0,0 +1,87 @@
+package executor
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMain(m *testing.M) {
+	RunSandboxHelper()
+	os.Exit(m.Run())
+}
+
+func TestSandboxExecutor_ScrubsEnvironment(t *testing.T) {
+	t.Setenv("TEMPLATER_SECRET", "leaked")
+	executor := NewSandboxExecutor(t.TempDir())
+
+	stdout, _, _, err := executor.Execute(context.Background(), `echo "[$TEMPLATER_SECRET][$MY_VAR]"`, "10s", map[string]string{"MY_VAR": "hello"})
+
+	require.NoError(t, err)
+	assert.Equal(t, "[][hello]\n", stdout)
+}
+
+func TestSandboxExecutor_KeepsAllowedEnvironment(t *testing.T) {
+	executor := NewSandboxExecutor(t.TempDir())
+
+	stdout, _, _, err := executor.Execute(context.Background(), `echo "$PATH"`, "10s", nil)
+
+	require.NoError(t, err)
+	assert.Equal(t, os.Getenv("PATH")+"\n", stdout)
+}
+
+func TestSandboxExecutor_RunsInTargetDirectory(t *testing.T) {
+	dir := t.TempDir()
+	executor := NewSandboxExecutor(dir)
+
+	stdout, _, _, err := executor.Execute(context.Background(), "pwd -P", "10s", nil)
+
+	require.NoError(t, err)
+	resolved, err := filepath.EvalSymlinks(dir)
+	require.NoError(t, err)
+	assert.Equal(t, resolved+"\n", stdout)
+}
+
+func TestSandboxExecutor_RestrictsWritesToTargetDirectory(t *testing.T) {
+	if !landlockAvailable() {
+		t.Skip("landlock is not available on this kernel")
+	}
+	root := t.TempDir()
+	target := filepath.Join(root, "target")
+	outside := filepath.Join(root, "outside")
+	require.NoError(t, os.Mkdir(target, 0755))
+	require.NoError(t, os.Mkdir(outside, 0755))
+
+	executor := NewSandboxExecutor(target)
+
+	_, _, exitCode, err := executor.Execute(context.Background(), "echo inside > "+filepath.Join(target, "inside.txt"), "10s", nil)
+	require.NoError(t, err)
+	assert.Equal(t, 0, exitCode)
+	assert.FileExists(t, filepath.Join(target, "inside.txt"))
+
+	_, _, exitCode, err = executor.Execute(context.Background(), `echo scratch > "$TMPDIR/scratch.txt" && echo discarded > /dev/null`, "10s", nil)
+	require.NoError(t, err)
+	assert.Equal(t, 0, exitCode)
+
+	_, _, exitCode, err = executor.Execute(context.Background(), "echo outside > "+filepath.Join(outside, "escape.txt"), "10s", nil)
+	require.NoError(t, err)
+	assert.NotEqual(t, 0, exitCode)
+	assert.NoFileExists(t, filepath.Join(outside, "escape.txt"))
+
+	_, _, exitCode, err = executor.Execute(context.Background(), "echo shared > "+filepath.Join(os.TempDir(), "templater-escape.txt"), "10s", nil)
+	require.NoError(t, err)
+	assert.NotEqual(t, 0, exitCode)
+	assert.NoFileExists(t, filepath.Join(os.TempDir(), "templater-escape.txt"))
+}
+
+func TestScrubEnv(t *testing.T) {
+	environ := []string{"PATH=/bin", "AWS_SECRET_ACCESS_KEY=x", "HOME=/home/me"}
+
+	scrubbed := scrubEnv(environ, []string{"PATH", "HOME"}, map[string]string{"TEMPLATER_FEATURE": "auth"})
+
+	assert.Equal(t, []string{"PATH=/bin", "HOME=/home/me", "TEMPLATER_FEATURE=auth"}, scrubbed)
+}
@@ -0,0 +1,52 @@
+package executor
+
+import (
+	"strings"
+	"sync"
+)
+
+type lineSplitter struct {
+	mu      sync.Mutex
+	output  OutputFunc
+	pending map[Stream]string
+}
+
+func newLineSplitter(output OutputFunc) *lineSplitter {
+	return &lineSplitter{output: output, pending: make(map[Stream]string)}
+}
+
+func (s *lineSplitter) writer(stream Stream) *streamWriter {
+	return &streamWriter{splitter: s, stream: stream}
+}
+
+func (s *lineSplitter) write(stream Stream, data []byte) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	buffered := s.pending[stream] + string(data)
+	lines := strings.Split(buffered, "\n")
+	for _, line := range lines[:len(lines)-1] {
+		s.output(stream, line)
+	}
+	s.pending[stream] = lines[len(lines)-1]
+}
+
+func (s *lineSplitter) flush() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, stream := range []Stream{Stdout, Stderr} {
+		if s.pending[stream] != "" {
+			s.output(stream, s.pending[stream])
+			s.pending[stream] = ""
+		}
+	}
+}
+
+type streamWriter struct {
+	splitter *lineSplitter
+	stream   Stream
+}
+
+func (w *streamWriter) Write(data []byte) (int, error) {
+	w.splitter.write(w.stream, data)
+	return len(data), nil
+}
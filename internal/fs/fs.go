@@ -11,6 +11,9 @@ type FileSystem interface {
 	WriteFile(path string, data []byte) error
 	AppendFile(path string, data []byte) error
 	Stat(path string) (os.FileInfo, error)
+	MkdirAll(path string) error
+	Remove(path string) error
+	Chmod(path string, mode os.FileMode) error
 }
 
 type OSFileSystem struct{}
@@ -27,6 +30,18 @@ func (OSFileSystem) Stat(path string) (os.FileInfo, error) {
 	return os.Stat(path)
 }
 
+func (OSFileSystem) MkdirAll(path string) error {
+	return os.MkdirAll(path, 0755)
+}
+
+func (OSFileSystem) Remove(path string) error {
+	return os.Remove(path)
+}
+
+func (OSFileSystem) Chmod(path string, mode os.FileMode) error {
+	return os.Chmod(path, mode)
+}
+
 func (OSFileSystem) WriteFile(path string, data []byte) error {
 	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
 		return err
@@ -0,0 +1,132 @@
+package patch
+
+import (
+	"fmt"
+	"strings"
+)
+
+type HunkError struct {
+	File   string
+	Hunk   Hunk
+	Line   int
+	Reason string
+}
+
+func (e *HunkError) Error() string {
+	return fmt.Sprintf("%s:%d: %s", e.File, e.Line, e.Reason)
+}
+
+func Apply(original string, exists bool, file File) (string, error) {
+	if file.IsBinary {
+		return "", &HunkError{File: file.Path(), Reason: "binary patches cannot be applied in memory"}
+	}
+	if file.IsNew && exists {
+		return "", &HunkError{File: file.Path(), Reason: "already exists in working directory"}
+	}
+	if !file.IsNew && !exists {
+		return "", &HunkError{File: file.Path(), Reason: "No such file or directory"}
+	}
+
+	lines, trailingNewline := SplitLines(original)
+	offset := 0
+	for _, hunk := range file.Hunks {
+		preimage := hunk.Preimage()
+		postimage := hunk.Postimage()
+
+		at := hunk.OldStart - 1 + offset
+		if hunk.OldLines == 0 {
+			at = hunk.OldStart + offset
+		}
+		position, ok := locate(lines, preimage, at)
+		if !ok {
+			return "", &HunkError{File: file.Path(), Hunk: hunk, Line: hunk.OldStart, Reason: "patch does not apply"}
+		}
+
+		reachesEnd := position+len(preimage) == len(lines)
+		lines = splice(lines, position, len(preimage), postimage)
+		offset += len(postimage) - len(preimage)
+
+		if reachesEnd {
+			oldNoNewline, newNoNewline := hunk.missingNewlines()
+			if newNoNewline {
+				trailingNewline = false
+			} else if oldNoNewline || len(postimage) > 0 {
+				trailingNewline = true
+			}
+		}
+	}
+
+	if file.IsDeleted {
+		return "", nil
+	}
+	return JoinLines(lines, trailingNewline), nil
+}
+
+func locate(lines, preimage []string, at int) (int, bool) {
+	for distance := 0; distance <= len(lines); distance++ {
+		for _, candidate := range []int{at - distance, at + distance} {
+			if matchesAt(lines, preimage, candidate) {
+				return candidate, true
+			}
+			if distance == 0 {
+				break
+			}
+		}
+	}
+	return 0, false
+}
+
+func matchesAt(lines, preimage []string, at int) bool {
+	if at < 0 || at+len(preimage) > len(lines) {
+		return false
+	}
+	for i, line := range preimage {
+		if lines[at+i] != line {
+			return false
+		}
+	}
+	return true
+}
+
+func splice(lines []string, at, remove int, insert []string) []string {
+	result := make([]string, 0, len(lines)-remove+len(insert))
+	result = append(result, lines[:at]...)
+	result = append(result, insert...)
+	return append(result, lines[at+remove:]...)
+}
+
+func (h Hunk) missingNewlines() (oldSide, newSide bool) {
+	for i, line := range h.Lines {
+		if !strings.HasPrefix(line, `\`) || i == 0 {
+			continue
+		}
+		switch previous := h.Lines[i-1]; {
+		case strings.HasPrefix(previous, "-"):
+			oldSide = true
+		case strings.HasPrefix(previous, "+"):
+			newSide = true
+		default:
+			oldSide, newSide = true, true
+		}
+	}
+	return oldSide, newSide
+}
+
+func SplitLines(content string) ([]string, bool) {
+	if content == "" {
+		return nil, false
+	}
+	trailingNewline := strings.HasSuffix(content, "\n")
+	return strings.Split(strings.TrimSuffix(content, "\n"), "\n"), trailingNewline
+}
+
+func JoinLines(lines []string, trailingNewline bool) string {
+	if len(lines) == 0 {
+		return ""
+	}
+	joined := strings.Join(lines, "\n")
+	if trailingNewline {
+		joined += "\n"
+	}
+	return joined
+}
@@ -0,0 +1,77 @@
+package patch
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func parseOne(t *testing.T, data string) File {
+	t.Helper()
+	files, err := Parse([]byte(data))
+	require.NoError(t, err)
+	require.Len(t, files, 1)
+	return files[0]
+}
+
+func TestApply_NewFile(t *testing.T) {
+	file := parseOne(t, "--- /dev/null\n+++ b/auth.txt\n@@ -0,0 +1,2 @@\n+one\n+two\n")
+
+	result, err := Apply("", false, file)
+	require.NoError(t, err)
+	assert.Equal(t, "one\ntwo\n", result)
+}
+
+func TestApply_NewFileAlreadyExists(t *testing.T) {
+	file := parseOne(t, "--- /dev/null\n+++ b/auth.txt\n@@ -0,0 +1 @@\n+one\n")
+
+	_, err := Apply("one\n", true, file)
+
+	var hunkErr *HunkError
+	require.ErrorAs(t, err, &hunkErr)
+	assert.Equal(t, "already exists in working directory", hunkErr.Reason)
+}
+
+func TestApply_ModifiesWithOffset(t *testing.T) {
+	file := parseOne(t, "--- a/f.txt\n+++ b/f.txt\n@@ -1,3 +1,3 @@\n a\n-b\n+B\n c\n")
+
+	result, err := Apply("header\na\nb\nc\n", true, file)
+	require.NoError(t, err)
+	assert.Equal(t, "header\na\nB\nc\n", result)
+}
+
+func TestApply_RejectsMismatchedContext(t *testing.T) {
+	file := parseOne(t, "--- a/f.txt\n+++ b/f.txt\n@@ -1,3 +1,3 @@\n a\n-x\n+y\n c\n")
+
+	_, err := Apply("a\nb\nc\n", true, file)
+
+	var hunkErr *HunkError
+	require.ErrorAs(t, err, &hunkErr)
+	assert.Equal(t, "f.txt", hunkErr.File)
+	assert.Equal(t, 1, hunkErr.Line)
+	assert.Equal(t, "patch does not apply", hunkErr.Reason)
+}
+
+func TestApply_MissingFile(t *testing.T) {
+	file := parseOne(t, "--- a/f.txt\n+++ b/f.txt\n@@ -1 +1 @@\n-a\n+b\n")
+
+	_, err := Apply("", false, file)
+	assert.EqualError(t, err, "f.txt:0: No such file or directory")
+}
+
+func TestApply_DeletedFile(t *testing.T) {
+	file := parseOne(t, "diff --git a/f.txt b/f.txt\ndeleted file mode 100644\n--- a/f.txt\n+++ /dev/null\n@@ -1 +0,0 @@\n-a\n")
+
+	result, err := Apply("a\n", true, file)
+	require.NoError(t, err)
+	assert.Equal(t, "", result)
+}
+
+func TestApply_NoNewlineAtEndOfFile(t *testing.T) {
+	file := parseOne(t, "--- a/f.txt\n+++ b/f.txt\n@@ -1 +1 @@\n-a\n\\ No newline at end of file\n+b\n")
+
+	result, err := Apply("a", true, file)
+	require.NoError(t, err)
+	assert.Equal(t, "b\n", result)
+}
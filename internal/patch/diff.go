@@ -0,0 +1,250 @@
+package patch
+
+import (
+	"fmt"
+	"strings"
+)
+
+const (
+	contextLines = 3
+	noNewline    = "\x00no newline"
+)
+
+type edit struct {
+	kind byte
+	line string
+}
+
+func Unified(fromName, toName, from, to string) string {
+	a := diffableLines(from)
+	b := diffableLines(to)
+	hunks := groupHunks(diffLines(a, b))
+	if len(hunks) == 0 {
+		return ""
+	}
+
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "--- %s\n+++ %s\n", fromName, toName)
+	for _, hunk := range hunks {
+		sb.WriteString(hunk.String())
+	}
+	return sb.String()
+}
+
+func Count(from, to string) (added, removed int) {
+	a, _ := SplitLines(from)
+	b, _ := SplitLines(to)
+	for _, e := range diffLines(a, b) {
+		switch e.kind {
+		case '+':
+			added++
+		case '-':
+			removed++
+		}
+	}
+	return added, removed
+}
+
+func diffLines(a, b []string) []edit {
+	var edits []edit
+	compareLines(a, b, &edits)
+	return edits
+}
+
+func compareLines(a, b []string, edits *[]edit) {
+	prefix := 0
+	for prefix < len(a) && prefix < len(b) && a[prefix] == b[prefix] {
+		*edits = append(*edits, edit{' ', a[prefix]})
+		prefix++
+	}
+	a, b = a[prefix:], b[prefix:]
+
+	suffix := 0
+	for suffix < len(a) && suffix < len(b) && a[len(a)-1-suffix] == b[len(b)-1-suffix] {
+		suffix++
+	}
+	common := a[len(a)-suffix:]
+	a, b = a[:len(a)-suffix], b[:len(b)-suffix]
+
+	switch {
+	case len(a) == 0:
+		for _, line := range b {
+			*edits = append(*edits, edit{'+', line})
+		}
+	case len(b) == 0:
+		for _, line := range a {
+			*edits = append(*edits, edit{'-', line})
+		}
+	default:
+		x, y, u, v := middleSnake(a, b)
+		compareLines(a[:x], b[:y], edits)
+		for _, line := range a[x:u] {
+			*edits = append(*edits, edit{' ', line})
+		}
+		compareLines(a[u:], b[v:], edits)
+	}
+
+	for _, line := range common {
+		*edits = append(*edits, edit{' ', line})
+	}
+}
+
+func middleSnake(a, b []string) (x, y, u, v int) {
+	n, m := len(a), len(b)
+	delta := n - m
+	odd := delta%2 != 0
+	limit := (n + m + 1) / 2
+	offset := limit + 1
+	forward := make([]int, 2*offset+1)
+	backward := make([]int, 2*offset+1)
+
+	for d := 0; d <= limit; d++ {
+		for k := -d; k <= d; k += 2 {
+			var x int
+			if k == -d || (k != d && forward[offset+k-1] < forward[offset+k+1]) {
+				x = forward[offset+k+1]
+			} else {
+				x = forward[offset+k-1] + 1
+			}
+			y := x - k
+			startX, startY := x, y
+			for x < n && y < m && a[x] == b[y] {
+				x++
+				y++
+			}
+			forward[offset+k] = x
+			if c := delta - k; odd && c >= -(d-1) && c <= d-1 && x+backward[offset+c] >= n {
+				return startX, startY, x, y
+			}
+		}
+
+		for k := -d; k <= d; k += 2 {
+			var x int
+			if k == -d || (k != d && backward[offset+k-1] < backward[offset+k+1]) {
+				x = backward[offset+k+1]
+			} else {
+				x = backward[offset+k-1] + 1
+			}
+			y := x - k
+			startX, startY := x, y
+			for x < n && y < m && a[n-1-x] == b[m-1-y] {
+				x++
+				y++
+			}
+			backward[offset+k] = x
+			if c := delta - k; !odd && c >= -d && c <= d && x+forward[offset+c] >= n {
+				return n - x, m - y, n - startX, m - startY
+			}
+		}
+	}
+	return 0, 0, 0, 0
+}
+
+func diffableLines(content string) []string {
+	lines, trailingNewline := SplitLines(content)
+	if !trailingNewline && len(lines) > 0 {
+		lines[len(lines)-1] += noNewline
+	}
+	return lines
+}
+
+type editHunk struct {
+	oldStart, oldLines int
+	newStart, newLines int
+	edits              []edit
+}
+
+func (h editHunk) String() string {
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "@@ -%s +%s @@\n", formatRange(h.oldStart, h.oldLines), formatRange(h.newStart, h.newLines))
+	for _, e := range h.edits {
+		sb.WriteByte(e.kind)
+		if line, ok := strings.CutSuffix(e.line, noNewline); ok {
+			sb.WriteString(line + "\n\\ No newline at end of file\n")
+			continue
+		}
+		sb.WriteString(e.line)
+		sb.WriteByte('\n')
+	}
+	return sb.String()
+}
+
+func formatRange(start, lines int) string {
+	if lines == 1 {
+		return fmt.Sprintf("%d", start)
+	}
+	if lines == 0 {
+		start--
+	}
+	return fmt.Sprintf("%d,%d", start, lines)
+}
+
+func groupHunks(edits []edit) []editHunk {
+	var hunks []editHunk
+	oldLine, newLine := 1, 1
+	i := 0
+
+	for i < len(edits) {
+		if edits[i].kind == ' ' {
+			oldLine++
+			newLine++
+			i++
+			continue
+		}
+
+		lead := min(contextLines, countContextBefore(edits, i))
+		start := i - lead
+		hunk := editHunk{oldStart: oldLine - lead, newStart: newLine - lead}
+
+		end := i
+		for end < len(edits) {
+			if edits[end].kind != ' ' {
+				end++
+				continue
+			}
+			run := countContextFrom(edits, end)
+			if end+run >= len(edits) || run > 2*contextLines {
+				end += min(run, contextLines)
+				break
+			}
+			end += run
+		}
+
+		hunk.edits = edits[start:end]
+		for _, e := range hunk.edits {
+			if e.kind != '+' {
+				hunk.oldLines++
+			}
+			if e.kind != '-' {
+				hunk.newLines++
+			}
+		}
+		for _, e := range edits[i:end] {
+			if e.kind != '+' {
+				oldLine++
+			}
+			if e.kind != '-' {
+				newLine++
+			}
+		}
+		hunks = append(hunks, hunk)
+		i = end
+	}
+	return hunks
+}
+
+func countContextBefore(edits []edit, i int) int {
+	n := 0
+	for j := i - 1; j >= 0 && edits[j].kind == ' '; j-- {
+		n++
+	}
+	return n
+}
+
+func countContextFrom(edits []edit, i int) int {
+	n := 0
+	for j := i; j < len(edits) && edits[j].kind == ' '; j++ {
+		n++
+	}
+	return n
+}
@@ -0,0 +1,134 @@
+package patch
+
+import (
+	"fmt"
+	"runtime"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestUnified_NewFile(t *testing.T) {
+	diff := Unified("/dev/null", "b/auth.txt", "", "one\ntwo\n")
+
+	assert.Equal(t,
+		"--- /dev/null\n"+
+			"+++ b/auth.txt\n"+
+			"@@ -0,0 +1,2 @@\n"+
+			"+one\n"+
+			"+two\n",
+		diff)
+}
+
+func TestUnified_ModifiedLineWithContext(t *testing.T) {
+	from := "1\n2\n3\n4\n5\n6\n7\n8\n9\n"
+	to := "1\n2\n3\n4\nfive\n6\n7\n8\n9\n"
+
+	diff := Unified("a/f.txt", "b/f.txt", from, to)
+
+	assert.Equal(t,
+		"--- a/f.txt\n"+
+			"+++ b/f.txt\n"+
+			"@@ -2,7 +2,7 @@\n"+
+			" 2\n"+
+			" 3\n"+
+			" 4\n"+
+			"-5\n"+
+			"+five\n"+
+			" 6\n"+
+			" 7\n"+
+			" 8\n",
+		diff)
+}
+
+func TestUnified_SeparatesDistantHunks(t *testing.T) {
+	var lines []string
+	for i := 0; i < 30; i++ {
+		lines = append(lines, "line")
+	}
+	from := strings.Join(lines, "\n") + "\n"
+	lines[2], lines[25] = "changed", "changed"
+	to := strings.Join(lines, "\n") + "\n"
+
+	files, err := Parse([]byte(Unified("a/f", "b/f", from, to)))
+	require.NoError(t, err)
+
+	require.Len(t, files, 1)
+	assert.Len(t, files[0].Hunks, 2)
+}
+
+func TestUnified_MissingNewline(t *testing.T) {
+	diff := Unified("a/f.txt", "b/f.txt", "a", "a\n")
+
+	assert.Equal(t,
+		"--- a/f.txt\n"+
+			"+++ b/f.txt\n"+
+			"@@ -1 +1 @@\n"+
+			"-a\n"+
+			"\\ No newline at end of file\n"+
+			"+a\n",
+		diff)
+}
+
+func TestUnified_IdenticalIsEmpty(t *testing.T) {
+	assert.Equal(t, "", Unified("a/f", "b/f", "same\n", "same\n"))
+}
+
+func TestUnified_RoundTripsThroughApply(t *testing.T) {
+	from := "package main\n\nimport \"fmt\"\n\nfunc main() {\n\tfmt.Println(\"hi\")\n}\n"
+	to := "package main\n\nimport (\n\t\"fmt\"\n\t\"os\"\n)\n\nfunc main() {\n\tfmt.Println(\"hi\")\n\tos.Exit(0)\n}\n"
+
+	files, err := Parse([]byte(Unified("a/main.go", "b/main.go", from, to)))
+	require.NoError(t, err)
+	require.Len(t, files, 1)
+
+	result, err := Apply(from, true, files[0])
+	require.NoError(t, err)
+	assert.Equal(t, to, result)
+}
+
+func TestCount(t *testing.T) {
+	added, removed := Count("a\nb\nc\n", "a\nB\nc\nd\n")
+
+	assert.Equal(t, 2, added)
+	assert.Equal(t, 1, removed)
+}
+
+func TestCount_IsMinimal(t *testing.T) {
+	from := "a\nb\nc\na\nb\nb\na\n"
+	to := "c\nb\na\nb\na\nc\n"
+
+	added, removed := Count(from, to)
+
+	assert.Equal(t, 5, added+removed)
+}
+
+func TestUnified_LargeInputsStayLinear(t *testing.T) {
+	var from, to strings.Builder
+	for i := 0; i < 20000; i++ {
+		fmt.Fprintf(&from, "line %d\n", i)
+		if i%7 == 0 {
+			fmt.Fprintf(&to, "changed %d\n", i)
+		} else {
+			fmt.Fprintf(&to, "line %d\n", i)
+		}
+	}
+
+	var before, after runtime.MemStats
+	runtime.ReadMemStats(&before)
+	added, removed := Count("", to.String())
+	assert.Equal(t, 20000, added)
+	assert.Zero(t, removed)
+
+	files, err := Parse([]byte(Unified("a/f", "b/f", from.String(), to.String())))
+	require.NoError(t, err)
+	runtime.ReadMemStats(&after)
+	assert.Less(t, after.TotalAlloc-before.TotalAlloc, uint64(64<<20))
+
+	require.Len(t, files, 1)
+	result, err := Apply(from.String(), true, files[0])
+	require.NoError(t, err)
+	assert.Equal(t, to.String(), result)
+}
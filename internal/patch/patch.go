@@ -0,0 +1,256 @@
+package patch
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+type File struct {
+	OldPath   string
+	NewPath   string
+	IsNew     bool
+	IsDeleted bool
+	IsBinary  bool
+	Hunks     []Hunk
+}
+
+func (f File) Path() string {
+	if f.IsDeleted {
+		return f.OldPath
+	}
+	return f.NewPath
+}
+
+type Hunk struct {
+	OldStart int
+	OldLines int
+	NewStart int
+	NewLines int
+	Header   string
+	Lines    []string
+}
+
+func (h Hunk) String() string {
+	return h.Header + "\n" + strings.Join(h.Lines, "\n") + "\n"
+}
+
+func (h Hunk) Preimage() []string {
+	return h.side('-')
+}
+
+func (h Hunk) Postimage() []string {
+	return h.side('+')
+}
+
+func (h Hunk) side(keep byte) []string {
+	var lines []string
+	for _, line := range h.Lines {
+		if line == "" {
+			lines = append(lines, "")
+			continue
+		}
+		if line[0] == ' ' || line[0] == keep {
+			lines = append(lines, line[1:])
+		}
+	}
+	return lines
+}
+
+func (f File) Reverse() File {
+	reversed := File{
+		OldPath:   f.NewPath,
+		NewPath:   f.OldPath,
+		IsNew:     f.IsDeleted,
+		IsDeleted: f.IsNew,
+		IsBinary:  f.IsBinary,
+		Hunks:     make([]Hunk, len(f.Hunks)),
+	}
+	for i, h := range f.Hunks {
+		reversed.Hunks[i] = h.Reverse()
+	}
+	return reversed
+}
+
+func (h Hunk) Reverse() Hunk {
+	reversed := Hunk{
+		OldStart: h.NewStart,
+		OldLines: h.NewLines,
+		NewStart: h.OldStart,
+		NewLines: h.OldLines,
+		Header:   h.Header,
+		Lines:    make([]string, len(h.Lines)),
+	}
+	if ranges, section, ok := strings.Cut(strings.TrimPrefix(h.Header, "@@ "), " @@"); ok {
+		if fields := strings.Fields(ranges); len(fields) == 2 {
+			reversed.Header = "@@ -" + fields[1][1:] + " +" + fields[0][1:] + " @@" + section
+		}
+	}
+	for i, line := range h.Lines {
+		switch {
+		case strings.HasPrefix(line, "+"):
+			line = "-" + line[1:]
+		case strings.HasPrefix(line, "-"):
+			line = "+" + line[1:]
+		}
+		reversed.Lines[i] = line
+	}
+	return reversed
+}
+
+func (f File) Added() int {
+	return f.count('+')
+}
+
+func (f File) Removed() int {
+	return f.count('-')
+}
+
+func (f File) count(prefix byte) int {
+	n := 0
+	for _, h := range f.Hunks {
+		for _, line := range h.Lines {
+			if line != "" && line[0] == prefix {
+				n++
+			}
+		}
+	}
+	return n
+}
+
+func Parse(data []byte) ([]File, error) {
+	lines := strings.Split(strings.TrimSuffix(string(data), "\n"), "\n")
+	var files []File
+	var current *File
+
+	for i := 0; i < len(lines); i++ {
+		line := lines[i]
+		switch {
+		case strings.HasPrefix(line, "diff --git "):
+			files = append(files, File{})
+			current = &files[len(files)-1]
+			current.OldPath, current.NewPath = parseGitHeader(line)
+		case strings.HasPrefix(line, "--- "):
+			if current == nil || len(current.Hunks) > 0 {
+				files = append(files, File{})
+				current = &files[len(files)-1]
+			}
+			current.OldPath = parseFileLine(line[4:])
+			current.IsNew = current.IsNew || current.OldPath == ""
+		case strings.HasPrefix(line, "+++ ") && current != nil:
+			current.NewPath = parseFileLine(line[4:])
+			current.IsDeleted = current.IsDeleted || current.NewPath == ""
+		case strings.HasPrefix(line, "new file mode") && current != nil:
+			current.IsNew = true
+		case strings.HasPrefix(line, "deleted file mode") && current != nil:
+			current.IsDeleted = true
+		case (strings.HasPrefix(line, "Binary files ") || line == "GIT binary patch") && current != nil:
+			current.IsBinary = true
+		case strings.HasPrefix(line, "@@ ") && current != nil:
+			hunk, err := parseHunkHeader(line)
+			if err != nil {
+				return nil, err
+			}
+			i = readHunkBody(lines, i+1, &hunk)
+			current.Hunks = append(current.Hunks, hunk)
+		}
+	}
+
+	for i := range files {
+		if files[i].IsNew {
+			files[i].OldPath = ""
+		}
+		if files[i].IsDeleted {
+			files[i].NewPath = ""
+		}
+	}
+	return files, nil
+}
+
+func parseGitHeader(line string) (string, string) {
+	fields := strings.Fields(strings.TrimPrefix(line, "diff --git "))
+	if len(fields) != 2 {
+		return "", ""
+	}
+	return stripPrefix(fields[0]), stripPrefix(fields[1])
+}
+
+func parseFileLine(name string) string {
+	if tab := strings.IndexByte(name, '\t'); tab >= 0 {
+		name = name[:tab]
+	}
+	if name == "/dev/null" {
+		return ""
+	}
+	return stripPrefix(name)
+}
+
+func stripPrefix(name string) string {
+	if strings.HasPrefix(name, "a/") || strings.HasPrefix(name, "b/") {
+		return name[2:]
+	}
+	return name
+}
+
+func parseHunkHeader(line string) (Hunk, error) {
+	end := strings.Index(line[3:], " @@")
+	if end < 0 {
+		return Hunk{}, fmt.Errorf("malformed hunk header: %s", line)
+	}
+	ranges := strings.Fields(line[3 : 3+end])
+	if len(ranges) != 2 || !strings.HasPrefix(ranges[0], "-") || !strings.HasPrefix(ranges[1], "+") {
+		return Hunk{}, fmt.Errorf("malformed hunk header: %s", line)
+	}
+	oldStart, oldLines, err := parseRange(ranges[0][1:])
+	if err != nil {
+		return Hunk{}, fmt.Errorf("malformed hunk header: %s", line)
+	}
+	newStart, newLines, err := parseRange(ranges[1][1:])
+	if err != nil {
+		return Hunk{}, fmt.Errorf("malformed hunk header: %s", line)
+	}
+	return Hunk{
+		OldStart: oldStart,
+		OldLines: oldLines,
+		NewStart: newStart,
+		NewLines: newLines,
+		Header:   line,
+	}, nil
+}
+
+func parseRange(r string) (start, count int, err error) {
+	startText, countText, found := strings.Cut(r, ",")
+	start, err = strconv.Atoi(startText)
+	if err != nil {
+		return 0, 0, err
+	}
+	if !found {
+		return start, 1, nil
+	}
+	count, err = strconv.Atoi(countText)
+	return start, count, err
+}
+
+func readHunkBody(lines []string, i int, hunk *Hunk) int {
+	oldSeen, newSeen := 0, 0
+	for ; i < len(lines); i++ {
+		line := lines[i]
+		if oldSeen >= hunk.OldLines && newSeen >= hunk.NewLines && !strings.HasPrefix(line, `\`) {
+			break
+		}
+		switch {
+		case line == "" || line[0] == ' ':
+			oldSeen++
+			newSeen++
+		case line[0] == '-':
+			oldSeen++
+		case line[0] == '+':
+			newSeen++
+		case line[0] == '\\':
+		default:
+			return i - 1
+		}
+		hunk.Lines = append(hunk.Lines, line)
+	}
+	return i - 1
+}
@@ -0,0 +1,141 @@
+package patch
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParse_NewFile(t *testing.T) {
+	data := "diff --git a/auth.txt b/auth.txt\n" +
+		"new file mode 100644\n" +
+		"index 0000000..e69de29\n" +
+		"--- /dev/null\n" +
+		"+++ b/auth.txt\n" +
+		"@@ -0,0 +1 @@\n" +
+		"+auth feature\n"
+
+	files, err := Parse([]byte(data))
+	require.NoError(t, err)
+
+	require.Len(t, files, 1)
+	assert.True(t, files[0].IsNew)
+	assert.Equal(t, "", files[0].OldPath)
+	assert.Equal(t, "auth.txt", files[0].Path())
+	require.Len(t, files[0].Hunks, 1)
+	assert.Equal(t, []string{"+auth feature"}, files[0].Hunks[0].Lines)
+	assert.Equal(t, 1, files[0].Added())
+}
+
+func TestParse_ModifiedFileWithMultipleHunks(t *testing.T) {
+	data := "diff --git a/main.go b/main.go\n" +
+		"index 1234567..abcdefg 100644\n" +
+		"--- a/main.go\n" +
+		"+++ b/main.go\n" +
+		"@@ -1,3 +1,3 @@ package main\n" +
+		" a\n" +
+		"-b\n" +
+		"+B\n" +
+		" c\n" +
+		"@@ -10,2 +10,3 @@\n" +
+		" x\n" +
+		"+y\n" +
+		" z\n"
+
+	files, err := Parse([]byte(data))
+	require.NoError(t, err)
+
+	require.Len(t, files, 1)
+	file := files[0]
+	assert.False(t, file.IsNew)
+	assert.Equal(t, "main.go", file.OldPath)
+	require.Len(t, file.Hunks, 2)
+	assert.Equal(t, 10, file.Hunks[1].OldStart)
+	assert.Equal(t, 2, file.Hunks[1].OldLines)
+	assert.Equal(t, []string{"a", "b", "c"}, file.Hunks[0].Preimage())
+	assert.Equal(t, []string{"a", "B", "c"}, file.Hunks[0].Postimage())
+	assert.Equal(t, 2, file.Added())
+	assert.Equal(t, 1, file.Removed())
+}
+
+func TestParse_MultipleFiles(t *testing.T) {
+	data := "diff --git a/a.txt b/a.txt\n" +
+		"deleted file mode 100644\n" +
+		"--- a/a.txt\n" +
+		"+++ /dev/null\n" +
+		"@@ -1 +0,0 @@\n" +
+		"-gone\n" +
+		"diff --git a/b.txt b/b.txt\n" +
+		"--- a/b.txt\n" +
+		"+++ b/b.txt\n" +
+		"@@ -1 +1 @@\n" +
+		"--- old\n" +
+		"+new\n"
+
+	files, err := Parse([]byte(data))
+	require.NoError(t, err)
+
+	require.Len(t, files, 2)
+	assert.True(t, files[0].IsDeleted)
+	assert.Equal(t, "a.txt", files[0].Path())
+	assert.Equal(t, []string{"-- old"}, files[1].Hunks[0].Preimage())
+}
+
+func TestParse_BinaryFile(t *testing.T) {
+	data := "diff --git a/logo.png b/logo.png\n" +
+		"new file mode 100644\n" +
+		"Binary files /dev/null and b/logo.png differ\n"
+
+	files, err := Parse([]byte(data))
+	require.NoError(t, err)
+
+	require.Len(t, files, 1)
+	assert.True(t, files[0].IsBinary)
+	assert.Equal(t, "logo.png", files[0].Path())
+}
+
+func TestParse_NonPatchContentHasNoFiles(t *testing.T) {
+	files, err := Parse([]byte("patch content"))
+	require.NoError(t, err)
+	assert.Empty(t, files)
+}
+
+func TestParse_MalformedHunkHeader(t *testing.T) {
+	data := "--- a/x\n+++ b/x\n@@ -a +1 @@\n"
+
+	_, err := Parse([]byte(data))
+	assert.EqualError(t, err, "malformed hunk header: @@ -a +1 @@")
+}
+
+func TestFile_Reverse(t *testing.T) {
+	data := "diff --git a/main.go b/main.go\n" +
+		"--- a/main.go\n" +
+		"+++ b/main.go\n" +
+		"@@ -1,2 +1,3 @@ package main\n" +
+		" a\n" +
+		"-b\n" +
+		"+B\n" +
+		"+C\n"
+
+	files, err := Parse([]byte(data))
+	require.NoError(t, err)
+	reversed := files[0].Reverse()
+
+	require.Len(t, reversed.Hunks, 1)
+	assert.Equal(t, "@@ -1,3 +1,2 @@ package main", reversed.Hunks[0].Header)
+	assert.Equal(t, []string{" a", "+b", "-B", "-C"}, reversed.Hunks[0].Lines)
+
+	patched, err := Apply("a\nb\n", true, files[0])
+	require.NoError(t, err)
+	original, err := Apply(patched, true, reversed)
+	require.NoError(t, err)
+	assert.Equal(t, "a\nb\n", original)
+}
+
+func TestFile_ReverseNewFileIsDeletion(t *testing.T) {
+	reversed := File{NewPath: "auth.go", IsNew: true}.Reverse()
+
+	assert.True(t, reversed.IsDeleted)
+	assert.Equal(t, "auth.go", reversed.Path())
+}
@@ -0,0 +1,60 @@
+package patch
+
+import (
+	"fmt"
+	"strings"
+)
+
+type Section struct {
+	Path string
+	Data []byte
+}
+
+// Split cuts a patch into one section per file diff. Text before the first
+// diff --git line, such as mail headers, is left out unless it holds a diff
+// of its own, which is an error, as is a section that does not parse.
+func Split(data []byte) ([]Section, error) {
+	lines := strings.SplitAfter(string(data), "\n")
+	var sections []Section
+	var current []string
+	preamble := true
+
+	flush := func() error {
+		if len(current) == 0 {
+			return nil
+		}
+		text := strings.Join(current, "")
+		current = nil
+		files, err := Parse([]byte(text))
+		if preamble {
+			if err != nil || len(files) > 0 {
+				return fmt.Errorf("diff before the first \"diff --git\" line cannot be split; add git headers or remove it")
+			}
+			return nil
+		}
+		if err != nil {
+			return fmt.Errorf("section %d: %w", len(sections)+1, err)
+		}
+		if len(files) == 0 {
+			return fmt.Errorf("section %d: no file diff found", len(sections)+1)
+		}
+		sections = append(sections, Section{Path: files[0].Path(), Data: []byte(text)})
+		return nil
+	}
+
+	for _, line := range lines {
+		if strings.HasPrefix(line, "diff --git ") {
+			if err := flush(); err != nil {
+				return nil, err
+			}
+			preamble = false
+		}
+		if line != "" {
+			current = append(current, line)
+		}
+	}
+	if err := flush(); err != nil {
+		return nil, err
+	}
+	return sections, nil
+}
@@ -0,0 +1,48 @@
+package patch
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSplit_OneSectionPerFile(t *testing.T) {
+	first := "diff --git a/auth.txt b/auth.txt\n" +
+		"new file mode 100644\n" +
+		"--- /dev/null\n" +
+		"+++ b/auth.txt\n" +
+		"@@ -0,0 +1 @@\n" +
+		"+auth feature\n"
+	second := "diff --git a/main.go b/main.go\n" +
+		"--- a/main.go\n" +
+		"+++ b/main.go\n" +
+		"@@ -1 +1 @@\n" +
+		"-old\n" +
+		"+new\n"
+
+	sections, err := Split([]byte("From: someone\nSubject: mail header\n\n" + first + second))
+	require.NoError(t, err)
+
+	require.Len(t, sections, 2)
+	assert.Equal(t, Section{Path: "auth.txt", Data: []byte(first)}, sections[0])
+	assert.Equal(t, Section{Path: "main.go", Data: []byte(second)}, sections[1])
+}
+
+func TestSplit_NoDiffs(t *testing.T) {
+	sections, err := Split([]byte("patch content"))
+	require.NoError(t, err)
+	assert.Empty(t, sections)
+}
+
+func TestSplit_RejectsSectionThatDoesNotParse(t *testing.T) {
+	_, err := Split([]byte("diff --git a/main.go b/main.go\n--- a/main.go\n+++ b/main.go\n@@ bogus @@\n"))
+
+	assert.ErrorContains(t, err, "section 1: ")
+}
+
+func TestSplit_RejectsDiffBeforeFirstGitHeader(t *testing.T) {
+	_, err := Split([]byte("--- a/old.go\n+++ b/old.go\n@@ -1 +1 @@\n-a\n+b\ndiff --git a/main.go b/main.go\n--- a/main.go\n+++ b/main.go\n@@ -1 +1 @@\n-old\n+new\n"))
+
+	assert.EqualError(t, err, `diff before the first "diff --git" line cannot be split; add git headers or remove it`)
+}
@@ -0,0 +1,64 @@
+package template
+
+import (
+	"errors"
+
+	"templater/internal/fs"
+)
+
+func AnnotateFeatures(fileSystem fs.FileSystem, templatePath, targetPath string, features []string) (map[string]Annotation, error) {
+	var r *resolver
+	if targetPath != "" {
+		var err error
+		if r, err = newResolver(fileSystem, templatePath, targetPath); err != nil {
+			return nil, err
+		}
+	}
+
+	annotations := make(map[string]Annotation, len(features))
+	for _, feature := range features {
+		manifest, err := ReadManifest(fileSystem, templatePath, feature)
+		if err != nil {
+			return nil, err
+		}
+		files, err := readFeaturePatch(fileSystem, templatePath, feature)
+		if err != nil {
+			return nil, err
+		}
+
+		touched := touchedFiles(files)
+		annotation := Annotation{Description: manifest.Description, Files: len(touched)}
+		for _, f := range touched {
+			annotation.Lines += f.Added + f.Removed
+		}
+
+		if targetPath != "" {
+			annotation.Status, err = featureStatus(r, feature)
+			if err != nil {
+				return nil, err
+			}
+		}
+		annotations[feature] = annotation
+	}
+	return annotations, nil
+}
+
+func featureStatus(r *resolver, feature string) (FeatureStatus, error) {
+	if _, ok := r.applied[feature]; ok {
+		return StatusApplied, nil
+	}
+
+	resolved, err := r.resolve([]string{feature}, nil)
+	if err == nil {
+		_, err = preview(r.fileSystem, r.targetPath, resolved, nil)
+	}
+	var conflict *PatchConflictError
+	var overlap *OverlapError
+	switch {
+	case errors.As(err, &conflict), errors.As(err, &overlap):
+		return StatusConflicting, nil
+	case err != nil:
+		return StatusUnknown, err
+	}
+	return StatusAvailable, nil
+}
@@ -0,0 +1,52 @@
+package template
+
+import (
+	"testing"
+
+	"templater/internal/testutil/fs"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAnnotateFeatures_DescriptionAndSize(t *testing.T) {
+	memfs := fs.NewMemoryFS()
+	memfs.AddDir("templates")
+	memfs.AddDir("templates/auth")
+	memfs.AddFile("templates/auth/base.patch", []byte(conflictingPatch))
+	memfs.AddFile("templates/auth/feature.yml", []byte("description: User accounts\n"))
+
+	annotations, err := AnnotateFeatures(memfs, "templates", "", []string{"auth"})
+	require.NoError(t, err)
+
+	assert.Equal(t, map[string]Annotation{
+		"auth": {Description: "User accounts", Files: 1, Lines: 2},
+	}, annotations)
+}
+
+func TestAnnotateFeatures_StatusAgainstTarget(t *testing.T) {
+	memfs := fs.NewMemoryFS()
+	memfs.AddDir("templates")
+	memfs.AddDir("templates/auth")
+	memfs.AddDir("templates/billing")
+	memfs.AddDir("templates/database")
+	memfs.AddFile("templates/auth/base.patch", []byte(newAuthFilePatch))
+	memfs.AddFile("templates/billing/base.patch", []byte(conflictingPatch))
+	memfs.AddFile("templates/database/base.patch", []byte(modifyMissingFilePatch))
+	memfs.AddDir("templates/cache")
+	memfs.AddFile("templates/cache/base.patch", []byte(newAuthFilePatch))
+	memfs.AddDir("templates/cache/merge")
+	memfs.AddFile("templates/cache/merge/config.json", []byte(`{"port": 2}`))
+	memfs.AddDir("project")
+	memfs.AddFile("project/file.txt", []byte("a\nb\nc\n"))
+	memfs.AddFile("project/config.json", []byte(`{"port": 1}`))
+	memfs.AddFile("project/.templater/applied.yml", []byte("applied:\n  - auth\n"))
+
+	annotations, err := AnnotateFeatures(memfs, "templates", "project", []string{"auth", "billing", "cache", "database"})
+	require.NoError(t, err)
+
+	assert.Equal(t, StatusApplied, annotations["auth"].Status)
+	assert.Equal(t, StatusConflicting, annotations["billing"].Status)
+	assert.Equal(t, StatusConflicting, annotations["cache"].Status)
+	assert.Equal(t, StatusConflicting, annotations["database"].Status)
+}
@@ -1,6 +1,7 @@
 package template
 
 import (
+	"fmt"
 	"os"
 	"path"
 	"sort"
@@ -10,15 +11,36 @@ import (
 	"gopkg.in/yaml.v3"
 )
 
+func appliedPath(targetPath string) string {
+	return path.Join(targetPath, ".templater/applied.yml")
+}
+
+// appliedYml records the version of the primary template repository as
+// template_version and those of named sources under source_versions.
 type appliedYml struct {
-	Applied []string `yaml:"applied"`
+	Applied         []string          `yaml:"applied"`
+	TemplateVersion string            `yaml:"template_version,omitempty"`
+	SourceVersions  map[string]string `yaml:"source_versions,omitempty"`
 }
 
-func ReadApplied(fileSystem fs.FileSystem, targetPath string) ([]string, error) {
-	data, err := fileSystem.ReadFile(path.Join(targetPath, ".templater/applied.yml"))
+// versions returns the recorded template versions keyed by source name, with
+// "" for the primary template repository.
+func (a *appliedYml) versions() map[string]string {
+	versions := make(map[string]string, len(a.SourceVersions)+1)
+	for source, version := range a.SourceVersions {
+		versions[source] = version
+	}
+	if a.TemplateVersion != "" {
+		versions[""] = a.TemplateVersion
+	}
+	return versions
+}
+
+func readAppliedYml(fileSystem fs.FileSystem, targetPath string) (*appliedYml, error) {
+	data, err := fileSystem.ReadFile(appliedPath(targetPath))
 	if err != nil {
 		if os.IsNotExist(err) {
-			return nil, nil
+			return &appliedYml{}, nil
 		}
 		return nil, err
 	}
@@ -27,19 +49,91 @@ func ReadApplied(fileSystem fs.FileSystem, targetPath string) ([]string, error)
 	if err := yaml.Unmarshal(data, &applied); err != nil {
 		return nil, err
 	}
+	return &applied, nil
+}
 
+func ReadApplied(fileSystem fs.FileSystem, targetPath string) ([]string, error) {
+	applied, err := readAppliedYml(fileSystem, targetPath)
+	if err != nil {
+		return nil, err
+	}
 	return applied.Applied, nil
 }
 
+// ReadTemplateVersions returns the template versions recorded in applied.yml
+// keyed by source name, with "" for the primary template repository.
+func ReadTemplateVersions(fileSystem fs.FileSystem, targetPath string) (map[string]string, error) {
+	applied, err := readAppliedYml(fileSystem, targetPath)
+	if err != nil {
+		return nil, err
+	}
+	return applied.versions(), nil
+}
+
 func WriteApplied(fileSystem fs.FileSystem, targetPath string, features []string) error {
+	return writeApplied(fileSystem, targetPath, features, nil)
+}
+
+func writeApplied(fileSystem fs.FileSystem, targetPath string, features []string, templateVersions map[string]string) error {
+	previous, err := readAppliedYml(fileSystem, targetPath)
+	if err != nil {
+		return err
+	}
+	versions := previous.versions()
+	for source, version := range templateVersions {
+		versions[source] = version
+	}
+
 	sorted := make([]string, len(features))
 	copy(sorted, features)
 	sort.Strings(sorted)
 
-	data, err := yaml.Marshal(appliedYml{Applied: sorted})
+	doc := appliedYml{Applied: sorted, TemplateVersion: versions[""]}
+	delete(versions, "")
+	if len(versions) > 0 {
+		doc.SourceVersions = versions
+	}
+	data, err := yaml.Marshal(doc)
 	if err != nil {
 		return err
 	}
 
-	return fileSystem.WriteFile(path.Join(targetPath, ".templater/applied.yml"), data)
+	return fileSystem.WriteFile(appliedPath(targetPath), data)
+}
+
+func readAppliedRefs(fileSystem fs.FileSystem, targetPath string) (map[string]FeatureRef, error) {
+	applied, err := ReadApplied(fileSystem, targetPath)
+	if err != nil {
+		return nil, err
+	}
+
+	refs := make(map[string]FeatureRef, len(applied))
+	for _, entry := range applied {
+		ref, err := ParseFeatureRef(entry)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", appliedPath(targetPath), err)
+		}
+		refs[ref.Name] = FeatureRef{Name: ref.Name, Variants: refs[ref.Name].Variants}.with(ref)
+	}
+	return refs, nil
+}
+
+func RecordApplied(fileSystem fs.FileSystem, targetPath string, features []string, templateVersions map[string]string) error {
+	refs, err := readAppliedRefs(fileSystem, targetPath)
+	if err != nil {
+		return err
+	}
+	for _, feature := range features {
+		ref, err := ParseFeatureRef(feature)
+		if err != nil {
+			return err
+		}
+		refs[ref.Name] = FeatureRef{Name: ref.Name, Variants: refs[ref.Name].Variants}.with(ref)
+	}
+
+	merged := make([]string, 0, len(refs))
+	for _, ref := range refs {
+		merged = append(merged, ref.String())
+	}
+	return writeApplied(fileSystem, targetPath, merged, templateVersions)
 }
@@ -92,3 +92,16 @@ func TestWriteApplied_SortsAlphabetically(t *testing.T) {
 			"    - database\n",
 		string(data))
 }
+
+func TestRecordApplied_MergesWithExistingEntries(t *testing.T) {
+	memfs := fs.NewMemoryFS()
+	memfs.AddDir("project")
+	memfs.AddFile("project/.templater/applied.yml", []byte("applied:\n  - auth\n  - ci/github[cache]\n"))
+
+	err := RecordApplied(memfs, "project", []string{"ci/github[cache,matrix]", "database"}, nil)
+	require.NoError(t, err)
+
+	applied, err := ReadApplied(memfs, "project")
+	require.NoError(t, err)
+	assert.Equal(t, []string{"auth", "ci/github[cache,matrix]", "database"}, applied)
+}
@@ -1,66 +1,308 @@
 package template
 
 import (
+	"context"
+	"errors"
 	"fmt"
-	"path"
+	"time"
 
 	"templater/internal/executor"
 	"templater/internal/fs"
 )
 
 type ApplyResult struct {
-	Applied        []string
-	AlreadyApplied []string
+	Applied          []string
+	AlreadyApplied   []string
+	StashKept        bool
+	TemplateVersions map[string]string
 }
 
 type DryRunResult struct {
 	WouldApply     []string
 	AlreadyApplied []string
+	Overlaps       []Overlap
+}
+
+const DefaultTimeout = "30s"
+
+type ApplyOptions struct {
+	Progress   ProgressFunc
+	Timeout    string
+	Vars       map[string]map[string]string
+	Commit     bool
+	AllowDirty bool
+	Stash      bool
 }
 
 type resolvedFeatures struct {
 	toApply        []string
 	alreadyApplied []string
+	previous       map[string]FeatureRef
+	overlaps       []Overlap
+	sources        Sources
+	versions       map[string]string
 }
 
-func ApplyFeature(fileSystem fs.FileSystem, exec executor.Executor, templatePath, targetPath, feature string) error {
-	patchPath := path.Join(templatePath, feature, "base.patch")
-	cmd := fmt.Sprintf("git apply --unsafe-paths --directory=%s %s", targetPath, patchPath)
+type applier struct {
+	fileSystem   fs.FileSystem
+	exec         executor.Executor
+	templatePath string
+	sources      Sources
+	targetPath   string
+	versions     map[string]string
+	opts         ApplyOptions
+	timeouts     map[string]string
+	previous     map[string]FeatureRef
+	commits      *committer
+	stashed      bool
+	dirty        bool
+	created      map[string][]string
+	backups      map[string][]fileBackup
+	metadata     map[string][]byte
+}
 
-	_, stderr, exitCode, err := exec.Execute(cmd, "30s", nil)
+func newApplier(fileSystem fs.FileSystem, exec executor.Executor, templatePath, targetPath string, features []FeatureRef, opts ApplyOptions) (*applier, error) {
+	if opts.Timeout == "" {
+		opts.Timeout = DefaultTimeout
+	}
+	if _, err := executor.ParseTimeout(opts.Timeout); err != nil {
+		return nil, err
+	}
+
+	sources, err := ReadSources(fileSystem, templatePath, targetPath)
+	if err != nil {
+		return nil, err
+	}
+
+	a := &applier{
+		fileSystem:   fileSystem,
+		exec:         exec,
+		templatePath: templatePath,
+		sources:      sources,
+		targetPath:   targetPath,
+		opts:         opts,
+		timeouts:     make(map[string]string),
+		created:      make(map[string][]string),
+		backups:      make(map[string][]fileBackup),
+	}
+	for _, ref := range features {
+		root, local := sources.locate(ref)
+		manifest, err := ReadManifest(fileSystem, root, local.Name)
+		if err != nil {
+			return nil, err
+		}
+		if manifest.Timeout == "" {
+			continue
+		}
+		if _, err := executor.ParseTimeout(manifest.Timeout); err != nil {
+			return nil, fmt.Errorf("%s: %w", manifestPath(root, local.Name), err)
+		}
+		a.timeouts[ref.Name] = manifest.Timeout
+	}
+	return a, nil
+}
+
+func (a *applier) timeout(ref FeatureRef) string {
+	if timeout, ok := a.timeouts[ref.Name]; ok {
+		return timeout
+	}
+	return a.opts.Timeout
+}
+
+func ApplyFeature(ctx context.Context, fileSystem fs.FileSystem, exec executor.Executor, templatePath, targetPath, feature string) error {
+	ref, err := ParseFeatureRef(feature)
 	if err != nil {
 		return err
 	}
-	if exitCode != 0 {
-		return fmt.Errorf("failed to apply %s: %s", feature, stderr)
+	a, err := newApplier(fileSystem, exec, templatePath, targetPath, []FeatureRef{ref}, ApplyOptions{})
+	if err != nil {
+		return err
+	}
+	return a.apply(ctx, ref, nil)
+}
+
+func (a *applier) apply(ctx context.Context, ref FeatureRef, output executor.OutputFunc) error {
+	patches, err := a.patches(ref)
+	if err != nil {
+		return err
+	}
+
+	for i, patchPath := range patches {
+		if err := a.applyPatch(ctx, ref, patchPath, output); err != nil {
+			a.reversePatches(context.WithoutCancel(ctx), ref, patches[:i])
+			return err
+		}
+	}
+
+	cached, err := a.cachePatches(ref, patches)
+	if err != nil {
+		a.reverse(context.WithoutCancel(ctx), ref)
+		return err
+	}
+
+	files, err := a.copyOverlay(ref)
+	if err != nil {
+		a.reverse(context.WithoutCancel(ctx), ref)
+		return err
 	}
 
+	merges, err := a.applyMerges(ref)
+	if err != nil {
+		a.reverse(context.WithoutCancel(ctx), ref)
+		return err
+	}
+
+	injections, err := a.applyInjections(ref)
+	if err != nil {
+		a.reverse(context.WithoutCancel(ctx), ref)
+		return err
+	}
+
+	if err := a.recordState(State{Patches: cached, Files: files, Merges: merges, Injections: injections}); err != nil {
+		a.reverse(context.WithoutCancel(ctx), ref)
+		return err
+	}
+	return nil
+}
+
+func (a *applier) patches(ref FeatureRef) ([]string, error) {
+	_, baseApplied := a.previous[ref.Name]
+	templatePath, local := a.sources.locate(ref)
+	return refPatches(a.fileSystem, templatePath, local, baseApplied)
+}
+
+func (a *applier) applyPatch(ctx context.Context, ref FeatureRef, patchPath string, output executor.OutputFunc) error {
+	cmd := fmt.Sprintf("git apply --unsafe-paths --directory=%s %s", shellQuote(a.targetPath), shellQuote(patchPath))
+	timeout := a.timeout(ref)
+
+	_, stderr, exitCode, err := a.exec.ExecuteStreaming(ctx, cmd, timeout, nil, output)
+	if errors.Is(err, executor.ErrTimeout) {
+		return &TimeoutError{Feature: ref.String(), Command: cmd, Timeout: timeout}
+	}
+	if err != nil {
+		return err
+	}
+	if exitCode != 0 {
+		return newPatchConflictError(a.fileSystem, ref.String(), a.targetPath, patchPath, stderr)
+	}
 	return nil
 }
 
-func ApplyFeatures(fileSystem fs.FileSystem, exec executor.Executor, templatePath, targetPath string, features []string) (*ApplyResult, error) {
-	resolved, err := resolveFeatures(fileSystem, templatePath, targetPath, features)
+func ApplyFeatures(ctx context.Context, fileSystem fs.FileSystem, exec executor.Executor, templatePath, targetPath string, features []string, opts ApplyOptions) (*ApplyResult, error) {
+	resolved, err := resolveFeatures(fileSystem, templatePath, targetPath, features, opts.Vars)
 	if err != nil {
 		return nil, err
 	}
 
-	var applied []string
-	for _, feature := range resolved.toApply {
-		if err := ApplyFeature(fileSystem, exec, templatePath, targetPath, feature); err != nil {
-			rollback(exec, templatePath, targetPath, applied)
+	refs, err := parseFeatureRefs(resolved.toApply)
+	if err != nil {
+		return nil, err
+	}
+	a, err := newApplier(fileSystem, exec, templatePath, targetPath, refs, opts)
+	if err != nil {
+		return nil, err
+	}
+	a.previous = resolved.previous
+	a.versions = resolved.versions
+
+	if !opts.Stash {
+		if err := CheckOverlaps(resolved.overlaps); err != nil {
 			return nil, err
 		}
-		applied = append(applied, feature)
 	}
+	if err := a.preflight(ctx, refs); err != nil {
+		return nil, err
+	}
+	result, err := a.applyChecked(ctx, refs, resolved)
+	if a.stashed {
+		if unstashErr := a.unstash(context.WithoutCancel(ctx)); unstashErr != nil {
+			if err != nil {
+				return nil, fmt.Errorf("%w (restoring stashed changes also failed; they are kept in the stash: %v)", err, unstashErr)
+			}
+			result.StashKept = true
+		}
+	}
+	return result, err
+}
 
-	return &ApplyResult{
-		Applied:        applied,
-		AlreadyApplied: resolved.alreadyApplied,
-	}, nil
+// applyChecked checks for overlaps with --stash only once the stash has
+// cleared the files in the way, then applies.
+func (a *applier) applyChecked(ctx context.Context, refs []FeatureRef, resolved *resolvedFeatures) (*ApplyResult, error) {
+	if a.opts.Stash {
+		overlaps := resolved.overlaps
+		if a.stashed {
+			var err error
+			overlaps, err = analyzeOverlaps(a.fileSystem, a.sources, a.targetPath, refs, resolved.previous, a.opts.Vars)
+			if err != nil {
+				return nil, err
+			}
+		}
+		if err := CheckOverlaps(overlaps); err != nil {
+			return nil, err
+		}
+	}
+	return a.applyAll(ctx, refs, resolved)
+}
+
+func (a *applier) applyAll(ctx context.Context, refs []FeatureRef, resolved *resolvedFeatures) (*ApplyResult, error) {
+	if err := a.snapshotMetadata(); err != nil {
+		return nil, err
+	}
+	if a.opts.Commit {
+		commits, err := a.prepareCommits(ctx, refs)
+		if err != nil {
+			return nil, err
+		}
+		a.commits = commits
+	}
+
+	for _, feature := range resolved.toApply {
+		a.opts.report(ProgressEvent{Feature: feature, State: FeaturePending})
+	}
+
+	var applied []FeatureRef
+	for _, ref := range refs {
+		feature := ref.String()
+		if err := ctx.Err(); err != nil {
+			return nil, a.rollback(context.WithoutCancel(ctx), applied, fmt.Errorf("apply interrupted: %w", err))
+		}
+
+		start := time.Now()
+		a.opts.report(ProgressEvent{Feature: feature, State: FeatureRunning})
+		output := func(stream executor.Stream, line string) {
+			a.opts.report(ProgressEvent{Feature: feature, State: FeatureRunning, Elapsed: time.Since(start), Output: line})
+		}
+
+		if err := a.apply(ctx, ref, output); err != nil {
+			a.opts.report(ProgressEvent{Feature: feature, State: FeatureFailed, Elapsed: time.Since(start)})
+			if ctx.Err() != nil {
+				err = fmt.Errorf("apply interrupted: %w", ctx.Err())
+			}
+			return nil, a.rollback(context.WithoutCancel(ctx), applied, err)
+		}
+
+		applied = append(applied, ref)
+		if a.commits != nil {
+			if err := a.commit(ctx, ref); err != nil {
+				a.opts.report(ProgressEvent{Feature: feature, State: FeatureFailed, Elapsed: time.Since(start)})
+				return nil, a.rollback(context.WithoutCancel(ctx), applied, err)
+			}
+		}
+		a.opts.report(ProgressEvent{Feature: feature, State: FeatureDone, Elapsed: time.Since(start)})
+	}
+
+	result := &ApplyResult{AlreadyApplied: resolved.alreadyApplied, TemplateVersions: a.versions}
+	for _, ref := range applied {
+		if previous, ok := resolved.previous[ref.Name]; ok {
+			ref = previous.with(ref)
+		}
+		result.Applied = append(result.Applied, ref.String())
+	}
+	return result, nil
 }
 
-func DryRun(fileSystem fs.FileSystem, templatePath, targetPath string, features []string) (*DryRunResult, error) {
-	resolved, err := resolveFeatures(fileSystem, templatePath, targetPath, features)
+func DryRun(fileSystem fs.FileSystem, templatePath, targetPath string, features []string, vars map[string]map[string]string) (*DryRunResult, error) {
+	resolved, err := resolveFeatures(fileSystem, templatePath, targetPath, features, vars)
 	if err != nil {
 		return nil, err
 	}
@@ -68,60 +310,179 @@ func DryRun(fileSystem fs.FileSystem, templatePath, targetPath string, features
 	return &DryRunResult{
 		WouldApply:     resolved.toApply,
 		AlreadyApplied: resolved.alreadyApplied,
+		Overlaps:       resolved.overlaps,
 	}, nil
 }
 
-func resolveFeatures(fileSystem fs.FileSystem, templatePath, targetPath string, features []string) (*resolvedFeatures, error) {
-	available, err := ListFeatures(fileSystem, templatePath)
+func resolveFeatures(fileSystem fs.FileSystem, templatePath, targetPath string, features []string, vars map[string]map[string]string) (*resolvedFeatures, error) {
+	r, err := newResolver(fileSystem, templatePath, targetPath)
 	if err != nil {
 		return nil, err
 	}
+	return r.resolve(features, vars)
+}
 
-	alreadyApplied, err := ReadApplied(fileSystem, targetPath)
+// resolver holds what resolving features reads once per template and target,
+// so that several feature sets can be resolved against them.
+type resolver struct {
+	fileSystem fs.FileSystem
+	targetPath string
+	sources    Sources
+	applied    map[string]FeatureRef
+	catalog    *catalog
+}
+
+func newResolver(fileSystem fs.FileSystem, templatePath, targetPath string) (*resolver, error) {
+	sources, err := ReadSources(fileSystem, templatePath, targetPath)
+	if err != nil {
+		return nil, err
+	}
+	applied, err := readAppliedRefs(fileSystem, targetPath)
 	if err != nil {
 		return nil, err
 	}
-	alreadySet := toSet(alreadyApplied)
+	return &resolver{
+		fileSystem: fileSystem,
+		targetPath: targetPath,
+		sources:    sources,
+		applied:    applied,
+		catalog:    newCatalog(fileSystem, sources),
+	}, nil
+}
 
-	hasRoot := hasRootPatch(fileSystem, templatePath)
+func (r *resolver) resolve(features []string, vars map[string]map[string]string) (*resolvedFeatures, error) {
+	requested, err := parseFeatureRefs(features)
+	if err != nil {
+		return nil, err
+	}
 
-	result := &resolvedFeatures{}
-	seen := make(map[string]bool)
+	var chain []FeatureRef
+	index := make(map[string]int)
 
-	for _, feature := range features {
-		deps := ResolveDependencies(feature, available, hasRoot)
-		if len(deps) == 0 {
-			return nil, fmt.Errorf("feature not found: %s", feature)
+	for _, ref := range requested {
+		deps, err := r.catalog.dependencies(ref.Name, nil)
+		if err != nil {
+			return nil, err
+		}
+		if err := checkVariants(r.fileSystem, r.sources, ref); err != nil {
+			return nil, err
 		}
 		for _, dep := range deps {
-			if seen[dep] {
-				continue
+			depRef := FeatureRef{Name: dep}
+			if dep == ref.Name {
+				depRef = ref
 			}
-			seen[dep] = true
-			if alreadySet[dep] {
-				result.alreadyApplied = append(result.alreadyApplied, dep)
-			} else {
-				result.toApply = append(result.toApply, dep)
+			if i, ok := index[dep]; ok {
+				chain[i] = chain[i].with(depRef)
+				continue
 			}
+			index[dep] = len(chain)
+			chain = append(chain, depRef)
 		}
 	}
 
+	result := &resolvedFeatures{previous: make(map[string]FeatureRef), sources: r.sources, versions: make(map[string]string)}
+	for _, ref := range chain {
+		applied, ok := r.applied[ref.Name]
+		if !ok {
+			result.toApply = append(result.toApply, ref.String())
+			continue
+		}
+		missing := ref.without(applied)
+		if len(missing.Variants) == 0 {
+			result.alreadyApplied = append(result.alreadyApplied, applied.String())
+			continue
+		}
+		result.previous[ref.Name] = applied
+		result.toApply = append(result.toApply, missing.String())
+	}
+
+	refs, err := parseFeatureRefs(result.toApply)
+	if err != nil {
+		return nil, err
+	}
+	for _, ref := range refs {
+		source, _ := splitSource(ref.Name)
+		config, err := r.catalog.config(source)
+		if err != nil {
+			return nil, err
+		}
+		if config.Version != "" {
+			result.versions[source] = config.Version
+		}
+	}
+	result.overlaps, err = analyzeOverlaps(r.fileSystem, r.sources, r.targetPath, refs, result.previous, vars)
+	if err != nil {
+		return nil, err
+	}
+
 	return result, nil
 }
 
-func rollback(exec executor.Executor, templatePath, targetPath string, applied []string) {
+func (a *applier) rollback(ctx context.Context, applied []FeatureRef, cause error) error {
+	var failed []string
 	for i := len(applied) - 1; i >= 0; i-- {
-		reverseFeature(exec, templatePath, targetPath, applied[i])
+		if err := a.reverse(ctx, applied[i]); err != nil {
+			failed = append(failed, applied[i].String())
+			continue
+		}
+		a.opts.report(ProgressEvent{Feature: applied[i].String(), State: FeatureRolledBack})
+	}
+	if err := a.restoreMetadata(); err != nil {
+		failed = append(failed, ".templater")
+	}
+	if a.commits != nil && len(applied) > 0 {
+		if err := a.commits.reset(ctx); err != nil {
+			failed = append(failed, "git history")
+		}
+	}
+	if len(failed) > 0 {
+		return &RollbackError{Cause: cause, Failed: failed}
+	}
+	return cause
+}
+
+func (a *applier) reverse(ctx context.Context, ref FeatureRef) error {
+	if err := a.restoreBackups(ref); err != nil {
+		return err
 	}
+	if err := a.removeOverlay(ref); err != nil {
+		return err
+	}
+	patches, err := a.patches(ref)
+	if err != nil {
+		return err
+	}
+	return a.reversePatches(ctx, ref, patches)
 }
 
-func reverseFeature(exec executor.Executor, templatePath, targetPath, feature string) {
-	patchPath := path.Join(templatePath, feature, "base.patch")
-	cmd := fmt.Sprintf("git apply --unsafe-paths --reverse --directory=%s %s", targetPath, patchPath)
-	exec.Execute(cmd, "30s", nil)
+func (a *applier) reversePatches(ctx context.Context, ref FeatureRef, patches []string) error {
+	for i := len(patches) - 1; i >= 0; i-- {
+		if err := a.reversePatch(ctx, ref, patches[i]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (a *applier) reversePatch(ctx context.Context, ref FeatureRef, patchPath string) error {
+	cmd := fmt.Sprintf("git apply --unsafe-paths --reverse --directory=%s %s", shellQuote(a.targetPath), shellQuote(patchPath))
+	timeout := a.timeout(ref)
+
+	_, stderr, exitCode, err := a.exec.Execute(ctx, cmd, timeout, nil)
+	if errors.Is(err, executor.ErrTimeout) {
+		return &TimeoutError{Feature: ref.String(), Command: cmd, Timeout: timeout}
+	}
+	if err != nil {
+		return err
+	}
+	if exitCode != 0 {
+		return fmt.Errorf("failed to reverse %s: %s", ref, stderr)
+	}
+	return nil
 }
 
 func hasRootPatch(fileSystem fs.FileSystem, templatePath string) bool {
-	_, err := fileSystem.Stat(path.Join(templatePath, "base.patch"))
-	return err == nil
+	patches, err := featurePatches(fileSystem, templatePath, "")
+	return err == nil && len(patches) > 0
 }
@@ -0,0 +1,113 @@
+package template
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	executorpkg "templater/internal/executor"
+	"templater/internal/fs"
+	"templater/internal/testutil/executor"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const newAuthFilePatch = "diff --git a/auth.txt b/auth.txt\n" +
+	"new file mode 100644\n" +
+	"--- /dev/null\n" +
+	"+++ b/auth.txt\n" +
+	"@@ -0,0 +1 @@\n" +
+	"+auth feature\n"
+
+const modifyAuthFilePatch = "diff --git a/auth.txt b/auth.txt\n" +
+	"--- a/auth.txt\n" +
+	"+++ b/auth.txt\n" +
+	"@@ -1 +1,2 @@\n" +
+	" auth feature\n" +
+	"+oauth provider\n"
+
+const modifyMissingFilePatch = "diff --git a/nonexistent.txt b/nonexistent.txt\n" +
+	"--- a/nonexistent.txt\n" +
+	"+++ b/nonexistent.txt\n" +
+	"@@ -1 +1 @@\n" +
+	"-old content\n" +
+	"+new content\n"
+
+const conflictingAuthFilePatch = "diff --git a/auth.txt b/auth.txt\n" +
+	"--- a/auth.txt\n" +
+	"+++ b/auth.txt\n" +
+	"@@ -1 +1 @@\n" +
+	"-basic auth\n" +
+	"+token auth\n"
+
+func writeTree(t *testing.T, root string, files map[string]string) {
+	t.Helper()
+	for name, content := range files {
+		filePath := filepath.Join(root, name)
+		require.NoError(t, os.MkdirAll(filepath.Dir(filePath), 0755))
+		require.NoError(t, os.WriteFile(filePath, []byte(content), 0644))
+	}
+}
+
+func scenario(t *testing.T, golden string, files map[string]string) (templatePath, targetPath string, exec executorpkg.Executor) {
+	t.Helper()
+	root := t.TempDir()
+	writeTree(t, root, files)
+	templatePath = filepath.Join(root, "templates")
+	targetPath = filepath.Join(root, "project")
+	require.NoError(t, os.MkdirAll(targetPath, 0755))
+
+	exec = executor.Golden(t, filepath.Join("testdata", golden), map[string]string{
+		"$TEMPLATES": templatePath,
+		"$TARGET":    targetPath,
+	})
+	return templatePath, targetPath, exec
+}
+
+// scenarioCommands lists the commands a scenario ran, whether they were
+// replayed or are being recorded.
+func scenarioCommands(exec executorpkg.Executor) []string {
+	var executed []executor.ExecutedCommand
+	switch e := exec.(type) {
+	case *executor.ReplayExecutor:
+		executed = e.Commands
+	case *executor.RecordingExecutor:
+		executed = e.Commands
+	}
+	commands := make([]string, len(executed))
+	for i, c := range executed {
+		commands[i] = c.Command
+	}
+	return commands
+}
+
+func TestApplyFeatures_Scenario_RollsBackEarlierFeatureOnConflict(t *testing.T) {
+	templatePath, targetPath, exec := scenario(t, "rollback_on_conflict.yml", map[string]string{
+		"templates/auth/base.patch":     newAuthFilePatch,
+		"templates/database/base.patch": conflictingAuthFilePatch,
+	})
+
+	_, err := ApplyFeatures(context.Background(), fs.OSFileSystem{}, exec, templatePath, targetPath, []string{"auth", "database"}, ApplyOptions{})
+
+	var conflict *PatchConflictError
+	require.ErrorAs(t, err, &conflict)
+	assert.Equal(t, "database", conflict.Feature)
+	assert.Equal(t, "auth.txt", conflict.File)
+	assert.Equal(t, "patch does not apply", conflict.Reason)
+	commands := scenarioCommands(exec)
+	assert.Equal(t, reverseCommand(targetPath, filepath.Join(templatePath, "auth/base.patch")), commands[len(commands)-1])
+}
+
+func TestApplyFeatures_Scenario_AppliesNestedFeatures(t *testing.T) {
+	templatePath, targetPath, exec := scenario(t, "nested_features.yml", map[string]string{
+		"templates/auth/base.patch":       newAuthFilePatch,
+		"templates/auth/oauth/base.patch": modifyAuthFilePatch,
+	})
+
+	result, err := ApplyFeatures(context.Background(), fs.OSFileSystem{}, exec, templatePath, targetPath, []string{"auth/oauth"}, ApplyOptions{})
+
+	require.NoError(t, err)
+	assert.Equal(t, []string{"auth", "auth/oauth"}, result.Applied)
+}
@@ -1,9 +1,11 @@
 package template
 
 import (
+	"context"
 	"fmt"
 	"testing"
 
+	executorpkg "templater/internal/executor"
 	"templater/internal/testutil/executor"
 	"templater/internal/testutil/fs"
 
@@ -28,7 +30,7 @@ func TestApplyFeature_ExecutesGitApply(t *testing.T) {
 
 	exec := &executor.FakeExecutor{}
 
-	err := ApplyFeature(memfs, exec, "templates", "project", "auth")
+	err := ApplyFeature(context.Background(), memfs, exec, "templates", "project", "auth")
 	require.NoError(t, err)
 
 	require.Len(t, exec.Commands, 1)
@@ -48,11 +50,123 @@ func TestApplyFeature_ReturnsErrorOnFailure(t *testing.T) {
 		Stderr:          "patch does not apply",
 	}
 
-	err := ApplyFeature(memfs, exec, "templates", "project", "auth")
+	err := ApplyFeature(context.Background(), memfs, exec, "templates", "project", "auth")
 	require.Error(t, err)
 	assert.Contains(t, err.Error(), "patch does not apply")
 }
 
+func TestApplyFeature_ReturnsPatchConflictError(t *testing.T) {
+	memfs := fs.NewMemoryFS()
+	memfs.AddFile("templates/auth/base.patch", []byte(conflictingPatch))
+	memfs.AddFile("project/file.txt", []byte("a\nb\nc\n"))
+
+	exec := &executor.FakeExecutor{
+		DefaultExitCode: 1,
+		Stderr:          "error: patch failed: project/file.txt:1\nerror: project/file.txt: patch does not apply\n",
+	}
+
+	err := ApplyFeature(context.Background(), memfs, exec, "templates", "project", "auth")
+
+	var conflict *PatchConflictError
+	require.ErrorAs(t, err, &conflict)
+	assert.Equal(t, "auth", conflict.Feature)
+	assert.Equal(t, "templates/auth/base.patch", conflict.PatchPath)
+	assert.Equal(t, "file.txt", conflict.File)
+}
+
+func TestApplyFeature_ReturnsTimeoutError(t *testing.T) {
+	memfs := fs.NewMemoryFS()
+	memfs.AddFile("templates/auth/base.patch", []byte("patch content"))
+
+	exec := &executor.FakeExecutor{
+		TimeoutCommands: map[string]bool{
+			applyCommand("project", "templates/auth/base.patch"): true,
+		},
+	}
+
+	err := ApplyFeature(context.Background(), memfs, exec, "templates", "project", "auth")
+
+	var timeout *TimeoutError
+	require.ErrorAs(t, err, &timeout)
+	assert.Equal(t, "auth", timeout.Feature)
+	assert.ErrorIs(t, err, executorpkg.ErrTimeout)
+}
+
+func TestApplyFeatures_ReportsFailedRollback(t *testing.T) {
+	memfs := fs.NewMemoryFS()
+	memfs.AddDir("templates")
+	memfs.AddDir("templates/auth")
+	memfs.AddDir("templates/auth/oauth")
+	memfs.AddFile("templates/auth/base.patch", []byte("auth patch"))
+	memfs.AddFile("templates/auth/oauth/base.patch", []byte("oauth patch"))
+
+	exec := &executor.FakeExecutor{
+		ExitCodes: map[string]int{
+			applyCommand("project", "templates/auth/oauth/base.patch"): 1,
+			reverseCommand("project", "templates/auth/base.patch"):     1,
+		},
+		Stderr: "patch does not apply",
+	}
+
+	_, err := ApplyFeatures(context.Background(), memfs, exec, "templates", "project", []string{"auth/oauth"}, ApplyOptions{})
+
+	var rollbackErr *RollbackError
+	require.ErrorAs(t, err, &rollbackErr)
+	assert.Equal(t, []string{"auth"}, rollbackErr.Failed)
+	var conflict *PatchConflictError
+	assert.ErrorAs(t, err, &conflict)
+}
+
+func TestApplyFeatures_UsesConfiguredTimeout(t *testing.T) {
+	memfs := fs.NewMemoryFS()
+	memfs.AddDir("templates")
+	memfs.AddDir("templates/auth")
+	memfs.AddDir("templates/database")
+	memfs.AddFile("templates/auth/base.patch", []byte("auth patch"))
+	memfs.AddFile("templates/database/base.patch", []byte("database patch"))
+	memfs.AddFile("templates/database/feature.yml", []byte("timeout: 5m\n"))
+
+	exec := &executor.FakeExecutor{}
+
+	_, err := ApplyFeatures(context.Background(), memfs, exec, "templates", "project", []string{"auth", "database"}, ApplyOptions{Timeout: "45s"})
+	require.NoError(t, err)
+
+	require.Len(t, exec.Commands, 2)
+	assert.Equal(t, "45s", exec.Commands[0].Timeout)
+	assert.Equal(t, "5m", exec.Commands[1].Timeout)
+}
+
+func TestApplyFeatures_DefaultsTimeout(t *testing.T) {
+	memfs := fs.NewMemoryFS()
+	memfs.AddDir("templates")
+	memfs.AddDir("templates/auth")
+	memfs.AddFile("templates/auth/base.patch", []byte("auth patch"))
+
+	exec := &executor.FakeExecutor{}
+
+	_, err := ApplyFeatures(context.Background(), memfs, exec, "templates", "project", []string{"auth"}, ApplyOptions{})
+	require.NoError(t, err)
+
+	require.Len(t, exec.Commands, 1)
+	assert.Equal(t, DefaultTimeout, exec.Commands[0].Timeout)
+}
+
+func TestApplyFeatures_RejectsInvalidFeatureTimeoutBeforeApplying(t *testing.T) {
+	memfs := fs.NewMemoryFS()
+	memfs.AddDir("templates")
+	memfs.AddDir("templates/auth")
+	memfs.AddDir("templates/database")
+	memfs.AddFile("templates/auth/base.patch", []byte("auth patch"))
+	memfs.AddFile("templates/database/base.patch", []byte("database patch"))
+	memfs.AddFile("templates/database/feature.yml", []byte("timeout: a while\n"))
+
+	exec := &executor.FakeExecutor{}
+
+	_, err := ApplyFeatures(context.Background(), memfs, exec, "templates", "project", []string{"auth", "database"}, ApplyOptions{})
+	assert.EqualError(t, err, `templates/database/feature.yml: invalid timeout "a while": expected a duration such as 30s or 2m`)
+	assert.Empty(t, exec.Commands)
+}
+
 func TestApplyFeatures_AppliesDependenciesInOrder(t *testing.T) {
 	memfs := fs.NewMemoryFS()
 	memfs.AddDir("templates")
@@ -64,7 +178,7 @@ func TestApplyFeatures_AppliesDependenciesInOrder(t *testing.T) {
 
 	exec := &executor.FakeExecutor{}
 
-	result, err := ApplyFeatures(memfs, exec, "templates", "project", []string{"auth/oauth"})
+	result, err := ApplyFeatures(context.Background(), memfs, exec, "templates", "project", []string{"auth/oauth"}, ApplyOptions{})
 	require.NoError(t, err)
 
 	assert.Equal(t, []string{"auth", "auth/oauth"}, result.Applied)
@@ -85,7 +199,7 @@ func TestApplyFeatures_SkipsAlreadyApplied(t *testing.T) {
 
 	exec := &executor.FakeExecutor{}
 
-	result, err := ApplyFeatures(memfs, exec, "templates", "project", []string{"auth/oauth"})
+	result, err := ApplyFeatures(context.Background(), memfs, exec, "templates", "project", []string{"auth/oauth"}, ApplyOptions{})
 	require.NoError(t, err)
 
 	assert.Equal(t, []string{"auth/oauth"}, result.Applied)
@@ -109,7 +223,7 @@ func TestApplyFeatures_RollsBackOnFailure(t *testing.T) {
 		Stderr: "patch does not apply",
 	}
 
-	_, err := ApplyFeatures(memfs, exec, "templates", "project", []string{"auth/oauth"})
+	_, err := ApplyFeatures(context.Background(), memfs, exec, "templates", "project", []string{"auth/oauth"}, ApplyOptions{})
 	require.Error(t, err)
 
 	assert.Equal(t, 3, len(exec.Commands))
@@ -135,7 +249,7 @@ func TestApplyFeatures_RollsBackMultipleOnFailure(t *testing.T) {
 		Stderr: "patch does not apply",
 	}
 
-	_, err := ApplyFeatures(memfs, exec, "templates", "project", []string{"auth/oauth"})
+	_, err := ApplyFeatures(context.Background(), memfs, exec, "templates", "project", []string{"auth/oauth"}, ApplyOptions{})
 	require.Error(t, err)
 
 	assert.Equal(t, 5, len(exec.Commands))
@@ -148,6 +262,126 @@ func TestApplyFeatures_RollsBackMultipleOnFailure(t *testing.T) {
 	assert.Equal(t, expectedAuthCommand, authCommand.Command)
 }
 
+func TestApplyFeatures_RollsBackWhenInterrupted(t *testing.T) {
+	memfs := fs.NewMemoryFS()
+	memfs.AddDir("templates")
+	memfs.AddDir("templates/auth")
+	memfs.AddDir("templates/auth/oauth")
+	memfs.AddFile("templates/auth/base.patch", []byte("auth patch"))
+	memfs.AddFile("templates/auth/oauth/base.patch", []byte("oauth patch"))
+	memfs.AddDir("project")
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	exec := &executor.FakeExecutor{
+		OnExecute: func(command string) {
+			if command == applyCommand("project", "templates/auth/base.patch") {
+				cancel()
+			}
+		},
+	}
+
+	_, err := ApplyFeatures(ctx, memfs, exec, "templates", "project", []string{"auth/oauth"}, ApplyOptions{})
+	require.ErrorIs(t, err, context.Canceled)
+
+	require.Len(t, exec.Commands, 2)
+	assert.Equal(t, reverseCommand("project", "templates/auth/base.patch"), exec.Commands[1].Command)
+}
+
+func TestApplyFeatures_AppliesNothingWhenAlreadyCancelled(t *testing.T) {
+	memfs := fs.NewMemoryFS()
+	memfs.AddDir("templates")
+	memfs.AddDir("templates/auth")
+	memfs.AddFile("templates/auth/base.patch", []byte("auth patch"))
+	memfs.AddDir("project")
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	exec := &executor.FakeExecutor{}
+
+	_, err := ApplyFeatures(ctx, memfs, exec, "templates", "project", []string{"auth"}, ApplyOptions{})
+	require.ErrorIs(t, err, context.Canceled)
+
+	assert.Empty(t, exec.Commands)
+}
+
+type recordedEvent struct {
+	feature string
+	state   FeatureState
+}
+
+func recordProgress(events *[]recordedEvent) ApplyOptions {
+	return ApplyOptions{Progress: func(event ProgressEvent) {
+		if event.Output == "" {
+			*events = append(*events, recordedEvent{event.Feature, event.State})
+		}
+	}}
+}
+
+func TestApplyFeatures_ReportsProgress(t *testing.T) {
+	memfs := fs.NewMemoryFS()
+	memfs.AddDir("templates")
+	memfs.AddDir("templates/auth")
+	memfs.AddDir("templates/auth/oauth")
+	memfs.AddFile("templates/auth/base.patch", []byte("auth patch"))
+	memfs.AddFile("templates/auth/oauth/base.patch", []byte("oauth patch"))
+
+	exec := &executor.FakeExecutor{}
+	var events []recordedEvent
+
+	_, err := ApplyFeatures(context.Background(), memfs, exec, "templates", "project", []string{"auth/oauth"}, recordProgress(&events))
+	require.NoError(t, err)
+
+	assert.Equal(t, []recordedEvent{
+		{"auth", FeaturePending},
+		{"auth/oauth", FeaturePending},
+		{"auth", FeatureRunning},
+		{"auth", FeatureDone},
+		{"auth/oauth", FeatureRunning},
+		{"auth/oauth", FeatureDone},
+	}, events)
+}
+
+func TestApplyFeatures_ReportsFailureAndRollbackProgress(t *testing.T) {
+	memfs := fs.NewMemoryFS()
+	memfs.AddDir("templates")
+	memfs.AddDir("templates/auth")
+	memfs.AddDir("templates/auth/oauth")
+	memfs.AddFile("templates/auth/base.patch", []byte("auth patch"))
+	memfs.AddFile("templates/auth/oauth/base.patch", []byte("oauth patch"))
+
+	exec := &executor.FakeExecutor{
+		ExitCodes: map[string]int{
+			applyCommand("project", "templates/auth/oauth/base.patch"): 1,
+		},
+		Stderr: "patch does not apply",
+	}
+	var events []recordedEvent
+	var output []string
+	opts := recordProgress(&events)
+	record := opts.Progress
+	opts.Progress = func(event ProgressEvent) {
+		if event.Output != "" {
+			output = append(output, event.Output)
+		}
+		record(event)
+	}
+
+	_, err := ApplyFeatures(context.Background(), memfs, exec, "templates", "project", []string{"auth/oauth"}, opts)
+	require.Error(t, err)
+
+	assert.Equal(t, []recordedEvent{
+		{"auth", FeaturePending},
+		{"auth/oauth", FeaturePending},
+		{"auth", FeatureRunning},
+		{"auth", FeatureDone},
+		{"auth/oauth", FeatureRunning},
+		{"auth/oauth", FeatureFailed},
+		{"auth", FeatureRolledBack},
+	}, events)
+	assert.Contains(t, output, "patch does not apply")
+}
+
 func TestApplyFeature_ErrorsOnMissingFeature(t *testing.T) {
 	memfs := fs.NewMemoryFS()
 	memfs.AddDir("templates")
@@ -155,8 +389,10 @@ func TestApplyFeature_ErrorsOnMissingFeature(t *testing.T) {
 
 	exec := &executor.FakeExecutor{}
 
-	_, err := ApplyFeatures(memfs, exec, "templates", "project", []string{"auth"})
+	_, err := ApplyFeatures(context.Background(), memfs, exec, "templates", "project", []string{"auth"}, ApplyOptions{})
 	assert.EqualError(t, err, "feature not found: auth")
+	var notFound *FeatureNotFoundError
+	assert.ErrorAs(t, err, &notFound)
 
 	assert.Equal(t, 0, len(exec.Commands))
 }
@@ -170,7 +406,7 @@ func TestApplyFeature_AppliesNothingOnMissingFeature(t *testing.T) {
 
 	exec := &executor.FakeExecutor{}
 
-	_, err := ApplyFeatures(memfs, exec, "templates", "project", []string{"auth", "websockets"})
+	_, err := ApplyFeatures(context.Background(), memfs, exec, "templates", "project", []string{"auth", "websockets"}, ApplyOptions{})
 	assert.EqualError(t, err, "feature not found: websockets")
 
 	assert.Equal(t, 0, len(exec.Commands))
@@ -185,7 +421,7 @@ func TestDryRun_ReturnsWhatWouldBeApplied(t *testing.T) {
 	memfs.AddFile("templates/auth/oauth/base.patch", []byte("oauth patch"))
 	memfs.AddDir("project")
 
-	result, err := DryRun(memfs, "templates", "project", []string{"auth/oauth"})
+	result, err := DryRun(memfs, "templates", "project", []string{"auth/oauth"}, nil)
 	require.NoError(t, err)
 
 	assert.Equal(t, []string{"auth", "auth/oauth"}, result.WouldApply)
@@ -202,9 +438,130 @@ func TestDryRun_ExcludesAlreadyApplied(t *testing.T) {
 	memfs.AddDir("project")
 	memfs.AddFile("project/.templater/applied.yml", []byte("applied:\n  - auth\n"))
 
-	result, err := DryRun(memfs, "templates", "project", []string{"auth/oauth"})
+	result, err := DryRun(memfs, "templates", "project", []string{"auth/oauth"}, nil)
 	require.NoError(t, err)
 
 	assert.Equal(t, []string{"auth/oauth"}, result.WouldApply)
 	assert.Equal(t, []string{"auth"}, result.AlreadyApplied)
 }
+
+func TestApplyFeatures_AppliesVariantsAfterBase(t *testing.T) {
+	memfs := fs.NewMemoryFS()
+	memfs.AddDir("templates")
+	memfs.AddDir("templates/ci")
+	memfs.AddDir("templates/ci/github")
+	memfs.AddDir("templates/ci/github/variants")
+	memfs.AddFile("templates/ci/base.patch", []byte("ci"))
+	memfs.AddFile("templates/ci/github/base.patch", []byte("github"))
+	memfs.AddFile("templates/ci/github/variants/cache.patch", []byte("cache"))
+	memfs.AddFile("templates/ci/github/variants/matrix.patch", []byte("matrix"))
+	memfs.AddDir("project")
+
+	exec := &executor.FakeExecutor{}
+
+	result, err := ApplyFeatures(context.Background(), memfs, exec, "templates", "project", []string{"ci/github[matrix,cache]"}, ApplyOptions{})
+	require.NoError(t, err)
+
+	assert.Equal(t, []string{"ci", "ci/github[matrix,cache]"}, result.Applied)
+	var commands []string
+	for _, c := range exec.Commands {
+		commands = append(commands, c.Command)
+	}
+	assert.Equal(t, []string{
+		applyCommand("project", "templates/ci/base.patch"),
+		applyCommand("project", "templates/ci/github/base.patch"),
+		applyCommand("project", "templates/ci/github/variants/matrix.patch"),
+		applyCommand("project", "templates/ci/github/variants/cache.patch"),
+	}, commands)
+}
+
+func TestApplyFeatures_AppliesOnlyMissingVariantsOfAppliedFeature(t *testing.T) {
+	memfs := fs.NewMemoryFS()
+	memfs.AddDir("templates")
+	memfs.AddDir("templates/ci")
+	memfs.AddDir("templates/ci/github")
+	memfs.AddDir("templates/ci/github/variants")
+	memfs.AddFile("templates/ci/base.patch", []byte("ci"))
+	memfs.AddFile("templates/ci/github/base.patch", []byte("github"))
+	memfs.AddFile("templates/ci/github/variants/cache.patch", []byte("cache"))
+	memfs.AddFile("templates/ci/github/variants/matrix.patch", []byte("matrix"))
+	memfs.AddDir("project")
+	memfs.AddFile("project/.templater/applied.yml", []byte("applied:\n  - ci\n  - ci/github[cache]\n"))
+	exec := &executor.FakeExecutor{}
+
+	result, err := ApplyFeatures(context.Background(), memfs, exec, "templates", "project", []string{"ci/github[cache,matrix]"}, ApplyOptions{})
+	require.NoError(t, err)
+
+	assert.Equal(t, []string{"ci/github[cache,matrix]"}, result.Applied)
+	assert.Equal(t, []string{"ci"}, result.AlreadyApplied)
+	require.Len(t, exec.Commands, 1)
+	assert.Equal(t, applyCommand("project", "templates/ci/github/variants/matrix.patch"), exec.Commands[0].Command)
+}
+
+func TestApplyFeatures_MergesVariantsRequestedAcrossArguments(t *testing.T) {
+	memfs := fs.NewMemoryFS()
+	memfs.AddDir("templates")
+	memfs.AddDir("templates/ci")
+	memfs.AddDir("templates/ci/github")
+	memfs.AddDir("templates/ci/github/variants")
+	memfs.AddFile("templates/ci/base.patch", []byte("ci"))
+	memfs.AddFile("templates/ci/github/base.patch", []byte("github"))
+	memfs.AddFile("templates/ci/github/variants/cache.patch", []byte("cache"))
+	memfs.AddFile("templates/ci/github/variants/matrix.patch", []byte("matrix"))
+	memfs.AddDir("project")
+
+	exec := &executor.FakeExecutor{}
+
+	result, err := ApplyFeatures(context.Background(), memfs, exec, "templates", "project", []string{"ci/github[cache]", "ci/github[matrix]"}, ApplyOptions{})
+	require.NoError(t, err)
+
+	assert.Equal(t, []string{"ci", "ci/github[cache,matrix]"}, result.Applied)
+}
+
+func TestApplyFeatures_RollsBackVariantPatches(t *testing.T) {
+	memfs := fs.NewMemoryFS()
+	memfs.AddDir("templates")
+	memfs.AddDir("templates/ci")
+	memfs.AddDir("templates/ci/github")
+	memfs.AddDir("templates/ci/github/variants")
+	memfs.AddFile("templates/ci/base.patch", []byte("ci"))
+	memfs.AddFile("templates/ci/github/base.patch", []byte("github"))
+	memfs.AddFile("templates/ci/github/variants/cache.patch", []byte("cache"))
+	memfs.AddFile("templates/ci/github/variants/matrix.patch", []byte("matrix"))
+	memfs.AddDir("project")
+	memfs.AddDir("templates/database")
+	memfs.AddFile("templates/database/base.patch", []byte("database"))
+	exec := &executor.FakeExecutor{
+		ExitCodes: map[string]int{applyCommand("project", "templates/database/base.patch"): 1},
+	}
+
+	_, err := ApplyFeatures(context.Background(), memfs, exec, "templates", "project", []string{"ci/github[cache]", "database"}, ApplyOptions{})
+	require.Error(t, err)
+
+	n := len(exec.Commands)
+	assert.Equal(t, reverseCommand("project", "templates/ci/github/variants/cache.patch"), exec.Commands[n-3].Command)
+	assert.Equal(t, reverseCommand("project", "templates/ci/github/base.patch"), exec.Commands[n-2].Command)
+	assert.Equal(t, reverseCommand("project", "templates/ci/base.patch"), exec.Commands[n-1].Command)
+}
+
+func TestApplyFeatures_RejectsUnknownVariant(t *testing.T) {
+	memfs := fs.NewMemoryFS()
+	memfs.AddDir("templates")
+	memfs.AddDir("templates/ci")
+	memfs.AddDir("templates/ci/github")
+	memfs.AddDir("templates/ci/github/variants")
+	memfs.AddFile("templates/ci/base.patch", []byte("ci"))
+	memfs.AddFile("templates/ci/github/base.patch", []byte("github"))
+	memfs.AddFile("templates/ci/github/variants/cache.patch", []byte("cache"))
+	memfs.AddFile("templates/ci/github/variants/matrix.patch", []byte("matrix"))
+	memfs.AddDir("project")
+
+	exec := &executor.FakeExecutor{}
+
+	_, err := ApplyFeatures(context.Background(), memfs, exec, "templates", "project", []string{"ci/github[docker]"}, ApplyOptions{})
+
+	var notFound *VariantNotFoundError
+	require.ErrorAs(t, err, &notFound)
+	assert.EqualError(t, err, "variant not found: ci/github[docker] (available: cache, matrix)")
+	assert.Empty(t, exec.Commands)
+}
@@ -0,0 +1,180 @@
+package template
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path"
+	"slices"
+	"strings"
+
+	"templater/internal/fs"
+	"templater/internal/patch"
+)
+
+type PatchDrift struct {
+	Patch  PatchState
+	File   string
+	Reason string
+}
+
+func patchCachePath(targetPath, sum string) string {
+	return path.Join(targetPath, ".templater/patches", sum+".patch")
+}
+
+func (a *applier) cachePatches(ref FeatureRef, patches []string) ([]PatchState, error) {
+	templatePath, _ := a.sources.locate(ref)
+	var records []PatchState
+	for _, patchPath := range patches {
+		data, err := a.fileSystem.ReadFile(patchPath)
+		if err != nil {
+			return nil, err
+		}
+		sum := checksum(data)
+		cached := patchCachePath(a.targetPath, sum)
+		if _, err := a.fileSystem.Stat(cached); errors.Is(err, os.ErrNotExist) {
+			if err := a.fileSystem.WriteFile(cached, data); err != nil {
+				return nil, err
+			}
+			a.backup(ref, cached, nil, false)
+		}
+		records = append(records, PatchState{
+			Path:    strings.TrimPrefix(patchPath, templatePath+"/"),
+			Feature: ref.Name,
+			SHA256:  sum,
+		})
+	}
+	return records, nil
+}
+
+// appliedPatches returns the patches to reverse when removing ref, in the
+// order they were applied. A feature applied before patches were cached has no
+// records for its base or for some variants; those patches are read from the
+// template instead, ahead of the recorded ones, since they were applied first.
+func (a *applier) appliedPatches(ref FeatureRef, state *State) ([]string, error) {
+	records := state.featurePatchStates(ref.Name)
+	templatePath, local := a.sources.locate(ref)
+	recorded := make(map[string]bool, len(records))
+	for _, record := range records {
+		recorded[path.Join(templatePath, record.Path)] = true
+	}
+
+	base, err := featurePatches(a.fileSystem, templatePath, local.Name)
+	if err != nil {
+		return nil, err
+	}
+	groups := [][]string{base}
+	for _, variant := range local.Variants {
+		groups = append(groups, []string{variantPatch(templatePath, local.Name, variant)})
+	}
+	var patches []string
+	for _, group := range groups {
+		if !slices.ContainsFunc(group, func(p string) bool { return recorded[p] }) {
+			patches = append(patches, group...)
+		}
+	}
+
+	for _, record := range records {
+		cached := patchCachePath(a.targetPath, record.SHA256)
+		if _, err := a.fileSystem.Stat(cached); err == nil {
+			patches = append(patches, cached)
+			continue
+		}
+		source := path.Join(templatePath, record.Path)
+		data, err := a.fileSystem.ReadFile(source)
+		if err != nil || checksum(data) != record.SHA256 {
+			return nil, fmt.Errorf("cannot remove %s: %s is missing and %s has changed since it was applied", displayFeature(ref.Name), cached, source)
+		}
+		patches = append(patches, source)
+	}
+	return patches, nil
+}
+
+func (a *applier) pruneCache(ref FeatureRef, removed []PatchState, state *State) error {
+	inUse := make(map[string]bool)
+	for _, p := range state.Patches {
+		inUse[p.SHA256] = true
+	}
+	for _, p := range removed {
+		if inUse[p.SHA256] {
+			continue
+		}
+		cached := patchCachePath(a.targetPath, p.SHA256)
+		data, err := a.fileSystem.ReadFile(cached)
+		if errors.Is(err, os.ErrNotExist) {
+			continue
+		}
+		if err != nil {
+			return err
+		}
+		a.backup(ref, cached, data, true)
+		if err := a.fileSystem.Remove(cached); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func CheckPatchDrift(fileSystem fs.FileSystem, targetPath string) ([]PatchDrift, error) {
+	state, err := ReadState(fileSystem, targetPath)
+	if err != nil {
+		return nil, err
+	}
+
+	contents := make(map[string]*string)
+	failed := make(map[string]bool)
+	var drifted []PatchDrift
+	for i := len(state.Patches) - 1; i >= 0; i-- {
+		p := state.Patches[i]
+		data, err := fileSystem.ReadFile(patchCachePath(targetPath, p.SHA256))
+		if errors.Is(err, os.ErrNotExist) {
+			drifted = append(drifted, PatchDrift{Patch: p, Reason: "cached copy is missing"})
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		files, err := patch.Parse(data)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", patchCachePath(targetPath, p.SHA256), err)
+		}
+
+		for j := len(files) - 1; j >= 0; j-- {
+			f := files[j].Reverse()
+			name := f.Path()
+			if f.IsBinary || failed[name] {
+				continue
+			}
+			content, known := contents[name]
+			if !known {
+				data, err := fileSystem.ReadFile(path.Join(targetPath, name))
+				switch {
+				case err == nil:
+					existing := string(data)
+					content = &existing
+				case !errors.Is(err, os.ErrNotExist):
+					return nil, err
+				}
+			}
+			var current string
+			if content != nil {
+				current = *content
+			}
+			reversed, err := patch.Apply(current, content != nil, f)
+			var hunkErr *patch.HunkError
+			if errors.As(err, &hunkErr) {
+				failed[name] = true
+				drifted = append(drifted, PatchDrift{Patch: p, File: name, Reason: hunkErr.Reason})
+				continue
+			}
+			if err != nil {
+				return nil, err
+			}
+			contents[name] = &reversed
+			if f.IsDeleted {
+				contents[name] = nil
+			}
+		}
+	}
+	return drifted, nil
+}
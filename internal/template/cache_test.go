@@ -0,0 +1,145 @@
+package template
+
+import (
+	"context"
+	"testing"
+
+	"templater/internal/testutil/executor"
+	"templater/internal/testutil/fs"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestApplyFeatures_CachesAppliedPatches(t *testing.T) {
+	memfs := fs.NewMemoryFS()
+	memfs.AddDir("templates")
+	memfs.AddDir("templates/auth")
+	memfs.AddFile("templates/auth/base.patch", []byte(authPatch))
+
+	_, err := ApplyFeatures(context.Background(), memfs, &executor.FakeExecutor{}, "templates", "project", []string{"auth"}, ApplyOptions{})
+	require.NoError(t, err)
+
+	sum := checksum([]byte(authPatch))
+	data, err := memfs.ReadFile(patchCachePath("project", sum))
+	require.NoError(t, err)
+	assert.Equal(t, authPatch, string(data))
+
+	state, err := ReadState(memfs, "project")
+	require.NoError(t, err)
+	assert.Equal(t, []PatchState{{Path: "auth/base.patch", Feature: "auth", SHA256: sum}}, state.Patches)
+}
+
+func TestApplyFeatures_RollbackRemovesCachedPatches(t *testing.T) {
+	memfs := fs.NewMemoryFS()
+	memfs.AddDir("templates")
+	memfs.AddDir("templates/auth")
+	memfs.AddFile("templates/auth/base.patch", []byte(authPatch))
+	memfs.AddDir("templates/database")
+	memfs.AddFile("templates/database/base.patch", []byte("database"))
+	exec := &executor.FakeExecutor{ExitCodes: map[string]int{applyCommand("project", "templates/database/base.patch"): 1}}
+
+	_, err := ApplyFeatures(context.Background(), memfs, exec, "templates", "project", []string{"auth", "database"}, ApplyOptions{})
+	require.Error(t, err)
+
+	_, err = memfs.ReadFile(patchCachePath("project", checksum([]byte(authPatch))))
+	assert.Error(t, err)
+	_, err = memfs.ReadFile(statePath("project"))
+	assert.Error(t, err)
+}
+
+func TestRemoveFeatures_ReversesCachedPatchAfterTemplateChanges(t *testing.T) {
+	memfs := fs.NewMemoryFS()
+	memfs.AddDir("templates")
+	memfs.AddDir("templates/auth")
+	memfs.AddFile("templates/auth/base.patch", []byte(authPatch))
+	applyAndRecord(t, memfs, "auth")
+	memfs.AddFile("templates/auth/base.patch", []byte("edited"))
+	memfs.AddFile("templates/auth/extra.patch", []byte("extra"))
+	exec := &executor.FakeExecutor{}
+
+	_, err := RemoveFeatures(context.Background(), memfs, exec, "templates", "project", []string{"auth"}, ApplyOptions{})
+	require.NoError(t, err)
+
+	cached := patchCachePath("project", checksum([]byte(authPatch)))
+	assert.Equal(t, []string{reverseCommand("project", cached)}, commandsOf(exec))
+	_, err = memfs.ReadFile(cached)
+	assert.Error(t, err)
+	_, err = memfs.ReadFile(statePath("project"))
+	assert.Error(t, err)
+}
+
+func TestRemoveFeatures_FallsBackToUnchangedTemplatePatch(t *testing.T) {
+	memfs := fs.NewMemoryFS()
+	memfs.AddDir("templates")
+	memfs.AddDir("templates/auth")
+	memfs.AddFile("templates/auth/base.patch", []byte(authPatch))
+	applyAndRecord(t, memfs, "auth")
+	require.NoError(t, memfs.Remove(patchCachePath("project", checksum([]byte(authPatch)))))
+	exec := &executor.FakeExecutor{}
+
+	_, err := RemoveFeatures(context.Background(), memfs, exec, "templates", "project", []string{"auth"}, ApplyOptions{})
+	require.NoError(t, err)
+
+	assert.Equal(t, []string{reverseCommand("project", "templates/auth/base.patch")}, commandsOf(exec))
+}
+
+func TestRemoveFeatures_MissingCacheAndChangedTemplate(t *testing.T) {
+	memfs := fs.NewMemoryFS()
+	memfs.AddDir("templates")
+	memfs.AddDir("templates/auth")
+	memfs.AddFile("templates/auth/base.patch", []byte(authPatch))
+	applyAndRecord(t, memfs, "auth")
+	cached := patchCachePath("project", checksum([]byte(authPatch)))
+	require.NoError(t, memfs.Remove(cached))
+	memfs.AddFile("templates/auth/base.patch", []byte("edited"))
+
+	_, err := RemoveFeatures(context.Background(), memfs, &executor.FakeExecutor{}, "templates", "project", []string{"auth"}, ApplyOptions{})
+
+	assert.EqualError(t, err, "cannot remove auth: "+cached+" is missing and templates/auth/base.patch has changed since it was applied")
+}
+
+func TestRemoveFeatures_ReversesUncachedBaseOfCachedVariant(t *testing.T) {
+	memfs := fs.NewMemoryFS()
+	memfs.AddDir("templates")
+	memfs.AddDir("templates/ci")
+	memfs.AddDir("templates/ci/github")
+	memfs.AddDir("templates/ci/github/variants")
+	memfs.AddFile("templates/ci/base.patch", []byte("ci"))
+	memfs.AddFile("templates/ci/github/base.patch", []byte("github"))
+	memfs.AddFile("templates/ci/github/variants/cache.patch", []byte("cache"))
+	memfs.AddFile("templates/ci/github/variants/matrix.patch", []byte("matrix"))
+	memfs.AddDir("project")
+	memfs.AddFile("project/.templater/applied.yml", []byte("applied:\n  - ci\n  - ci/github\n"))
+	applyAndRecord(t, memfs, "ci/github[cache]")
+	exec := &executor.FakeExecutor{}
+
+	_, err := RemoveFeatures(context.Background(), memfs, exec, "templates", "project", []string{"ci/github"}, ApplyOptions{})
+	require.NoError(t, err)
+
+	assert.Equal(t, []string{
+		reverseCommand("project", patchCachePath("project", checksum([]byte("cache")))),
+		reverseCommand("project", "templates/ci/github/base.patch"),
+	}, commandsOf(exec))
+}
+
+func TestCheckPatchDrift(t *testing.T) {
+	memfs := fs.NewMemoryFS()
+	memfs.AddDir("templates")
+	memfs.AddDir("templates/auth")
+	memfs.AddFile("templates/auth/base.patch", []byte(authPatch))
+	applyAndRecord(t, memfs, "auth")
+
+	memfs.AddFile("project/auth.go", []byte("package auth\n"))
+	drifted, err := CheckPatchDrift(memfs, "project")
+	require.NoError(t, err)
+	assert.Empty(t, drifted)
+
+	memfs.AddFile("project/auth.go", []byte("package login\n"))
+	drifted, err = CheckPatchDrift(memfs, "project")
+	require.NoError(t, err)
+	require.Len(t, drifted, 1)
+	assert.Equal(t, "auth.go", drifted[0].File)
+	assert.Equal(t, "auth/base.patch", drifted[0].Patch.Path)
+	assert.Equal(t, "patch does not apply", drifted[0].Reason)
+}
@@ -0,0 +1,294 @@
+package template
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"slices"
+	"sort"
+	"strings"
+
+	"templater/internal/executor"
+	"templater/internal/fs"
+	"templater/internal/patch"
+)
+
+const (
+	featureTrailer        = "Templater-Feature"
+	templateCommitTrailer = "Templater-Template-Commit"
+	patchHashTrailer      = "Templater-Patch-Hash"
+)
+
+type FeatureCommit struct {
+	Commit         string
+	Feature        string
+	TemplateCommit string
+	PatchHash      string
+}
+
+type committer struct {
+	exec            executor.Executor
+	targetPath      string
+	timeout         string
+	start           string
+	templateCommits map[string]string
+	paths           []string
+}
+
+// prepareCommits records the target's starting commit and the HEAD of every
+// template repository the features come from.
+func (a *applier) prepareCommits(ctx context.Context, refs []FeatureRef) (*committer, error) {
+	c := &committer{exec: a.exec, targetPath: a.targetPath, timeout: a.opts.Timeout, templateCommits: make(map[string]string)}
+
+	start, _ := c.git(ctx, fmt.Sprintf("git -C %s rev-parse --verify --quiet HEAD", shellQuote(a.targetPath)), "")
+	c.start = strings.TrimSpace(start)
+	for _, ref := range refs {
+		templatePath, _ := a.sources.locate(ref)
+		if _, ok := c.templateCommits[templatePath]; ok {
+			continue
+		}
+		templateCommit, _ := c.git(ctx, fmt.Sprintf("git -C %s rev-parse HEAD", shellQuote(templatePath)), "")
+		c.templateCommits[templatePath] = strings.TrimSpace(templateCommit)
+	}
+	return c, nil
+}
+
+func (a *applier) commit(ctx context.Context, ref FeatureRef) error {
+	recorded := ref
+	if previous, ok := a.previous[ref.Name]; ok {
+		recorded = previous.with(ref)
+	}
+	var versions map[string]string
+	source, _ := splitSource(ref.Name)
+	if version, ok := a.versions[source]; ok {
+		versions = map[string]string{source: version}
+	}
+	if err := RecordApplied(a.fileSystem, a.targetPath, []string{recorded.String()}, versions); err != nil {
+		return fmt.Errorf("failed to update applied.yml: %w", err)
+	}
+
+	patches, err := a.patches(ref)
+	if err != nil {
+		return err
+	}
+	overlay, err := a.overlay(ref)
+	if err != nil {
+		return err
+	}
+	merges, err := a.merges(ref)
+	if err != nil {
+		return err
+	}
+	sources := slices.Clone(patches)
+	for _, f := range overlay {
+		sources = append(sources, f.Source)
+	}
+	for _, fragment := range merges {
+		sources = append(sources, fragment.Source)
+	}
+	injections, err := a.injections(ref)
+	if err != nil {
+		return err
+	}
+	if len(injections) > 0 {
+		templatePath, local := a.sources.locate(ref)
+		sources = append(sources, manifestPath(templatePath, local.Name))
+	}
+	hash, err := patchHash(a.fileSystem, sources)
+	if err != nil {
+		return err
+	}
+
+	var pathspec string
+	if a.dirty {
+		paths, err := a.writtenPaths(ref)
+		if err != nil {
+			return err
+		}
+		for _, patchPath := range patches {
+			data, err := a.fileSystem.ReadFile(patchPath)
+			if err != nil {
+				return err
+			}
+			paths = append(paths, ".templater/patches/"+checksum(data)+".patch")
+		}
+		paths = append(paths, ".templater/applied.yml")
+		if len(patches) > 0 || len(overlay) > 0 || len(merges) > 0 || len(injections) > 0 {
+			paths = append(paths, ".templater/state.yml")
+		}
+		pathspec = " -- " + shellJoin(paths)
+		a.commits.paths = append(a.commits.paths, paths...)
+	}
+
+	templatePath, _ := a.sources.locate(ref)
+	message := commitMessage(FeatureCommit{
+		Feature:        displayFeature(recorded.String()),
+		TemplateCommit: a.commits.templateCommits[templatePath],
+		PatchHash:      hash,
+	})
+	if _, err := a.commits.git(ctx, fmt.Sprintf("git -C %s add -A%s", shellQuote(a.targetPath), pathspec), ""); err != nil {
+		return err
+	}
+	_, err = a.commits.git(ctx, fmt.Sprintf("git -C %s commit --quiet --no-verify -F -%s", shellQuote(a.targetPath), pathspec), message)
+	return err
+}
+
+func (a *applier) writtenPaths(ref FeatureRef) ([]string, error) {
+	patches, err := a.patches(ref)
+	if err != nil {
+		return nil, err
+	}
+	paths, err := patchedPaths(a.fileSystem, patches)
+	if err != nil {
+		return nil, err
+	}
+	overlay, err := a.overlay(ref)
+	if err != nil {
+		return nil, err
+	}
+	for _, f := range overlay {
+		paths = append(paths, f.Path)
+	}
+	merges, err := a.merges(ref)
+	if err != nil {
+		return nil, err
+	}
+	for _, fragment := range merges {
+		paths = append(paths, fragment.Path)
+	}
+	injections, err := a.injections(ref)
+	if err != nil {
+		return nil, err
+	}
+	for _, inj := range injections {
+		paths = append(paths, inj.File)
+	}
+	return paths, nil
+}
+
+// reset moves HEAD back to where the apply started and unstages what the
+// feature commits added. With --allow-dirty only the committed paths are
+// unstaged, so changes the user had staged stay in the index.
+func (c *committer) reset(ctx context.Context) error {
+	cmd := fmt.Sprintf("git -C %s reset --quiet", shellQuote(c.targetPath))
+	if c.start == "" {
+		if _, err := c.git(ctx, fmt.Sprintf("git -C %s update-ref -d HEAD", shellQuote(c.targetPath)), ""); err != nil {
+			return err
+		}
+	} else {
+		if _, err := c.git(ctx, fmt.Sprintf("git -C %s reset --soft --quiet %s", shellQuote(c.targetPath), c.start), ""); err != nil {
+			return err
+		}
+		cmd += " " + c.start
+	}
+	if c.paths != nil {
+		cmd += " -- " + shellJoin(c.paths)
+	}
+	_, err := c.git(ctx, cmd, "")
+	return err
+}
+
+func (c *committer) git(ctx context.Context, cmd, stdin string) (string, error) {
+	return runGit(ctx, c.exec, cmd, c.timeout, stdin)
+}
+
+func commitMessage(commit FeatureCommit) string {
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "Apply feature %s\n\n", commit.Feature)
+	fmt.Fprintf(&sb, "%s: %s\n", featureTrailer, commit.Feature)
+	if commit.TemplateCommit != "" {
+		fmt.Fprintf(&sb, "%s: %s\n", templateCommitTrailer, commit.TemplateCommit)
+	}
+	fmt.Fprintf(&sb, "%s: %s\n", patchHashTrailer, commit.PatchHash)
+	return sb.String()
+}
+
+func patchHash(fileSystem fs.FileSystem, patches []string) (string, error) {
+	h := sha256.New()
+	for _, patchPath := range patches {
+		data, err := fileSystem.ReadFile(patchPath)
+		if err != nil {
+			return "", err
+		}
+		h.Write(data)
+	}
+	return "sha256:" + hex.EncodeToString(h.Sum(nil)), nil
+}
+
+func patchedPaths(fileSystem fs.FileSystem, patches []string) ([]string, error) {
+	seen := make(map[string]bool)
+	var paths []string
+	for _, patchPath := range patches {
+		data, err := fileSystem.ReadFile(patchPath)
+		if err != nil {
+			return nil, err
+		}
+		files, err := patch.Parse(data)
+		if err != nil {
+			return nil, err
+		}
+		for _, f := range files {
+			for _, p := range []string{f.OldPath, f.Path()} {
+				if p != "" && !seen[p] {
+					seen[p] = true
+					paths = append(paths, p)
+				}
+			}
+		}
+	}
+	sort.Strings(paths)
+	return paths, nil
+}
+
+func displayFeature(feature string) string {
+	if feature == "" || strings.HasSuffix(feature, ":") {
+		return feature + "(root)"
+	}
+	return feature
+}
+
+func ReadFeatureCommits(ctx context.Context, exec executor.Executor, targetPath, timeout string) ([]FeatureCommit, error) {
+	if timeout == "" {
+		timeout = DefaultTimeout
+	}
+	cmd := fmt.Sprintf("git -C %s log --grep=^%s: --format=%%H%%n%%B%%x1e", shellQuote(targetPath), featureTrailer)
+	stdout, _, exitCode, err := exec.Execute(ctx, cmd, timeout, nil)
+	if err != nil {
+		return nil, err
+	}
+	if exitCode != 0 {
+		return nil, nil
+	}
+	return parseFeatureCommits(stdout), nil
+}
+
+func parseFeatureCommits(log string) []FeatureCommit {
+	var commits []FeatureCommit
+	for _, record := range strings.Split(log, "\x1e") {
+		hash, body, _ := strings.Cut(strings.TrimSpace(record), "\n")
+		commit := FeatureCommit{Commit: hash}
+		found := false
+		for _, line := range strings.Split(body, "\n") {
+			key, value, ok := strings.Cut(line, ": ")
+			if !ok {
+				continue
+			}
+			switch key {
+			case featureTrailer:
+				commit.Feature, found = value, true
+				if value == "(root)" || strings.HasSuffix(value, ":(root)") {
+					commit.Feature = strings.TrimSuffix(value, "(root)")
+				}
+			case templateCommitTrailer:
+				commit.TemplateCommit = value
+			case patchHashTrailer:
+				commit.PatchHash = value
+			}
+		}
+		if found {
+			commits = append(commits, commit)
+		}
+	}
+	return commits
+}
@@ -0,0 +1,146 @@
+package template
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"templater/internal/testutil/executor"
+	"templater/internal/testutil/fs"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const authPatch = "diff --git a/auth.go b/auth.go\n" +
+	"new file mode 100644\n" +
+	"--- /dev/null\n" +
+	"+++ b/auth.go\n" +
+	"@@ -0,0 +1 @@\n" +
+	"+package auth\n"
+
+func TestApplyFeatures_CommitsEachFeature(t *testing.T) {
+	memfs := fs.NewMemoryFS()
+	memfs.AddDir("templates")
+	memfs.AddDir("templates/auth")
+	memfs.AddFile("templates/auth/base.patch", []byte(authPatch))
+	exec := &executor.FakeExecutor{}
+
+	result, err := ApplyFeatures(context.Background(), memfs, exec, "templates", "project", []string{"auth"}, ApplyOptions{Commit: true})
+	require.NoError(t, err)
+
+	assert.Equal(t, []string{"auth"}, result.Applied)
+	assert.Equal(t, []string{
+		"git -C project rev-parse --show-prefix",
+		"git -C project status --porcelain -z --untracked-files=all -- .",
+		"git -C project rev-parse --verify --quiet HEAD",
+		"git -C templates rev-parse HEAD",
+		applyCommand("project", "templates/auth/base.patch"),
+		"git -C project add -A",
+		"git -C project commit --quiet --no-verify -F -",
+	}, commandsOf(exec))
+	assert.Equal(t, "Apply feature auth\n\n"+
+		"Templater-Feature: auth\n"+
+		"Templater-Patch-Hash: sha256:f9680f8f4cff696ed8213ad00983f1b649e98e6ce236736843ab0f6d6e9f547f\n", exec.StdinReceived)
+
+	applied, err := ReadApplied(memfs, "project")
+	require.NoError(t, err)
+	assert.Equal(t, []string{"auth"}, applied)
+}
+
+func TestApplyFeatures_AllowDirtyCommitsOnlyPatchedFiles(t *testing.T) {
+	memfs := fs.NewMemoryFS()
+	memfs.AddDir("templates")
+	memfs.AddDir("templates/auth")
+	memfs.AddFile("templates/auth/base.patch", []byte(authPatch))
+
+	exec := &executor.FakeExecutor{Stdout: " M main.go\x00"}
+
+	_, err := ApplyFeatures(context.Background(), memfs, exec, "templates", "project", []string{"auth"}, ApplyOptions{Commit: true, AllowDirty: true})
+	require.NoError(t, err)
+
+	commands := commandsOf(exec)
+	assert.Equal(t, []string{
+		"git -C project add -A -- auth.go .templater/patches/" + checksum([]byte(authPatch)) + ".patch .templater/applied.yml .templater/state.yml",
+		"git -C project commit --quiet --no-verify -F - -- auth.go .templater/patches/" + checksum([]byte(authPatch)) + ".patch .templater/applied.yml .templater/state.yml",
+	}, commands[len(commands)-2:])
+}
+
+func TestApplyFeatures_AllowDirtyRefusesToCommitUserEdits(t *testing.T) {
+	memfs := fs.NewMemoryFS()
+	memfs.AddDir("templates")
+	memfs.AddDir("templates/auth")
+	memfs.AddFile("templates/auth/base.patch", []byte(authPatch))
+	exec := &executor.FakeExecutor{Stdout: " M auth.go\x00"}
+
+	_, err := ApplyFeatures(context.Background(), memfs, exec, "templates", "project", []string{"auth"}, ApplyOptions{Commit: true, AllowDirty: true})
+
+	var dirtyErr *DirtyTreeError
+	require.ErrorAs(t, err, &dirtyErr)
+	assert.Equal(t, []string{"auth.go"}, dirtyErr.Overlapping)
+	assert.NotContains(t, commandsOf(exec), applyCommand("project", "templates/auth/base.patch"))
+	_, err = memfs.ReadFile(appliedPath("project"))
+	assert.Error(t, err)
+}
+
+func TestApplyFeatures_CommitFailureRollsBack(t *testing.T) {
+	memfs := fs.NewMemoryFS()
+	memfs.AddDir("templates")
+	memfs.AddDir("templates/auth")
+	memfs.AddFile("templates/auth/base.patch", []byte(authPatch))
+	exec := &executor.FakeExecutor{ExitCodes: map[string]int{
+		"git -C project rev-parse --verify --quiet HEAD": 1,
+		"git -C project commit --quiet --no-verify -F -": 1,
+	}}
+
+	_, err := ApplyFeatures(context.Background(), memfs, exec, "templates", "project", []string{"auth"}, ApplyOptions{Commit: true})
+	require.Error(t, err)
+
+	commands := commandsOf(exec)
+	assert.Equal(t, []string{
+		reverseCommand("project", "templates/auth/base.patch"),
+		"git -C project update-ref -d HEAD",
+		"git -C project reset --quiet",
+	}, commands[len(commands)-3:])
+
+	_, err = memfs.ReadFile(appliedPath("project"))
+	assert.Error(t, err)
+}
+
+func TestApplyFeatures_AllowDirtyRollbackKeepsUserIndex(t *testing.T) {
+	memfs := fs.NewMemoryFS()
+	memfs.AddDir("templates")
+	memfs.AddDir("templates/auth")
+	memfs.AddFile("templates/auth/base.patch", []byte(authPatch))
+
+	exec := &executor.FakeExecutor{Stdout: " M main.go\x00", ExitCodes: map[string]int{
+		"git -C project rev-parse --verify --quiet HEAD": 1,
+	}}
+	exec.OnExecute = func(command string) {
+		if strings.HasPrefix(command, "git -C project commit ") {
+			exec.ExitCodes[command] = 1
+		}
+	}
+
+	_, err := ApplyFeatures(context.Background(), memfs, exec, "templates", "project", []string{"auth"}, ApplyOptions{Commit: true, AllowDirty: true})
+	require.Error(t, err)
+
+	commands := commandsOf(exec)
+	assert.Equal(t, []string{
+		"git -C project update-ref -d HEAD",
+		"git -C project reset --quiet -- auth.go .templater/patches/" + checksum([]byte(authPatch)) + ".patch .templater/applied.yml .templater/state.yml",
+	}, commands[len(commands)-2:])
+}
+
+func TestParseFeatureCommits(t *testing.T) {
+	log := "c2\nApply feature auth/oauth\n\nTemplater-Feature: auth/oauth\nTemplater-Template-Commit: t1\nTemplater-Patch-Hash: sha256:ab\n\x1e\n" +
+		"c1\nUnrelated change\n\x1e\n" +
+		"c0\nApply feature (root)\n\nTemplater-Feature: (root)\nTemplater-Patch-Hash: sha256:cd\n\x1e\n"
+
+	commits := parseFeatureCommits(log)
+
+	assert.Equal(t, []FeatureCommit{
+		{Commit: "c2", Feature: "auth/oauth", TemplateCommit: "t1", PatchHash: "sha256:ab"},
+		{Commit: "c0", Feature: "", PatchHash: "sha256:cd"},
+	}, commits)
+}
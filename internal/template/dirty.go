@@ -0,0 +1,146 @@
+package template
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+)
+
+const stashMessage = "templater: auto-stash before apply"
+
+func (a *applier) preflight(ctx context.Context, refs []FeatureRef) error {
+	if len(refs) == 0 {
+		return nil
+	}
+
+	prefix, err := a.gitPrefix(ctx)
+	if err != nil {
+		if a.opts.Commit || a.opts.Stash {
+			return fmt.Errorf("%s is not inside a git work tree (required by --commit and --stash): %w", a.targetPath, err)
+		}
+		if a.opts.AllowDirty {
+			return nil
+		}
+		return a.checkModified(refs)
+	}
+
+	status, err := runGit(ctx, a.exec, fmt.Sprintf("git -C %s status --porcelain -z --untracked-files=all -- .", shellQuote(a.targetPath)), a.opts.Timeout, "")
+	if err != nil {
+		return err
+	}
+	var dirty []string
+	for _, p := range porcelainPaths(status) {
+		dirty = append(dirty, strings.TrimPrefix(p, prefix))
+	}
+	if len(dirty) == 0 {
+		return nil
+	}
+
+	switch {
+	case a.opts.Stash:
+		cmd := fmt.Sprintf("git -C %s stash push --include-untracked --quiet -m %q -- .", shellQuote(a.targetPath), stashMessage)
+		if _, err := runGit(ctx, a.exec, cmd, a.opts.Timeout, ""); err != nil {
+			return err
+		}
+		a.stashed = true
+		return nil
+	case a.opts.AllowDirty:
+		if a.opts.Commit {
+			var written []string
+			for _, ref := range refs {
+				paths, err := a.writtenPaths(ref)
+				if err != nil {
+					return err
+				}
+				written = append(written, paths...)
+			}
+			if overlap := intersect(dirty, written); len(overlap) > 0 {
+				return &DirtyTreeError{Target: a.targetPath, Files: dirty, Overlapping: overlap, Git: true, Commit: true}
+			}
+		}
+		a.dirty = true
+		return nil
+	}
+
+	touched, err := a.patchedPaths(refs)
+	if err != nil {
+		return err
+	}
+	return &DirtyTreeError{Target: a.targetPath, Files: dirty, Overlapping: intersect(dirty, touched), Git: true}
+}
+
+func (a *applier) gitPrefix(ctx context.Context) (string, error) {
+	if !a.opts.Commit && !a.opts.Stash && !inGitWorkTree(a.fileSystem, a.targetPath) {
+		return "", errors.New("no .git directory found")
+	}
+	prefix, err := runGit(ctx, a.exec, fmt.Sprintf("git -C %s rev-parse --show-prefix", shellQuote(a.targetPath)), a.opts.Timeout, "")
+	return strings.TrimSpace(prefix), err
+}
+
+func (a *applier) checkModified(refs []FeatureRef) error {
+	target := newVirtualTarget(a.fileSystem, a.targetPath)
+	var modified []string
+	var cause error
+
+	for _, ref := range refs {
+		patches, err := a.patches(ref)
+		if err != nil {
+			return err
+		}
+		for _, patchPath := range patches {
+			err := target.applyPatch(ref.String(), patchPath)
+			var conflict *PatchConflictError
+			if !errors.As(err, &conflict) {
+				if err != nil {
+					return err
+				}
+				continue
+			}
+			if f, ok := target.files[conflict.File]; ok && f.originalExists && f.content == f.original {
+				modified = append(modified, conflict.File)
+				if cause == nil {
+					cause = conflict
+				}
+			}
+		}
+	}
+
+	if len(modified) == 0 {
+		return nil
+	}
+	return &DirtyTreeError{Target: a.targetPath, Files: modified, Overlapping: modified, Cause: cause}
+}
+
+func (a *applier) patchedPaths(refs []FeatureRef) ([]string, error) {
+	var all []string
+	for _, ref := range refs {
+		patches, err := a.patches(ref)
+		if err != nil {
+			return nil, err
+		}
+		all = append(all, patches...)
+	}
+	return patchedPaths(a.fileSystem, all)
+}
+
+func (a *applier) unstash(ctx context.Context) error {
+	_, err := runGit(ctx, a.exec, fmt.Sprintf("git -C %s stash pop --quiet", shellQuote(a.targetPath)), a.opts.Timeout, "")
+	return err
+}
+
+func intersect(a, b []string) []string {
+	set := make(map[string]bool, len(b))
+	for _, s := range b {
+		set[s] = true
+	}
+	var both []string
+	for _, s := range a {
+		if set[s] {
+			both = append(both, s)
+		}
+	}
+	sort.Strings(both)
+	return both
+}
@@ -0,0 +1,228 @@
+package template
+
+import (
+	"context"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"templater/internal/testutil/executor"
+	"templater/internal/testutil/fs"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const modifyReadmePatch = "diff --git a/README.md b/README.md\n" +
+	"--- a/README.md\n" +
+	"+++ b/README.md\n" +
+	"@@ -1 +1,2 @@\n" +
+	" # Project\n" +
+	"+Now with auth.\n"
+
+func TestApplyFeatures_RefusesDirtyGitTree(t *testing.T) {
+	memfs := fs.NewMemoryFS()
+	memfs.AddDir("templates")
+	memfs.AddDir("templates/auth")
+	memfs.AddFile("templates/auth/base.patch", []byte(authPatch))
+	memfs.AddFile("templates/auth/patches/0001-readme.patch", []byte(modifyReadmePatch))
+	memfs.AddDir("templates/auth/patches")
+	memfs.AddFile("project/README.md", []byte("# Project\n"))
+	memfs.AddDir("project/.git")
+
+	exec := &executor.FakeExecutor{Stdout: " M README.md\x00?? notes.txt\x00"}
+
+	_, err := ApplyFeatures(context.Background(), memfs, exec, "templates", "project", []string{"auth"}, ApplyOptions{})
+
+	var dirty *DirtyTreeError
+	require.ErrorAs(t, err, &dirty)
+	assert.Equal(t, []string{"README.md", "notes.txt"}, dirty.Files)
+	assert.Equal(t, []string{"README.md"}, dirty.Overlapping)
+	assert.Equal(t, "project has uncommitted changes (README.md, notes.txt, overlapping the patches: README.md); commit them, or pass --stash or --allow-dirty", err.Error())
+	assert.Equal(t, []string{
+		"git -C project rev-parse --show-prefix",
+		"git -C project status --porcelain -z --untracked-files=all -- .",
+	}, commandsOf(exec))
+}
+
+func TestApplyFeatures_AllowDirtyAppliesAnyway(t *testing.T) {
+	memfs := fs.NewMemoryFS()
+	memfs.AddDir("templates")
+	memfs.AddDir("templates/auth")
+	memfs.AddFile("templates/auth/base.patch", []byte(authPatch))
+	memfs.AddFile("templates/auth/patches/0001-readme.patch", []byte(modifyReadmePatch))
+	memfs.AddDir("templates/auth/patches")
+	memfs.AddFile("project/README.md", []byte("# Project\n"))
+	memfs.AddDir("project/.git")
+
+	exec := &executor.FakeExecutor{Stdout: " M README.md\x00"}
+
+	result, err := ApplyFeatures(context.Background(), memfs, exec, "templates", "project", []string{"auth"}, ApplyOptions{AllowDirty: true})
+	require.NoError(t, err)
+
+	assert.Equal(t, []string{"auth"}, result.Applied)
+}
+
+func TestApplyFeatures_StashesAroundApply(t *testing.T) {
+	memfs := fs.NewMemoryFS()
+	memfs.AddDir("templates")
+	memfs.AddDir("templates/auth")
+	memfs.AddFile("templates/auth/base.patch", []byte(authPatch))
+	memfs.AddFile("templates/auth/patches/0001-readme.patch", []byte(modifyReadmePatch))
+	memfs.AddDir("templates/auth/patches")
+	memfs.AddFile("project/README.md", []byte("# Project\n"))
+	memfs.AddDir("project/.git")
+
+	exec := &executor.FakeExecutor{Stdout: " M README.md\x00"}
+
+	result, err := ApplyFeatures(context.Background(), memfs, exec, "templates", "project", []string{"auth"}, ApplyOptions{Stash: true})
+	require.NoError(t, err)
+
+	assert.False(t, result.StashKept)
+	assert.Equal(t, []string{
+		"git -C project rev-parse --show-prefix",
+		"git -C project status --porcelain -z --untracked-files=all -- .",
+		`git -C project stash push --include-untracked --quiet -m "templater: auto-stash before apply" -- .`,
+		applyCommand("project", "templates/auth/base.patch"),
+		applyCommand("project", "templates/auth/patches/0001-readme.patch"),
+		"git -C project stash pop --quiet",
+	}, commandsOf(exec))
+}
+
+func TestApplyFeatures_StashIsRestoredAfterFailure(t *testing.T) {
+	memfs := fs.NewMemoryFS()
+	memfs.AddDir("templates")
+	memfs.AddDir("templates/auth")
+	memfs.AddFile("templates/auth/base.patch", []byte(authPatch))
+	memfs.AddFile("templates/auth/patches/0001-readme.patch", []byte(modifyReadmePatch))
+	memfs.AddDir("templates/auth/patches")
+	memfs.AddFile("project/README.md", []byte("# Project\n"))
+	memfs.AddDir("project/.git")
+
+	exec := &executor.FakeExecutor{
+		Stdout:    " M README.md\x00",
+		ExitCodes: map[string]int{applyCommand("project", "templates/auth/patches/0001-readme.patch"): 1},
+	}
+
+	_, err := ApplyFeatures(context.Background(), memfs, exec, "templates", "project", []string{"auth"}, ApplyOptions{Stash: true})
+	require.Error(t, err)
+
+	commands := commandsOf(exec)
+	assert.Equal(t, []string{
+		reverseCommand("project", "templates/auth/base.patch"),
+		"git -C project stash pop --quiet",
+	}, commands[len(commands)-2:])
+}
+
+func TestApplyFeatures_ReportsStashKeptWhenPopFails(t *testing.T) {
+	memfs := fs.NewMemoryFS()
+	memfs.AddDir("templates")
+	memfs.AddDir("templates/auth")
+	memfs.AddFile("templates/auth/base.patch", []byte(authPatch))
+	memfs.AddFile("templates/auth/patches/0001-readme.patch", []byte(modifyReadmePatch))
+	memfs.AddDir("templates/auth/patches")
+	memfs.AddFile("project/README.md", []byte("# Project\n"))
+	memfs.AddDir("project/.git")
+
+	exec := &executor.FakeExecutor{
+		Stdout:    " M README.md\x00",
+		ExitCodes: map[string]int{"git -C project stash pop --quiet": 1},
+	}
+
+	result, err := ApplyFeatures(context.Background(), memfs, exec, "templates", "project", []string{"auth"}, ApplyOptions{Stash: true})
+	require.NoError(t, err)
+
+	assert.True(t, result.StashKept)
+}
+
+func TestApplyFeatures_DetectsModifiedFilesOutsideGit(t *testing.T) {
+	memfs := fs.NewMemoryFS()
+	memfs.AddDir("templates")
+	memfs.AddDir("templates/auth")
+	memfs.AddFile("templates/auth/base.patch", []byte(authPatch))
+	memfs.AddFile("templates/auth/patches/0001-readme.patch", []byte(modifyReadmePatch))
+	memfs.AddDir("templates/auth/patches")
+	memfs.AddFile("project/README.md", []byte("# My project\n"))
+	exec := &executor.FakeExecutor{}
+
+	_, err := ApplyFeatures(context.Background(), memfs, exec, "templates", "project", []string{"auth"}, ApplyOptions{})
+
+	var dirty *DirtyTreeError
+	require.ErrorAs(t, err, &dirty)
+	assert.Equal(t, []string{"README.md"}, dirty.Files)
+	var conflict *PatchConflictError
+	require.ErrorAs(t, err, &conflict)
+	assert.Equal(t, "README.md", conflict.File)
+	assert.Contains(t, err.Error(), "failed to apply auth")
+	assert.Empty(t, exec.Commands)
+}
+
+func TestApplyFeatures_IgnoresMissingFilesOutsideGit(t *testing.T) {
+	memfs := fs.NewMemoryFS()
+	memfs.AddDir("templates")
+	memfs.AddDir("templates/auth")
+	memfs.AddFile("templates/auth/base.patch", []byte(authPatch))
+	memfs.AddFile("templates/auth/patches/0001-readme.patch", []byte(modifyReadmePatch))
+	memfs.AddDir("templates/auth/patches")
+	memfs.AddFile("project/README.md", []byte("# My project\n"))
+
+	a := &applier{fileSystem: memfs, templatePath: "templates", targetPath: "elsewhere"}
+
+	assert.NoError(t, a.checkModified([]FeatureRef{{Name: "auth"}}))
+}
+
+func TestCheckModified_ReportsMalformedPatch(t *testing.T) {
+	memfs := fs.NewMemoryFS()
+	memfs.AddDir("templates")
+	memfs.AddDir("templates/auth")
+	memfs.AddFile("templates/auth/base.patch", []byte(authPatch))
+	memfs.AddFile("templates/auth/patches/0001-readme.patch", []byte("diff --git a/README.md b/README.md\n--- a/README.md\n+++ b/README.md\n@@ bogus @@\n"))
+	memfs.AddDir("templates/auth/patches")
+	memfs.AddFile("project/README.md", []byte("# Project\n"))
+
+	a := &applier{fileSystem: memfs, templatePath: "templates", targetPath: "project", sources: Sources{"": "templates"}}
+
+	assert.Error(t, a.checkModified([]FeatureRef{{Name: "auth"}}))
+}
+
+func TestApplyFeatures_StashClearsOverlayFileInTheWay(t *testing.T) {
+	memfs := fs.NewMemoryFS()
+	memfs.AddDir("templates")
+	memfs.AddDir("templates/auth")
+	memfs.AddDir("templates/auth/files")
+	memfs.AddFile("templates/auth/files/LICENSE", []byte("MIT\n"))
+	memfs.AddDir("project")
+	memfs.AddDir("project/.git")
+	memfs.AddFile("project/LICENSE", []byte("draft\n"))
+	exec := &executor.FakeExecutor{Stdout: "?? LICENSE\x00"}
+	exec.OnExecute = func(command string) {
+		if strings.Contains(command, " stash push ") {
+			require.NoError(t, memfs.Remove("project/LICENSE"))
+		}
+	}
+
+	_, err := ApplyFeatures(context.Background(), memfs, exec, "templates", "project", []string{"auth"}, ApplyOptions{Stash: true})
+	require.NoError(t, err)
+
+	data, err := memfs.ReadFile("project/LICENSE")
+	require.NoError(t, err)
+	assert.Equal(t, "MIT\n", string(data))
+}
+
+func TestInGitWorkTree_WalksAboveWorkingDirectory(t *testing.T) {
+	wd, err := os.Getwd()
+	require.NoError(t, err)
+	memfs := fs.NewMemoryFS()
+	memfs.AddDir(path.Join(path.Dir(filepath.ToSlash(wd)), ".git"))
+
+	assert.True(t, inGitWorkTree(memfs, "project"))
+	assert.False(t, inGitWorkTree(fs.NewMemoryFS(), "project"))
+}
+
+func TestPorcelainPaths(t *testing.T) {
+	status := " M README.md\x00R  docs/new name.md\x00docs/old name.md\x00?? caf\u00e9.txt\x00"
+
+	assert.Equal(t, []string{"README.md", "docs/new name.md", "docs/old name.md", "caf\u00e9.txt"}, porcelainPaths(status))
+}
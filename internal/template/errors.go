@@ -0,0 +1,202 @@
+package template
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"templater/internal/executor"
+	"templater/internal/fs"
+	"templater/internal/patch"
+)
+
+type FeatureNotFoundError struct {
+	Feature string
+}
+
+func (e *FeatureNotFoundError) Error() string {
+	return fmt.Sprintf("feature not found: %s", e.Feature)
+}
+
+type PresetNotFoundError struct {
+	Name      string
+	Available []string
+}
+
+func (e *PresetNotFoundError) Error() string {
+	if len(e.Available) == 0 {
+		return fmt.Sprintf("preset not found: %s (template defines no presets)", e.Name)
+	}
+	return fmt.Sprintf("preset not found: %s (available: %s)", e.Name, strings.Join(e.Available, ", "))
+}
+
+type VariantNotFoundError struct {
+	Feature   string
+	Variant   string
+	Available []string
+}
+
+func (e *VariantNotFoundError) Error() string {
+	if len(e.Available) == 0 {
+		return fmt.Sprintf("variant not found: %s[%s] (feature has no variants)", e.Feature, e.Variant)
+	}
+	return fmt.Sprintf("variant not found: %s[%s] (available: %s)", e.Feature, e.Variant, strings.Join(e.Available, ", "))
+}
+
+type DirtyTreeError struct {
+	Target      string
+	Files       []string
+	Overlapping []string
+	Git         bool
+	Commit      bool
+	Cause       error
+}
+
+func (e *DirtyTreeError) Error() string {
+	if !e.Git {
+		return fmt.Sprintf("%v (locally modified in %s: %s; nothing was changed)", e.Cause, e.Target, strings.Join(e.Files, ", "))
+	}
+	if e.Commit {
+		return fmt.Sprintf("%s has uncommitted changes in files the features change (%s); --commit would include them in the feature commit, so commit or stash them first", e.Target, strings.Join(e.Overlapping, ", "))
+	}
+	overlap := ""
+	if len(e.Overlapping) > 0 {
+		overlap = fmt.Sprintf(", overlapping the patches: %s", strings.Join(e.Overlapping, ", "))
+	}
+	return fmt.Sprintf("%s has uncommitted changes (%s%s); commit them, or pass --stash or --allow-dirty", e.Target, strings.Join(e.Files, ", "), overlap)
+}
+
+func (e *DirtyTreeError) Unwrap() error {
+	return e.Cause
+}
+
+type PatchConflictError struct {
+	Feature   string
+	PatchPath string
+	File      string
+	Line      int
+	Reason    string
+	Hunk      *patch.Hunk
+	Found     []string
+	Stderr    string
+}
+
+func (e *PatchConflictError) Error() string {
+	return fmt.Sprintf("failed to apply %s: %s", e.Feature, strings.TrimSpace(e.Stderr))
+}
+
+type OverlapError struct {
+	Overlaps []Overlap
+}
+
+func (e *OverlapError) Error() string {
+	first := e.Overlaps[0]
+	if len(e.Overlaps) == 1 {
+		return fmt.Sprintf("failed to apply %s: %s", displayFeature(first.Features[0]), first)
+	}
+
+	noun := "paths"
+	for _, o := range e.Overlaps {
+		if o.Kind == OverlapKey {
+			noun = "changes"
+		}
+	}
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "failed to apply %s: %d %s would not apply:", displayFeature(first.Features[0]), len(e.Overlaps), noun)
+	for _, o := range e.Overlaps {
+		fmt.Fprintf(&sb, "\n  %s", o)
+	}
+	return sb.String()
+}
+
+type TimeoutError struct {
+	Feature string
+	Command string
+	Timeout string
+}
+
+func (e *TimeoutError) Error() string {
+	return fmt.Sprintf("timed out after %s applying %s", e.Timeout, e.Feature)
+}
+
+func (e *TimeoutError) Unwrap() error {
+	return executor.ErrTimeout
+}
+
+type RollbackError struct {
+	Cause  error
+	Failed []string
+}
+
+func (e *RollbackError) Error() string {
+	return fmt.Sprintf("%v (rollback failed for %s; target may be left partially patched)", e.Cause, strings.Join(e.Failed, ", "))
+}
+
+func (e *RollbackError) Unwrap() error {
+	return e.Cause
+}
+
+var (
+	patchFailedPattern = regexp.MustCompile(`(?m)^error: patch failed: (.+):(\d+)$`)
+	fileErrorPattern   = regexp.MustCompile(`(?m)^error: (.+): (patch does not apply|already exists in working directory|No such file or directory|does not exist in index)$`)
+)
+
+func newPatchConflictError(fileSystem fs.FileSystem, feature, targetPath, patchPath, stderr string) *PatchConflictError {
+	conflict := &PatchConflictError{Feature: feature, PatchPath: patchPath, Stderr: stderr}
+	prefix := strings.TrimSuffix(targetPath, "/") + "/"
+
+	if m := patchFailedPattern.FindStringSubmatch(stderr); m != nil {
+		conflict.File = strings.TrimPrefix(m[1], prefix)
+		conflict.Line, _ = strconv.Atoi(m[2])
+	}
+	if m := fileErrorPattern.FindStringSubmatch(stderr); m != nil {
+		if conflict.File == "" {
+			conflict.File = strings.TrimPrefix(m[1], prefix)
+		}
+		conflict.Reason = m[2]
+	}
+	if conflict.File == "" {
+		return conflict
+	}
+
+	conflict.Hunk = findRejectedHunk(fileSystem, patchPath, conflict.File, conflict.Line)
+	if conflict.Hunk != nil && conflict.Line > 0 {
+		conflict.Found = readLines(fileSystem, joinPath(targetPath, conflict.File), conflict.Line, conflict.Hunk.OldLines)
+	}
+	return conflict
+}
+
+func findRejectedHunk(fileSystem fs.FileSystem, patchPath, file string, line int) *patch.Hunk {
+	data, err := fileSystem.ReadFile(patchPath)
+	if err != nil {
+		return nil
+	}
+	files, err := patch.Parse(data)
+	if err != nil {
+		return nil
+	}
+	for _, f := range files {
+		if f.Path() != file || len(f.Hunks) == 0 {
+			continue
+		}
+		for i := range f.Hunks {
+			if f.Hunks[i].OldStart == line {
+				return &f.Hunks[i]
+			}
+		}
+		return &f.Hunks[0]
+	}
+	return nil
+}
+
+func readLines(fileSystem fs.FileSystem, filePath string, start, count int) []string {
+	data, err := fileSystem.ReadFile(filePath)
+	if err != nil {
+		return nil
+	}
+	lines := strings.Split(strings.TrimSuffix(string(data), "\n"), "\n")
+	from := min(max(start-1, 0), len(lines))
+	to := min(from+count, len(lines))
+	return lines[from:to]
+}
@@ -0,0 +1,62 @@
+package template
+
+import (
+	"testing"
+
+	"templater/internal/testutil/fs"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const conflictingPatch = "diff --git a/file.txt b/file.txt\n" +
+	"--- a/file.txt\n" +
+	"+++ b/file.txt\n" +
+	"@@ -1,3 +1,3 @@\n" +
+	" a\n" +
+	"-x\n" +
+	"+y\n" +
+	" c\n"
+
+func TestNewPatchConflictError_LocatesRejectedHunk(t *testing.T) {
+	memfs := fs.NewMemoryFS()
+	memfs.AddFile("templates/auth/base.patch", []byte(conflictingPatch))
+	memfs.AddFile("project/file.txt", []byte("a\nb\nc\n"))
+	stderr := "error: patch failed: project/file.txt:1\n" +
+		"error: project/file.txt: patch does not apply\n"
+
+	err := newPatchConflictError(memfs, "auth", "project", "templates/auth/base.patch", stderr)
+
+	assert.Equal(t, "file.txt", err.File)
+	assert.Equal(t, 1, err.Line)
+	assert.Equal(t, "patch does not apply", err.Reason)
+	require.NotNil(t, err.Hunk)
+	assert.Equal(t, "@@ -1,3 +1,3 @@", err.Hunk.Header)
+	assert.Equal(t, []string{"a", "b", "c"}, err.Found)
+	assert.Equal(t, "failed to apply auth: "+
+		"error: patch failed: project/file.txt:1\n"+
+		"error: project/file.txt: patch does not apply", err.Error())
+}
+
+func TestNewPatchConflictError_MissingFile(t *testing.T) {
+	memfs := fs.NewMemoryFS()
+	memfs.AddFile("templates/auth/base.patch", []byte(conflictingPatch))
+	stderr := "error: project/file.txt: No such file or directory\n"
+
+	err := newPatchConflictError(memfs, "auth", "project", "templates/auth/base.patch", stderr)
+
+	assert.Equal(t, "file.txt", err.File)
+	assert.Equal(t, "No such file or directory", err.Reason)
+	require.NotNil(t, err.Hunk)
+	assert.Empty(t, err.Found)
+}
+
+func TestNewPatchConflictError_UnrecognisedStderr(t *testing.T) {
+	memfs := fs.NewMemoryFS()
+
+	err := newPatchConflictError(memfs, "auth", "project", "templates/auth/base.patch", "fatal: corrupt patch")
+
+	assert.Empty(t, err.File)
+	assert.Nil(t, err.Hunk)
+	assert.Equal(t, "failed to apply auth: fatal: corrupt patch", err.Error())
+}
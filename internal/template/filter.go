@@ -0,0 +1,92 @@
+package template
+
+import (
+	"fmt"
+	"path"
+	"regexp"
+	"slices"
+	"strings"
+
+	"templater/internal/fs"
+)
+
+type FeatureFilter struct {
+	Pattern    string
+	Tags       []string
+	Depth      int
+	LeavesOnly bool
+}
+
+func FilterFeatures(fileSystem fs.FileSystem, templatePath string, features []string, filter FeatureFilter) ([]string, error) {
+	match, err := compilePattern(filter.Pattern)
+	if err != nil {
+		return nil, err
+	}
+
+	var result []string
+	for _, feature := range features {
+		if !match(feature) {
+			continue
+		}
+		if filter.Depth > 0 && strings.Count(feature, "/")+1 > filter.Depth {
+			continue
+		}
+		if filter.LeavesOnly && hasDescendant(feature, features) {
+			continue
+		}
+		if len(filter.Tags) > 0 {
+			manifest, err := ReadManifest(fileSystem, templatePath, feature)
+			if err != nil {
+				return nil, err
+			}
+			if !hasAllTags(manifest.Tags, filter.Tags) {
+				continue
+			}
+		}
+		result = append(result, feature)
+	}
+	return result, nil
+}
+
+func compilePattern(pattern string) (func(string) bool, error) {
+	if pattern == "" {
+		return func(string) bool { return true }, nil
+	}
+
+	if len(pattern) > 2 && strings.HasPrefix(pattern, "/") && strings.HasSuffix(pattern, "/") {
+		re, err := regexp.Compile(pattern[1 : len(pattern)-1])
+		if err != nil {
+			return nil, fmt.Errorf("invalid filter %s: %w", pattern, err)
+		}
+		return re.MatchString, nil
+	}
+
+	if _, err := path.Match(pattern, ""); err != nil {
+		return nil, fmt.Errorf("invalid filter %s: %w", pattern, err)
+	}
+	return func(feature string) bool {
+		if matched, _ := path.Match(pattern, feature); matched {
+			return true
+		}
+		matched, _ := path.Match(pattern, path.Base(feature))
+		return matched
+	}, nil
+}
+
+func hasDescendant(feature string, features []string) bool {
+	for _, other := range features {
+		if strings.HasPrefix(other, feature+"/") {
+			return true
+		}
+	}
+	return false
+}
+
+func hasAllTags(have, want []string) bool {
+	for _, tag := range want {
+		if !slices.Contains(have, tag) {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,72 @@
+package template
+
+import (
+	"testing"
+
+	"templater/internal/testutil/fs"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var filterFeatures = []string{
+	"auth",
+	"auth/oauth",
+	"auth/oauth/github",
+	"auth/oauth/google",
+	"database",
+	"database/postgres",
+}
+
+func TestFilterFeatures_NoFilterKeepsAll(t *testing.T) {
+	result, err := FilterFeatures(fs.NewMemoryFS(), "templates", filterFeatures, FeatureFilter{})
+	require.NoError(t, err)
+	assert.Equal(t, filterFeatures, result)
+}
+
+func TestFilterFeatures_GlobMatchesPathOrName(t *testing.T) {
+	result, err := FilterFeatures(fs.NewMemoryFS(), "templates", filterFeatures, FeatureFilter{Pattern: "auth/oauth/*"})
+	require.NoError(t, err)
+	assert.Equal(t, []string{"auth/oauth/github", "auth/oauth/google"}, result)
+
+	result, err = FilterFeatures(fs.NewMemoryFS(), "templates", filterFeatures, FeatureFilter{Pattern: "g*"})
+	require.NoError(t, err)
+	assert.Equal(t, []string{"auth/oauth/github", "auth/oauth/google"}, result)
+}
+
+func TestFilterFeatures_Regex(t *testing.T) {
+	result, err := FilterFeatures(fs.NewMemoryFS(), "templates", filterFeatures, FeatureFilter{Pattern: "/^(auth|database)$/"})
+	require.NoError(t, err)
+	assert.Equal(t, []string{"auth", "database"}, result)
+}
+
+func TestFilterFeatures_InvalidRegex(t *testing.T) {
+	_, err := FilterFeatures(fs.NewMemoryFS(), "templates", filterFeatures, FeatureFilter{Pattern: "/(/"})
+	assert.ErrorContains(t, err, "invalid filter /(/")
+}
+
+func TestFilterFeatures_Depth(t *testing.T) {
+	result, err := FilterFeatures(fs.NewMemoryFS(), "templates", filterFeatures, FeatureFilter{Depth: 2})
+	require.NoError(t, err)
+	assert.Equal(t, []string{"auth", "auth/oauth", "database", "database/postgres"}, result)
+}
+
+func TestFilterFeatures_LeavesOnly(t *testing.T) {
+	result, err := FilterFeatures(fs.NewMemoryFS(), "templates", filterFeatures, FeatureFilter{LeavesOnly: true})
+	require.NoError(t, err)
+	assert.Equal(t, []string{"auth/oauth/github", "auth/oauth/google", "database/postgres"}, result)
+}
+
+func TestFilterFeatures_Tags(t *testing.T) {
+	memfs := fs.NewMemoryFS()
+	memfs.AddFile("templates/auth/oauth/github/feature.yml", []byte("tags: [oauth, vendor]\n"))
+	memfs.AddFile("templates/auth/oauth/google/feature.yml", []byte("tags: [oauth]\n"))
+
+	result, err := FilterFeatures(memfs, "templates", filterFeatures, FeatureFilter{Tags: []string{"oauth"}})
+	require.NoError(t, err)
+	assert.Equal(t, []string{"auth/oauth/github", "auth/oauth/google"}, result)
+
+	result, err = FilterFeatures(memfs, "templates", filterFeatures, FeatureFilter{Tags: []string{"oauth", "vendor"}})
+	require.NoError(t, err)
+	assert.Equal(t, []string{"auth/oauth/github"}, result)
+}
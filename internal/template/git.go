@@ -0,0 +1,85 @@
+package template
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"path"
+	"path/filepath"
+	"strings"
+
+	"templater/internal/executor"
+	"templater/internal/fs"
+)
+
+func runGit(ctx context.Context, exec executor.Executor, cmd, timeout, stdin string) (string, error) {
+	stdout, stderr, exitCode, err := exec.ExecuteWithStdin(ctx, cmd, timeout, nil, stdin)
+	if errors.Is(err, executor.ErrTimeout) {
+		return "", fmt.Errorf("%s timed out after %s", cmd, timeout)
+	}
+	if err != nil {
+		return "", err
+	}
+	if exitCode != 0 {
+		return "", fmt.Errorf("%s failed: %s", cmd, strings.TrimSpace(stderr))
+	}
+	return stdout, nil
+}
+
+// shellQuote quotes s for sh when it holds anything beyond the characters
+// that are safe unquoted.
+func shellQuote(s string) string {
+	if s != "" && strings.Trim(s, "abcdefghijklmnopqrstuvwxyzABCDEFGHIJKLMNOPQRSTUVWXYZ0123456789@%+=:,./_-") == "" {
+		return s
+	}
+	return "'" + strings.ReplaceAll(s, "'", `'\''`) + "'"
+}
+
+func shellJoin(words []string) string {
+	quoted := make([]string, len(words))
+	for i, word := range words {
+		quoted[i] = shellQuote(word)
+	}
+	return strings.Join(quoted, " ")
+}
+
+// porcelainPaths reads `git status --porcelain -z`, which leaves paths
+// unquoted and follows a rename or copy with its original path.
+func porcelainPaths(status string) []string {
+	var paths []string
+	entries := strings.Split(status, "\x00")
+	for i := 0; i < len(entries); i++ {
+		entry := entries[i]
+		if len(entry) <= 3 {
+			continue
+		}
+		paths = append(paths, entry[3:])
+		if (entry[0] == 'R' || entry[0] == 'C') && i+1 < len(entries) {
+			i++
+			paths = append(paths, entries[i])
+		}
+	}
+	return paths
+}
+
+// inGitWorkTree looks for a .git entry in dir or any directory above it. A
+// relative dir is walked up to the working directory and then, resolved to
+// an absolute path, on to the root.
+func inGitWorkTree(fileSystem fs.FileSystem, dir string) bool {
+	for d := dir; ; d = path.Dir(d) {
+		if _, err := fileSystem.Stat(path.Join(d, ".git")); err == nil {
+			return true
+		}
+		if d == "." || d == "/" || path.Dir(d) == d {
+			break
+		}
+	}
+	if path.IsAbs(dir) {
+		return false
+	}
+	abs, err := filepath.Abs(dir)
+	if err != nil {
+		return false
+	}
+	return inGitWorkTree(fileSystem, filepath.ToSlash(abs))
+}
@@ -0,0 +1,146 @@
+package template
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path"
+	"strings"
+
+	"templater/internal/executor"
+	"templater/internal/fs"
+)
+
+type InitOptions struct {
+	Git    bool
+	Commit bool
+	Apply  ApplyOptions
+}
+
+type InitResult struct {
+	Applied          []string
+	TemplateVersions map[string]string
+	Committed        bool
+}
+
+func InitProject(ctx context.Context, fileSystem fs.FileSystem, exec executor.Executor, templatePath, targetPath string, features []string, opts InitOptions) (result *InitResult, err error) {
+	created, err := ensureEmptyDir(fileSystem, targetPath)
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		if err == nil {
+			return
+		}
+		if cleanupErr := clearDir(fileSystem, targetPath, created); cleanupErr != nil {
+			err = &RollbackError{Cause: err, Failed: []string{targetPath}}
+		}
+	}()
+
+	timeout := opts.Apply.Timeout
+	if timeout == "" {
+		timeout = DefaultTimeout
+	}
+
+	if opts.Git {
+		if _, err := runGit(ctx, exec, fmt.Sprintf("git init --quiet %s", shellQuote(targetPath)), timeout, ""); err != nil {
+			return nil, err
+		}
+	}
+
+	if len(features) == 0 && hasRootPatch(fileSystem, templatePath) {
+		features = []string{""}
+	}
+
+	result = &InitResult{}
+	if len(features) > 0 {
+		applied, err := ApplyFeatures(ctx, fileSystem, exec, templatePath, targetPath, features, opts.Apply)
+		if err != nil {
+			return nil, err
+		}
+		result.Applied = applied.Applied
+		result.TemplateVersions = applied.TemplateVersions
+	}
+
+	if err := RecordApplied(fileSystem, targetPath, result.Applied, result.TemplateVersions); err != nil {
+		return nil, fmt.Errorf("failed to update applied.yml: %w", err)
+	}
+
+	if opts.Git && opts.Commit {
+		if _, err := runGit(ctx, exec, fmt.Sprintf("git -C %s add -A", shellQuote(targetPath)), timeout, ""); err != nil {
+			return nil, err
+		}
+		cmd := fmt.Sprintf("git -C %s commit --quiet -F -", shellQuote(targetPath))
+		if _, err := runGit(ctx, exec, cmd, timeout, initCommitMessage(result.Applied)); err != nil {
+			return nil, err
+		}
+		result.Committed = true
+	}
+
+	return result, nil
+}
+
+// ensureEmptyDir reports whether it had to create dir.
+func ensureEmptyDir(fileSystem fs.FileSystem, dir string) (bool, error) {
+	entries, err := fileSystem.ReadDir(dir)
+	if errors.Is(err, os.ErrNotExist) {
+		return true, fileSystem.MkdirAll(dir)
+	}
+	if err != nil {
+		return false, err
+	}
+	if len(entries) > 0 {
+		return false, fmt.Errorf("%s already exists and is not empty", dir)
+	}
+	return false, nil
+}
+
+// clearDir undoes a failed init: it removes dir if init created it, and
+// otherwise only what init wrote into it.
+func clearDir(fileSystem fs.FileSystem, dir string, created bool) error {
+	if created {
+		return removeAll(fileSystem, dir, true)
+	}
+	entries, err := fileSystem.ReadDir(dir)
+	if err != nil {
+		return err
+	}
+	for _, entry := range entries {
+		if err := removeAll(fileSystem, path.Join(dir, entry.Name()), entry.IsDir()); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func removeAll(fileSystem fs.FileSystem, name string, dir bool) error {
+	if dir {
+		entries, err := fileSystem.ReadDir(name)
+		if err != nil {
+			return err
+		}
+		for _, entry := range entries {
+			if err := removeAll(fileSystem, path.Join(name, entry.Name()), entry.IsDir()); err != nil {
+				return err
+			}
+		}
+	}
+	if err := fileSystem.Remove(name); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return err
+	}
+	return nil
+}
+
+func initCommitMessage(applied []string) string {
+	if len(applied) == 0 {
+		return "Initialize project from template\n"
+	}
+
+	var sb strings.Builder
+	sb.WriteString("Initialize project from template\n\nApplied features:\n")
+	for _, feature := range applied {
+		fmt.Fprintf(&sb, "- %s\n", displayFeature(feature))
+	}
+	return sb.String()
+}
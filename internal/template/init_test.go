@@ -0,0 +1,150 @@
+package template
+
+import (
+	"context"
+	"testing"
+
+	"templater/internal/testutil/executor"
+	"templater/internal/testutil/fs"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func commandsOf(exec *executor.FakeExecutor) []string {
+	var commands []string
+	for _, c := range exec.Commands {
+		commands = append(commands, c.Command)
+	}
+	return commands
+}
+
+func TestInitProject_InitialisesAppliesAndCommits(t *testing.T) {
+	memfs := fs.NewMemoryFS()
+	memfs.AddDir("templates")
+	memfs.AddDir("templates/auth")
+	memfs.AddFile("templates/base.patch", []byte("root"))
+	memfs.AddFile("templates/auth/base.patch", []byte("auth"))
+	exec := &executor.FakeExecutor{}
+
+	result, err := InitProject(context.Background(), memfs, exec, "templates", "service", []string{"auth"}, InitOptions{Git: true, Commit: true})
+	require.NoError(t, err)
+
+	assert.Equal(t, []string{"", "auth"}, result.Applied)
+	assert.True(t, result.Committed)
+	assert.Equal(t, []string{
+		"git init --quiet service",
+		applyCommand("service", "templates/base.patch"),
+		applyCommand("service", "templates/auth/base.patch"),
+		"git -C service add -A",
+		"git -C service commit --quiet -F -",
+	}, commandsOf(exec))
+	assert.Equal(t, "Initialize project from template\n\nApplied features:\n- (root)\n- auth\n", exec.StdinReceived)
+
+	applied, err := ReadApplied(memfs, "service")
+	require.NoError(t, err)
+	assert.Equal(t, []string{"", "auth"}, applied)
+}
+
+func TestInitProject_AppliesRootWithoutFeatures(t *testing.T) {
+	memfs := fs.NewMemoryFS()
+	memfs.AddDir("templates")
+	memfs.AddDir("templates/auth")
+	memfs.AddFile("templates/base.patch", []byte("root"))
+	memfs.AddFile("templates/auth/base.patch", []byte("auth"))
+
+	exec := &executor.FakeExecutor{}
+
+	result, err := InitProject(context.Background(), memfs, exec, "templates", "service", nil, InitOptions{})
+	require.NoError(t, err)
+
+	assert.Equal(t, []string{""}, result.Applied)
+	assert.False(t, result.Committed)
+	assert.Equal(t, []string{applyCommand("service", "templates/base.patch")}, commandsOf(exec))
+}
+
+func TestInitProject_RefusesNonEmptyDirectory(t *testing.T) {
+	memfs := fs.NewMemoryFS()
+	memfs.AddDir("templates")
+	memfs.AddDir("templates/auth")
+	memfs.AddFile("templates/base.patch", []byte("root"))
+	memfs.AddFile("templates/auth/base.patch", []byte("auth"))
+	memfs.AddDir("service")
+	memfs.AddFile("service/README.md", []byte("existing"))
+	exec := &executor.FakeExecutor{}
+
+	_, err := InitProject(context.Background(), memfs, exec, "templates", "service", nil, InitOptions{Git: true})
+
+	assert.EqualError(t, err, "service already exists and is not empty")
+	assert.Empty(t, exec.Commands)
+}
+
+func TestInitProject_ReportsGitFailure(t *testing.T) {
+	memfs := fs.NewMemoryFS()
+	memfs.AddDir("templates")
+	memfs.AddDir("templates/auth")
+	memfs.AddFile("templates/base.patch", []byte("root"))
+	memfs.AddFile("templates/auth/base.patch", []byte("auth"))
+
+	exec := &executor.FakeExecutor{
+		ExitCodes: map[string]int{"git -C service commit --quiet -F -": 128},
+		Stderr:    "Please tell me who you are.\n",
+	}
+
+	_, err := InitProject(context.Background(), memfs, exec, "templates", "service", nil, InitOptions{Git: true, Commit: true})
+
+	assert.EqualError(t, err, "git -C service commit --quiet -F - failed: Please tell me who you are.")
+}
+
+func TestInitProject_RemovesCreatedDirectoryOnFailure(t *testing.T) {
+	memfs := fs.NewMemoryFS()
+	memfs.AddDir("templates")
+	memfs.AddDir("templates/auth")
+	memfs.AddFile("templates/base.patch", []byte("root"))
+	memfs.AddFile("templates/auth/base.patch", []byte("auth"))
+	exec := &executor.FakeExecutor{
+		ExitCodes: map[string]int{applyCommand("service", "templates/auth/base.patch"): 1},
+	}
+
+	_, err := InitProject(context.Background(), memfs, exec, "templates", "service", []string{"auth"}, InitOptions{Git: true})
+	require.Error(t, err)
+
+	_, err = memfs.Stat("service")
+	assert.Error(t, err)
+}
+
+func TestInitProject_EmptiesExistingDirectoryOnFailure(t *testing.T) {
+	memfs := fs.NewMemoryFS()
+	memfs.AddDir("templates")
+	memfs.AddDir("templates/auth")
+	memfs.AddFile("templates/base.patch", []byte("root"))
+	memfs.AddFile("templates/auth/base.patch", []byte("auth"))
+	memfs.AddDir("service")
+	exec := &executor.FakeExecutor{
+		ExitCodes: map[string]int{"git -C service commit --quiet -F -": 128},
+	}
+
+	_, err := InitProject(context.Background(), memfs, exec, "templates", "service", nil, InitOptions{Git: true, Commit: true})
+	require.Error(t, err)
+
+	entries, err := memfs.ReadDir("service")
+	require.NoError(t, err)
+	assert.Empty(t, entries)
+}
+
+func TestInitProject_QuotesTargetPath(t *testing.T) {
+	memfs := fs.NewMemoryFS()
+	memfs.AddDir("templates")
+	memfs.AddDir("templates/auth")
+	memfs.AddFile("templates/base.patch", []byte("root"))
+	memfs.AddFile("templates/auth/base.patch", []byte("auth"))
+
+	exec := &executor.FakeExecutor{}
+
+	_, err := InitProject(context.Background(), memfs, exec, "templates", "my service", nil, InitOptions{Git: true, Commit: true})
+	require.NoError(t, err)
+
+	commands := commandsOf(exec)
+	assert.Equal(t, "git init --quiet 'my service'", commands[0])
+	assert.Equal(t, "git -C 'my service' commit --quiet -F -", commands[len(commands)-1])
+}
@@ -0,0 +1,232 @@
+package template
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path"
+	"slices"
+	"strings"
+)
+
+type Injection struct {
+	File    string `yaml:"file"`
+	Anchor  string `yaml:"anchor"`
+	Snippet string `yaml:"snippet"`
+}
+
+type injectedBlock struct {
+	feature    string
+	start, end int
+}
+
+func validateInjections(injections []Injection) error {
+	for i, inj := range injections {
+		switch {
+		case inj.File == "" || inj.Anchor == "" || inj.Snippet == "":
+			return fmt.Errorf("inject[%d]: file, anchor and snippet are required", i)
+		case path.IsAbs(inj.File) || path.Clean(inj.File) != inj.File || inj.File == ".." || strings.HasPrefix(inj.File, "../"):
+			return fmt.Errorf("inject[%d]: file %q is not a relative path inside the target", i, inj.File)
+		case strings.Contains(inj.Anchor, "\n") || strings.TrimSpace(inj.Anchor) != inj.Anchor:
+			return fmt.Errorf("inject[%d]: anchor must be a single line without surrounding spaces", i)
+		}
+	}
+	return nil
+}
+
+func injectSnippet(content string, inj Injection, feature string) (string, string, error) {
+	lines := strings.Split(content, "\n")
+	at, err := findAnchor(lines, inj.Anchor)
+	if err != nil {
+		return "", "", err
+	}
+	if at < 0 {
+		return "", "", errAnchorNotFound
+	}
+	blocks, err := anchorBlocks(lines, at, inj.Anchor)
+	if err != nil {
+		return "", "", err
+	}
+
+	indent := lines[at][:len(lines[at])-len(strings.TrimLeft(lines[at], " \t"))]
+	name := displayFeature(feature)
+	body := snippetLines(indent, inj.Snippet)
+	sum := checksum([]byte(strings.Join(body, "\n")))
+	insertAt := at + 1
+	for _, b := range blocks {
+		if b.feature == name {
+			if slices.Equal(lines[b.start+1:b.end], body) {
+				return content, sum, nil
+			}
+			return "", "", fmt.Errorf("already has a different %s block at %q", name, inj.Anchor)
+		}
+		if b.feature < name {
+			insertAt = b.end + 1
+		}
+	}
+
+	block := append([]string{indent + inj.Anchor + " begin " + name}, body...)
+	block = append(block, indent+inj.Anchor+" end "+name)
+	return strings.Join(slices.Insert(lines, insertAt, block...), "\n"), sum, nil
+}
+
+func ejectSnippet(content, anchor, feature, sum string) (string, bool, error) {
+	lines := strings.Split(content, "\n")
+	at, err := findAnchor(lines, anchor)
+	if err != nil {
+		return "", false, err
+	}
+	if at < 0 {
+		return content, true, nil
+	}
+	blocks, err := anchorBlocks(lines, at, anchor)
+	if err != nil {
+		return "", false, err
+	}
+
+	for _, b := range blocks {
+		if b.feature != displayFeature(feature) {
+			continue
+		}
+		if checksum([]byte(strings.Join(lines[b.start+1:b.end], "\n"))) != sum {
+			return content, false, nil
+		}
+		return strings.Join(slices.Delete(lines, b.start, b.end+1), "\n"), true, nil
+	}
+	return content, true, nil
+}
+
+// findAnchor returns the line holding anchor, or -1 when there is none. An
+// anchor on several lines is ambiguous, so it is an error.
+func findAnchor(lines []string, anchor string) (int, error) {
+	at := -1
+	for i, line := range lines {
+		if strings.TrimSpace(line) != anchor {
+			continue
+		}
+		if at >= 0 {
+			return -1, fmt.Errorf("anchor %q appears more than once (lines %d and %d)", anchor, at+1, i+1)
+		}
+		at = i
+	}
+	return at, nil
+}
+
+// anchorBlocks returns the blocks injected at the anchor on line at. They are
+// looked for in the whole rest of the file, since lines may have been edited
+// in between them since they were injected.
+func anchorBlocks(lines []string, at int, anchor string) ([]injectedBlock, error) {
+	begin := anchor + " begin "
+	var blocks []injectedBlock
+	for i := at + 1; i < len(lines); i++ {
+		marker := strings.TrimSpace(lines[i])
+		if !strings.HasPrefix(marker, begin) {
+			continue
+		}
+		name := strings.TrimPrefix(marker, begin)
+		end := anchor + " end " + name
+		j := i + 1
+		for j < len(lines) && strings.TrimSpace(lines[j]) != end {
+			j++
+		}
+		if j == len(lines) {
+			return nil, fmt.Errorf("%q has no matching %q", marker, end)
+		}
+		blocks = append(blocks, injectedBlock{feature: name, start: i, end: j})
+		i = j
+	}
+	return blocks, nil
+}
+
+func snippetLines(indent, snippet string) []string {
+	lines := strings.Split(strings.TrimRight(snippet, "\n"), "\n")
+	for i, line := range lines {
+		if line != "" {
+			lines[i] = indent + line
+		}
+	}
+	return lines
+}
+
+var errAnchorNotFound = errors.New("anchor not found")
+
+func injectionConflict(feature, source string, inj Injection, err error) *PatchConflictError {
+	reason := err.Error()
+	if errors.Is(err, errAnchorNotFound) {
+		reason = fmt.Sprintf("anchor %q not found", inj.Anchor)
+	}
+	return &PatchConflictError{
+		Feature:   feature,
+		PatchPath: source,
+		File:      inj.File,
+		Reason:    reason,
+		Stderr:    "error: " + inj.File + ": " + reason,
+	}
+}
+
+func (a *applier) injections(ref FeatureRef) ([]Injection, error) {
+	if _, baseApplied := a.previous[ref.Name]; baseApplied {
+		return nil, nil
+	}
+	templatePath, local := a.sources.locate(ref)
+	manifest, err := ReadManifest(a.fileSystem, templatePath, local.Name)
+	if err != nil {
+		return nil, err
+	}
+	return manifest.Inject, nil
+}
+
+func (a *applier) applyInjections(ref FeatureRef) ([]InjectionState, error) {
+	injections, err := a.injections(ref)
+	if err != nil {
+		return nil, err
+	}
+
+	templatePath, local := a.sources.locate(ref)
+	source := manifestPath(templatePath, local.Name)
+	var records []InjectionState
+	for _, inj := range injections {
+		targetFile := path.Join(a.targetPath, inj.File)
+		data, err := a.fileSystem.ReadFile(targetFile)
+		if errors.Is(err, os.ErrNotExist) {
+			return nil, injectionConflict(ref.String(), source, inj, errors.New("No such file or directory"))
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		injected, sum, err := injectSnippet(string(data), inj, ref.Name)
+		if err != nil {
+			return nil, injectionConflict(ref.String(), source, inj, err)
+		}
+		if injected != string(data) {
+			a.backup(ref, targetFile, data, true)
+			if err := a.fileSystem.WriteFile(targetFile, []byte(injected)); err != nil {
+				return nil, err
+			}
+		}
+		records = append(records, InjectionState{Path: inj.File, Anchor: inj.Anchor, Feature: ref.Name, SHA256: sum})
+	}
+	return records, nil
+}
+
+func (a *applier) eject(ref FeatureRef, record InjectionState) (bool, error) {
+	targetFile := path.Join(a.targetPath, record.Path)
+	data, err := a.fileSystem.ReadFile(targetFile)
+	if errors.Is(err, os.ErrNotExist) {
+		return true, nil
+	}
+	if err != nil {
+		return false, err
+	}
+
+	ejected, removed, err := ejectSnippet(string(data), record.Anchor, record.Feature, record.SHA256)
+	if err != nil {
+		return false, fmt.Errorf("%s: %w", record.Path, err)
+	}
+	if !removed || ejected == string(data) {
+		return removed, nil
+	}
+	a.backup(ref, targetFile, data, true)
+	return true, a.fileSystem.WriteFile(targetFile, []byte(ejected))
+}
@@ -0,0 +1,274 @@
+package template
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"templater/internal/testutil/executor"
+	"templater/internal/testutil/fs"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const routerGo = `package main
+
+func routes(r *Router) {
+	// templater:routes
+	r.Handle("/", index)
+}
+`
+
+func TestInjectSnippet_OrdersBlocksByFeature(t *testing.T) {
+	inj := Injection{File: "router.go", Anchor: "// templater:routes", Snippet: "r.Handle(\"/b\", b)\n"}
+	content, _, err := injectSnippet(routerGo, inj, "billing")
+	require.NoError(t, err)
+
+	inj.Snippet = "r.Handle(\"/a\", a)\n"
+	content, _, err = injectSnippet(content, inj, "auth")
+	require.NoError(t, err)
+
+	assert.Equal(t, `package main
+
+func routes(r *Router) {
+	// templater:routes
+	// templater:routes begin auth
+	r.Handle("/a", a)
+	// templater:routes end auth
+	// templater:routes begin billing
+	r.Handle("/b", b)
+	// templater:routes end billing
+	r.Handle("/", index)
+}
+`, content)
+}
+
+func TestInjectSnippet_IsIdempotent(t *testing.T) {
+	inj := Injection{File: "router.go", Anchor: "// templater:routes", Snippet: "r.Handle(\"/a\", a)\n"}
+	once, sum, err := injectSnippet(routerGo, inj, "auth")
+	require.NoError(t, err)
+
+	twice, again, err := injectSnippet(once, inj, "auth")
+	require.NoError(t, err)
+
+	assert.Equal(t, once, twice)
+	assert.Equal(t, sum, again)
+}
+
+func TestInjectSnippet_FindsBlocksSeparatedByEdits(t *testing.T) {
+	inj := Injection{File: "router.go", Anchor: "// templater:routes", Snippet: "r.Handle(\"/b\", b)\n"}
+	content, sum, err := injectSnippet(routerGo, inj, "billing")
+	require.NoError(t, err)
+	edited := strings.Replace(content, "\t// templater:routes begin billing", "\tr.Handle(\"/health\", health)\n\t// templater:routes begin billing", 1)
+
+	again, _, err := injectSnippet(edited, inj, "billing")
+	require.NoError(t, err)
+	assert.Equal(t, edited, again)
+
+	ejected, removed, err := ejectSnippet(edited, inj.Anchor, "billing", sum)
+	require.NoError(t, err)
+	assert.True(t, removed)
+	assert.Equal(t, strings.Replace(routerGo, "\tr.Handle(\"/\"", "\tr.Handle(\"/health\", health)\n\tr.Handle(\"/\"", 1), ejected)
+}
+
+func TestInjectSnippet_RejectsRepeatedAnchor(t *testing.T) {
+	inj := Injection{File: "router.go", Anchor: "// templater:routes", Snippet: "r.Handle(\"/a\", a)\n"}
+	content := routerGo + "\n// templater:routes\n"
+
+	_, _, err := injectSnippet(content, inj, "auth")
+	assert.EqualError(t, err, `anchor "// templater:routes" appears more than once (lines 4 and 8)`)
+
+	_, _, err = ejectSnippet(content, inj.Anchor, "auth", "")
+	assert.EqualError(t, err, `anchor "// templater:routes" appears more than once (lines 4 and 8)`)
+}
+
+func TestInjectSnippet_RejectsDifferentExistingBlock(t *testing.T) {
+	inj := Injection{File: "router.go", Anchor: "// templater:routes", Snippet: "r.Handle(\"/a\", a)\n"}
+	content, _, err := injectSnippet(routerGo, inj, "auth")
+	require.NoError(t, err)
+
+	inj.Snippet = "r.Handle(\"/signin\", a)\n"
+	_, _, err = injectSnippet(content, inj, "auth")
+
+	assert.EqualError(t, err, `already has a different auth block at "// templater:routes"`)
+}
+
+func TestEjectSnippet_KeepsModifiedBlock(t *testing.T) {
+	inj := Injection{File: "router.go", Anchor: "// templater:routes", Snippet: "r.Handle(\"/a\", a)\n"}
+	content, sum, err := injectSnippet(routerGo, inj, "auth")
+	require.NoError(t, err)
+
+	ejected, removed, err := ejectSnippet(content, inj.Anchor, "auth", sum)
+	require.NoError(t, err)
+	assert.True(t, removed)
+	assert.Equal(t, routerGo, ejected)
+
+	modified := strings.Replace(content, `"/a"`, `"/signin"`, 1)
+	_, removed, err = ejectSnippet(modified, inj.Anchor, "auth", sum)
+	require.NoError(t, err)
+	assert.False(t, removed)
+}
+
+func TestReadManifest_ValidatesInjections(t *testing.T) {
+	memfs := fs.NewMemoryFS()
+	memfs.AddDir("templates")
+	memfs.AddDir("templates/auth")
+	memfs.AddFile("templates/auth/base.patch", []byte(authPatch))
+	memfs.AddFile("templates/auth/feature.yml", []byte("inject:\n  - file: ../router.go\n    anchor: // templater:routes\n    snippet: x\n"))
+
+	_, err := ReadManifest(memfs, "templates", "auth")
+
+	assert.ErrorContains(t, err, `inject[0]: file "../router.go" is not a relative path inside the target`)
+}
+
+func TestApplyFeatures_InjectsSnippetsAndRecordsState(t *testing.T) {
+	memfs := fs.NewMemoryFS()
+	memfs.AddDir("templates")
+	memfs.AddDir("templates/auth")
+	memfs.AddFile("templates/auth/base.patch", []byte(authPatch))
+	memfs.AddFile("templates/auth/feature.yml", []byte("inject:\n  - file: router.go\n    anchor: // templater:routes\n    snippet: |\n      r.Handle(\"/login\", login)\n"))
+	memfs.AddDir("templates/billing")
+	memfs.AddFile("templates/billing/feature.yml", []byte("inject:\n  - file: router.go\n    anchor: // templater:routes\n    snippet: |\n      r.Handle(\"/invoices\", invoices)\n"))
+	memfs.AddDir("project")
+	memfs.AddFile("project/router.go", []byte(routerGo))
+
+	_, err := ApplyFeatures(context.Background(), memfs, &executor.FakeExecutor{}, "templates", "project", []string{"billing", "auth"}, ApplyOptions{})
+	require.NoError(t, err)
+
+	data, err := memfs.ReadFile("project/router.go")
+	require.NoError(t, err)
+	assert.Contains(t, string(data), "// templater:routes begin auth\n\tr.Handle(\"/login\", login)\n\t// templater:routes end auth\n\t// templater:routes begin billing\n")
+
+	state, err := ReadState(memfs, "project")
+	require.NoError(t, err)
+	require.Len(t, state.Injections, 2)
+	assert.Equal(t, InjectionState{Path: "router.go", Anchor: "// templater:routes", Feature: "auth", SHA256: checksum([]byte("\tr.Handle(\"/login\", login)"))}, state.Injections[1])
+}
+
+func TestApplyFeatures_MissingAnchorIsReportedBeforeApplying(t *testing.T) {
+	memfs := fs.NewMemoryFS()
+	memfs.AddDir("templates")
+	memfs.AddDir("templates/auth")
+	memfs.AddFile("templates/auth/base.patch", []byte(authPatch))
+	memfs.AddFile("templates/auth/feature.yml", []byte("inject:\n  - file: router.go\n    anchor: // templater:routes\n    snippet: |\n      r.Handle(\"/login\", login)\n"))
+	memfs.AddDir("templates/billing")
+	memfs.AddFile("templates/billing/feature.yml", []byte("inject:\n  - file: router.go\n    anchor: // templater:routes\n    snippet: |\n      r.Handle(\"/invoices\", invoices)\n"))
+	memfs.AddDir("project")
+	memfs.AddFile("project/router.go", []byte("package main\n"))
+
+	_, err := ApplyFeatures(context.Background(), memfs, &executor.FakeExecutor{}, "templates", "project", []string{"auth"}, ApplyOptions{})
+
+	var conflict *PatchConflictError
+	require.ErrorAs(t, err, &conflict)
+	assert.Equal(t, "router.go", conflict.File)
+	assert.Equal(t, "templates/auth/feature.yml", conflict.PatchPath)
+	assert.Equal(t, `anchor "// templater:routes" not found`, conflict.Reason)
+}
+
+func TestApplyFeatures_MissingInjectionFileIsReportedBeforeApplying(t *testing.T) {
+	memfs := fs.NewMemoryFS()
+	memfs.AddDir("templates")
+	memfs.AddDir("templates/auth")
+	memfs.AddFile("templates/auth/base.patch", []byte(authPatch))
+	memfs.AddFile("templates/auth/feature.yml", []byte("inject:\n  - file: router.go\n    anchor: // templater:routes\n    snippet: |\n      r.Handle(\"/login\", login)\n"))
+	memfs.AddDir("templates/billing")
+	memfs.AddFile("templates/billing/feature.yml", []byte("inject:\n  - file: router.go\n    anchor: // templater:routes\n    snippet: |\n      r.Handle(\"/invoices\", invoices)\n"))
+	memfs.AddDir("project")
+	exec := &executor.FakeExecutor{}
+
+	_, err := ApplyFeatures(context.Background(), memfs, exec, "templates", "project", []string{"auth"}, ApplyOptions{})
+
+	var overlap *OverlapError
+	require.ErrorAs(t, err, &overlap)
+	assert.Equal(t, OverlapMissing, overlap.Overlaps[0].Kind)
+	assert.Empty(t, exec.Commands)
+}
+
+func TestApplyFeatures_RollbackRestoresInjectedFiles(t *testing.T) {
+	memfs := fs.NewMemoryFS()
+	memfs.AddDir("templates")
+	memfs.AddDir("templates/auth")
+	memfs.AddFile("templates/auth/base.patch", []byte(authPatch))
+	memfs.AddFile("templates/auth/feature.yml", []byte("inject:\n  - file: router.go\n    anchor: // templater:routes\n    snippet: |\n      r.Handle(\"/login\", login)\n"))
+	memfs.AddDir("templates/billing")
+	memfs.AddFile("templates/billing/feature.yml", []byte("inject:\n  - file: router.go\n    anchor: // templater:routes\n    snippet: |\n      r.Handle(\"/invoices\", invoices)\n"))
+	memfs.AddDir("project")
+	memfs.AddFile("project/router.go", []byte(routerGo))
+	memfs.AddFile("templates/billing/base.patch", []byte("billing"))
+	exec := &executor.FakeExecutor{ExitCodes: map[string]int{applyCommand("project", "templates/billing/base.patch"): 1}}
+
+	_, err := ApplyFeatures(context.Background(), memfs, exec, "templates", "project", []string{"auth", "billing"}, ApplyOptions{})
+	require.Error(t, err)
+
+	data, err := memfs.ReadFile("project/router.go")
+	require.NoError(t, err)
+	assert.Equal(t, routerGo, string(data))
+}
+
+func TestRemoveFeatures_EjectsInjectedBlocks(t *testing.T) {
+	memfs := fs.NewMemoryFS()
+	memfs.AddDir("templates")
+	memfs.AddDir("templates/auth")
+	memfs.AddFile("templates/auth/base.patch", []byte(authPatch))
+	memfs.AddFile("templates/auth/feature.yml", []byte("inject:\n  - file: router.go\n    anchor: // templater:routes\n    snippet: |\n      r.Handle(\"/login\", login)\n"))
+	memfs.AddDir("templates/billing")
+	memfs.AddFile("templates/billing/feature.yml", []byte("inject:\n  - file: router.go\n    anchor: // templater:routes\n    snippet: |\n      r.Handle(\"/invoices\", invoices)\n"))
+	memfs.AddDir("project")
+	memfs.AddFile("project/router.go", []byte(routerGo))
+	applyAndRecord(t, memfs, "auth", "billing")
+
+	result, err := RemoveFeatures(context.Background(), memfs, &executor.FakeExecutor{}, "templates", "project", []string{"auth"}, ApplyOptions{})
+	require.NoError(t, err)
+
+	assert.Empty(t, result.Kept)
+	data, err := memfs.ReadFile("project/router.go")
+	require.NoError(t, err)
+	assert.NotContains(t, string(data), "/login")
+	assert.Contains(t, string(data), "/invoices")
+
+	state, err := ReadState(memfs, "project")
+	require.NoError(t, err)
+	require.Len(t, state.Injections, 1)
+	assert.Equal(t, "billing", state.Injections[0].Feature)
+}
+
+func TestRemoveFeatures_KeepsModifiedInjectedBlock(t *testing.T) {
+	memfs := fs.NewMemoryFS()
+	memfs.AddDir("templates")
+	memfs.AddDir("templates/auth")
+	memfs.AddFile("templates/auth/base.patch", []byte(authPatch))
+	memfs.AddFile("templates/auth/feature.yml", []byte("inject:\n  - file: router.go\n    anchor: // templater:routes\n    snippet: |\n      r.Handle(\"/login\", login)\n"))
+	memfs.AddDir("templates/billing")
+	memfs.AddFile("templates/billing/feature.yml", []byte("inject:\n  - file: router.go\n    anchor: // templater:routes\n    snippet: |\n      r.Handle(\"/invoices\", invoices)\n"))
+	memfs.AddDir("project")
+	memfs.AddFile("project/router.go", []byte(routerGo))
+	applyAndRecord(t, memfs, "auth")
+	data, err := memfs.ReadFile("project/router.go")
+	require.NoError(t, err)
+	memfs.AddFile("project/router.go", []byte(strings.Replace(string(data), "/login", "/signin", 1)))
+
+	result, err := RemoveFeatures(context.Background(), memfs, &executor.FakeExecutor{}, "templates", "project", []string{"auth"}, ApplyOptions{})
+	require.NoError(t, err)
+
+	assert.Equal(t, []string{`router.go: auth block at "// templater:routes"`}, result.Kept)
+}
+
+func TestPreviewFeatures_IncludesInjections(t *testing.T) {
+	memfs := fs.NewMemoryFS()
+	memfs.AddDir("templates")
+	memfs.AddDir("templates/auth")
+	memfs.AddFile("templates/auth/base.patch", []byte(authPatch))
+	memfs.AddFile("templates/auth/feature.yml", []byte("inject:\n  - file: router.go\n    anchor: // templater:routes\n    snippet: |\n      r.Handle(\"/login\", login)\n"))
+	memfs.AddDir("templates/billing")
+	memfs.AddFile("templates/billing/feature.yml", []byte("inject:\n  - file: router.go\n    anchor: // templater:routes\n    snippet: |\n      r.Handle(\"/invoices\", invoices)\n"))
+	memfs.AddDir("project")
+	memfs.AddFile("project/router.go", []byte(routerGo))
+
+	preview, err := PreviewFeatures(memfs, "templates", "project", []string{"auth"}, nil)
+	require.NoError(t, err)
+
+	require.Len(t, preview.Changes, 2)
+	assert.Equal(t, "router.go", preview.Changes[1].Path)
+	assert.Contains(t, preview.Changes[1].New, "\tr.Handle(\"/login\", login)\n")
+}
@@ -1,6 +1,7 @@
 package template
 
 import (
+	"fmt"
 	"path"
 	"sort"
 
@@ -24,21 +25,64 @@ func ListFeatures(fileSystem fs.FileSystem, repoPath string) ([]string, error) {
 		if err != nil {
 			return nil, err
 		}
+		feature, err := isFeature(fileSystem, repoPath, relPath)
+		if err != nil {
+			return nil, err
+		}
+		if feature && relPath != "" {
+			features = append(features, relPath)
+		}
 
 		for _, entry := range entries {
-			entryRel := entry.Name()
-			if relPath != "" {
-				entryRel = path.Join(relPath, entry.Name())
+			if !entry.IsDir() {
+				continue
 			}
-
-			if entry.IsDir() {
-				queue = append(queue, entryRel)
-			} else if entry.Name() == "base.patch" && relPath != "" {
-				features = append(features, relPath)
+			entryRel := path.Join(relPath, entry.Name())
+			// A feature keeps its patches, overlay files and the like in
+			// directories with reserved names; anywhere else those names
+			// are ordinary sub-features.
+			if feature && reservedDirs[entry.Name()] {
+				if err := checkReservedDir(fileSystem, repoPath, relPath, entryRel); err != nil {
+					return nil, err
+				}
+				continue
 			}
+			queue = append(queue, entryRel)
 		}
 	}
 
 	sort.Strings(features)
 	return features, nil
 }
+
+func isFeature(fileSystem fs.FileSystem, repoPath, feature string) (bool, error) {
+	patches, err := featurePatches(fileSystem, repoPath, feature)
+	if err != nil || len(patches) > 0 {
+		return len(patches) > 0, err
+	}
+	for _, dir := range []string{filesDir, mergeDir, templatesDir} {
+		if _, err := fileSystem.Stat(path.Join(repoPath, feature, dir)); err == nil {
+			return true, nil
+		}
+	}
+	manifest, err := ReadManifest(fileSystem, repoPath, feature)
+	if err != nil {
+		return false, err
+	}
+	return len(manifest.Inject) > 0, nil
+}
+
+// checkReservedDir rejects a reserved directory that holds patches of its own
+// when its parent has none: the parent may only count as a feature because of
+// that directory, so it is unclear which of the two was meant.
+func checkReservedDir(fileSystem fs.FileSystem, repoPath, feature, dir string) error {
+	own, err := featurePatches(fileSystem, repoPath, feature)
+	if err != nil || len(own) > 0 {
+		return err
+	}
+	patches, err := featurePatches(fileSystem, repoPath, dir)
+	if err != nil || len(patches) == 0 {
+		return err
+	}
+	return fmt.Errorf("%s: %q is reserved inside a feature, so it cannot be a sub-feature of %s; rename it", path.Join(repoPath, dir), path.Base(dir), path.Join(repoPath, feature))
+}
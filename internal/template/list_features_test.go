@@ -56,3 +56,48 @@ func TestListFeatures_MissingBasePatch(t *testing.T) {
 	expected := []string{"auth", "auth/oauth/github", "auth/oauth/google"}
 	assert.Equal(t, expected, features)
 }
+
+func TestListFeatures_IncludesFeaturesWithOnlyTemplates(t *testing.T) {
+	memfs := fs.NewMemoryFS()
+	memfs.AddDir("templates")
+	memfs.AddDir("templates/service")
+	memfs.AddDir("templates/service/templates")
+	memfs.AddFile("templates/service/templates/main.go.tmpl", []byte("package main\n"))
+	memfs.AddDir("templates/docs")
+
+	features, err := ListFeatures(memfs, "templates")
+	require.NoError(t, err)
+
+	assert.Equal(t, []string{"service"}, features)
+}
+
+func TestListFeatures_ReservedNamesOnlyUnderFeatures(t *testing.T) {
+	memfs := fs.NewMemoryFS()
+	memfs.AddDir("repo")
+	memfs.AddDir("repo/ci")
+	memfs.AddDir("repo/ci/hooks")
+	memfs.AddDir("repo/ci/variants")
+	memfs.AddFile("repo/ci/hooks/base.patch", []byte{})
+	memfs.AddFile("repo/ci/variants/base.patch", []byte{})
+	memfs.AddDir("repo/auth")
+	memfs.AddDir("repo/auth/files")
+	memfs.AddFile("repo/auth/base.patch", []byte{})
+	memfs.AddFile("repo/auth/files/base.patch", []byte{})
+
+	features, err := ListFeatures(memfs, "repo")
+	require.NoError(t, err)
+
+	assert.Equal(t, []string{"auth", "ci/hooks", "ci/variants"}, features)
+}
+
+func TestListFeatures_RejectsSubFeatureInReservedDir(t *testing.T) {
+	memfs := fs.NewMemoryFS()
+	memfs.AddDir("repo")
+	memfs.AddDir("repo/ci")
+	memfs.AddDir("repo/ci/templates")
+	memfs.AddFile("repo/ci/templates/base.patch", []byte{})
+
+	_, err := ListFeatures(memfs, "repo")
+
+	assert.EqualError(t, err, `repo/ci/templates: "templates" is reserved inside a feature, so it cannot be a sub-feature of repo/ci; rename it`)
+}
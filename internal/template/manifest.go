@@ -0,0 +1,47 @@
+package template
+
+import (
+	"fmt"
+	"os"
+	"path"
+
+	"templater/internal/fs"
+
+	"gopkg.in/yaml.v3"
+)
+
+type Manifest struct {
+	Description string      `yaml:"description"`
+	Tags        []string    `yaml:"tags"`
+	Timeout     string      `yaml:"timeout"`
+	Requires    []string    `yaml:"requires"`
+	Inject      []Injection `yaml:"inject"`
+}
+
+func manifestPath(templatePath, feature string) string {
+	return path.Join(templatePath, feature, "feature.yml")
+}
+
+func ReadManifest(fileSystem fs.FileSystem, templatePath, feature string) (*Manifest, error) {
+	data, err := fileSystem.ReadFile(manifestPath(templatePath, feature))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return &Manifest{}, nil
+		}
+		return nil, err
+	}
+
+	var manifest Manifest
+	if err := yaml.Unmarshal(data, &manifest); err != nil {
+		return nil, fmt.Errorf("%s: %w", manifestPath(templatePath, feature), err)
+	}
+	for i, required := range manifest.Requires {
+		if ref, err := ParseFeatureRef(required); err != nil || ref.Name == "" || len(ref.Variants) > 0 {
+			return nil, fmt.Errorf("%s: requires[%d]: %q is not a feature name", manifestPath(templatePath, feature), i, required)
+		}
+	}
+	if err := validateInjections(manifest.Inject); err != nil {
+		return nil, fmt.Errorf("%s: %w", manifestPath(templatePath, feature), err)
+	}
+	return &manifest, nil
+}
@@ -0,0 +1,37 @@
+package template
+
+import (
+	"testing"
+
+	"templater/internal/testutil/fs"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestReadManifest_MissingIsEmpty(t *testing.T) {
+	memfs := fs.NewMemoryFS()
+	memfs.AddFile("templates/auth/base.patch", []byte("patch"))
+
+	manifest, err := ReadManifest(memfs, "templates", "auth")
+	require.NoError(t, err)
+	assert.Equal(t, &Manifest{}, manifest)
+}
+
+func TestReadManifest_ReadsTimeout(t *testing.T) {
+	memfs := fs.NewMemoryFS()
+	memfs.AddFile("templates/auth/feature.yml", []byte("timeout: 5m\n"))
+
+	manifest, err := ReadManifest(memfs, "templates", "auth")
+	require.NoError(t, err)
+	assert.Equal(t, "5m", manifest.Timeout)
+}
+
+func TestReadManifest_ValidatesRequires(t *testing.T) {
+	memfs := fs.NewMemoryFS()
+	memfs.AddFile("templates/web/feature.yml", []byte("requires:\n  - infra:ci\n  - ci[cache]\n"))
+
+	_, err := ReadManifest(memfs, "templates", "web")
+
+	assert.EqualError(t, err, `templates/web/feature.yml: requires[1]: "ci[cache]" is not a feature name`)
+}
@@ -0,0 +1,330 @@
+package template
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path"
+	"strings"
+
+	"templater/internal/fs"
+
+	"gopkg.in/yaml.v3"
+)
+
+const mergeDir = "merge"
+
+type mergeFragment struct {
+	Source string
+	Path   string
+	codec  mergeCodec
+}
+
+type MergeConflict struct {
+	Key      string
+	Existing string
+	Wanted   string
+}
+
+func mergeFragments(fileSystem fs.FileSystem, templatePath, feature string) ([]mergeFragment, error) {
+	root := path.Join(templatePath, feature, mergeDir)
+	names, err := walkFiles(fileSystem, root)
+	if err != nil {
+		return nil, err
+	}
+
+	var fragments []mergeFragment
+	for _, name := range names {
+		source := path.Join(root, name)
+		codec, ok := codecFor(name)
+		if !ok {
+			return nil, fmt.Errorf("%s: unsupported merge format (expected .json, .yml, .yaml, .toml or go.mod)", source)
+		}
+		fragments = append(fragments, mergeFragment{Source: source, Path: name, codec: codec})
+	}
+	return fragments, nil
+}
+
+func mergeFile(fileSystem fs.FileSystem, fragment mergeFragment, existing []byte) ([]byte, []MergeChange, []MergeConflict, error) {
+	dst, err := fragment.codec.decode(existing, false)
+	if err != nil {
+		return nil, nil, nil, fmt.Errorf("%s: %w", fragment.Path, err)
+	}
+	data, err := fileSystem.ReadFile(fragment.Source)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	src, err := fragment.codec.decode(data, true)
+	if err != nil {
+		return nil, nil, nil, fmt.Errorf("%s: %w", fragment.Source, err)
+	}
+
+	var changes []MergeChange
+	var conflicts []MergeConflict
+	mergeNode(dst, src, nil, &changes, &conflicts)
+	if len(conflicts) > 0 || len(changes) == 0 {
+		return existing, nil, conflicts, nil
+	}
+
+	merged := existing
+	for _, change := range changes {
+		if merged, err = applyChange(fragment.codec, merged, change); err != nil {
+			return nil, nil, nil, fmt.Errorf("%s: %s: %w", fragment.Path, displayKey(change.Key), err)
+		}
+	}
+	if err := checkEdited(fragment.codec, merged, dst); err != nil {
+		return nil, nil, nil, fmt.Errorf("%s: %w", fragment.Path, err)
+	}
+	return merged, changes, nil, nil
+}
+
+// checkEdited guards the in-place edits: the edited file must decode to the
+// document the change was computed on.
+func checkEdited(codec mergeCodec, data []byte, want *yaml.Node) error {
+	got, err := codec.decode(data, false)
+	if err != nil {
+		return fmt.Errorf("edit produced an invalid file: %w", err)
+	}
+	if !nodesEqual(got, want) {
+		return errors.New("could not edit the file in place")
+	}
+	return nil
+}
+
+func mergeNode(dst, src *yaml.Node, key []string, changes *[]MergeChange, conflicts *[]MergeConflict) {
+	dst, src = resolveAlias(dst), resolveAlias(src)
+	switch {
+	case dst.Kind == yaml.MappingNode && src.Kind == yaml.MappingNode:
+		for i := 0; i+1 < len(src.Content); i += 2 {
+			name := src.Content[i].Value
+			childKey := append(append([]string(nil), key...), name)
+			existing := mappingValue(dst, name)
+			if existing == nil {
+				value := copyNode(src.Content[i+1])
+				dst.Content = append(dst.Content, copyNode(src.Content[i]), value)
+				*changes = append(*changes, MergeChange{Key: childKey, Value: *copyNode(value)})
+				continue
+			}
+			mergeNode(existing, src.Content[i+1], childKey, changes, conflicts)
+		}
+	case dst.Kind == yaml.SequenceNode && src.Kind == yaml.SequenceNode:
+		var items []yaml.Node
+		for _, item := range src.Content {
+			if indexOfNode(dst.Content, item) >= 0 {
+				continue
+			}
+			dst.Content = append(dst.Content, copyNode(item))
+			items = append(items, *copyNode(item))
+		}
+		if len(items) > 0 {
+			*changes = append(*changes, MergeChange{Key: append([]string(nil), key...), Items: items})
+		}
+	case !nodesEqual(dst, src):
+		*conflicts = append(*conflicts, MergeConflict{Key: displayKey(key), Existing: describeNode(dst), Wanted: describeNode(src)})
+	}
+}
+
+func unmergeFile(codec mergeCodec, existing []byte, changes []MergeChange) ([]byte, bool, []string, error) {
+	doc, err := codec.decode(existing, false)
+	if err != nil {
+		return nil, false, nil, err
+	}
+
+	data := existing
+	var kept []string
+	for i := len(changes) - 1; i >= 0; i-- {
+		change := changes[i]
+		if change.Value.Kind != 0 {
+			parent := lookupNode(doc, change.Key[:len(change.Key)-1])
+			if parent == nil || parent.Kind != yaml.MappingNode {
+				continue
+			}
+			name := change.Key[len(change.Key)-1]
+			current := mappingValue(parent, name)
+			switch {
+			case current == nil:
+			case nodesEqual(current, &change.Value):
+				deleteMappingValue(parent, name)
+				if data, err = codec.remove(data, change.Key); err != nil {
+					return nil, false, nil, fmt.Errorf("%s: %w", displayKey(change.Key), err)
+				}
+			default:
+				kept = append(kept, displayKey(change.Key))
+			}
+			continue
+		}
+
+		seq := lookupNode(doc, change.Key)
+		if seq == nil || seq.Kind != yaml.SequenceNode {
+			continue
+		}
+		for j := range change.Items {
+			index := indexOfNode(seq.Content, &change.Items[j])
+			if index < 0 {
+				continue
+			}
+			seq.Content = append(seq.Content[:index], seq.Content[index+1:]...)
+			if data, err = codec.removeItem(data, change.Key, index); err != nil {
+				return nil, false, nil, fmt.Errorf("%s: %w", displayKey(change.Key), err)
+			}
+		}
+	}
+
+	if err := checkEdited(codec, data, doc); err != nil {
+		return nil, false, nil, err
+	}
+	empty := doc.Kind == yaml.MappingNode && len(doc.Content) == 0
+	return data, empty, kept, nil
+}
+
+func lookupNode(doc *yaml.Node, key []string) *yaml.Node {
+	node := doc
+	for _, name := range key {
+		node = resolveAlias(node)
+		if node.Kind != yaml.MappingNode {
+			return nil
+		}
+		if node = mappingValue(node, name); node == nil {
+			return nil
+		}
+	}
+	return resolveAlias(node)
+}
+
+func nodesEqual(a, b *yaml.Node) bool {
+	a, b = resolveAlias(a), resolveAlias(b)
+	if a.Kind != b.Kind || len(a.Content) != len(b.Content) {
+		return false
+	}
+	switch a.Kind {
+	case yaml.ScalarNode:
+		return a.ShortTag() == b.ShortTag() && a.Value == b.Value
+	case yaml.MappingNode:
+		for i := 0; i+1 < len(a.Content); i += 2 {
+			other := mappingValue(b, a.Content[i].Value)
+			if other == nil || !nodesEqual(a.Content[i+1], other) {
+				return false
+			}
+		}
+		return true
+	}
+	for i := range a.Content {
+		if !nodesEqual(a.Content[i], b.Content[i]) {
+			return false
+		}
+	}
+	return true
+}
+
+func indexOfNode(nodes []*yaml.Node, node *yaml.Node) int {
+	for i, n := range nodes {
+		if nodesEqual(n, node) {
+			return i
+		}
+	}
+	return -1
+}
+
+func resolveAlias(node *yaml.Node) *yaml.Node {
+	for node.Kind == yaml.AliasNode && node.Alias != nil {
+		node = node.Alias
+	}
+	return node
+}
+
+func copyNode(node *yaml.Node) *yaml.Node {
+	node = resolveAlias(node)
+	c := *node
+	c.Anchor = ""
+	c.Content = make([]*yaml.Node, len(node.Content))
+	for i, child := range node.Content {
+		c.Content[i] = copyNode(child)
+	}
+	return &c
+}
+
+func displayKey(key []string) string {
+	if len(key) == 0 {
+		return "(top level)"
+	}
+	return strings.Join(key, ".")
+}
+
+func mergeOverlaps(feature string, fragment mergeFragment, conflicts []MergeConflict) []Overlap {
+	overlaps := make([]Overlap, len(conflicts))
+	for i, c := range conflicts {
+		overlaps[i] = Overlap{Path: fragment.Path, Kind: OverlapKey, Features: []string{feature}, PatchPath: fragment.Source, Key: c}
+	}
+	return overlaps
+}
+
+func (a *applier) merges(ref FeatureRef) ([]mergeFragment, error) {
+	if _, baseApplied := a.previous[ref.Name]; baseApplied {
+		return nil, nil
+	}
+	templatePath, local := a.sources.locate(ref)
+	return mergeFragments(a.fileSystem, templatePath, local.Name)
+}
+
+func (a *applier) applyMerges(ref FeatureRef) ([]MergeState, error) {
+	fragments, err := a.merges(ref)
+	if err != nil {
+		return nil, err
+	}
+
+	var records []MergeState
+	for _, fragment := range fragments {
+		targetFile := path.Join(a.targetPath, fragment.Path)
+		existing, err := a.fileSystem.ReadFile(targetFile)
+		existed := err == nil
+		if err != nil && !errors.Is(err, os.ErrNotExist) {
+			return nil, err
+		}
+
+		merged, changes, conflicts, err := mergeFile(a.fileSystem, fragment, existing)
+		if err != nil {
+			return nil, err
+		}
+		if len(conflicts) > 0 {
+			return nil, &OverlapError{Overlaps: mergeOverlaps(ref.String(), fragment, conflicts)}
+		}
+		if len(changes) == 0 {
+			continue
+		}
+
+		a.backup(ref, targetFile, existing, existed)
+		if err := a.fileSystem.WriteFile(targetFile, merged); err != nil {
+			return nil, err
+		}
+		records = append(records, MergeState{Path: fragment.Path, Feature: ref.Name, Created: !existed, Changes: changes})
+	}
+	return records, nil
+}
+
+func (a *applier) unmerge(ref FeatureRef, record MergeState) ([]string, error) {
+	targetFile := path.Join(a.targetPath, record.Path)
+	existing, err := a.fileSystem.ReadFile(targetFile)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	codec, ok := codecFor(record.Path)
+	if !ok {
+		return nil, fmt.Errorf("%s: unsupported merge format", record.Path)
+	}
+
+	data, empty, kept, err := unmergeFile(codec, existing, record.Changes)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", record.Path, err)
+	}
+	for i, key := range kept {
+		kept[i] = record.Path + ": " + key
+	}
+	a.backup(ref, targetFile, existing, true)
+	if record.Created && empty {
+		return kept, a.fileSystem.Remove(targetFile)
+	}
+	return kept, a.fileSystem.WriteFile(targetFile, data)
+}
@@ -0,0 +1,277 @@
+package template
+
+import (
+	"bytes"
+	"fmt"
+	"path"
+	"strconv"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// mergeCodec edits a structured file in place: every operation rewrites only
+// the bytes of the key or item it touches, so comments, blank lines and the
+// file's own indentation survive a merge and its removal.
+type mergeCodec interface {
+	decode(data []byte, fragment bool) (*yaml.Node, error)
+	set(data []byte, key []string, value *yaml.Node) ([]byte, error)
+	appendItems(data []byte, key []string, items []yaml.Node) ([]byte, error)
+	remove(data []byte, key []string) ([]byte, error)
+	removeItem(data []byte, key []string, index int) ([]byte, error)
+}
+
+func codecFor(name string) (mergeCodec, bool) {
+	if path.Base(name) == "go.mod" {
+		return goModCodec{}, true
+	}
+	switch path.Ext(name) {
+	case ".yml", ".yaml":
+		return yamlCodec{}, true
+	case ".json":
+		return jsonCodec{}, true
+	case ".toml":
+		return tomlCodec{}, true
+	}
+	return nil, false
+}
+
+func applyChange(codec mergeCodec, data []byte, change MergeChange) ([]byte, error) {
+	if change.Value.Kind != 0 {
+		return codec.set(data, change.Key, &change.Value)
+	}
+	return codec.appendItems(data, change.Key, change.Items)
+}
+
+type textSpan struct {
+	start, end int
+}
+
+// flowContainer is a bracketed collection — a JSON value, a YAML flow
+// collection or a TOML array or inline table — with the spans of its items,
+// trimmed of whitespace and comments.
+type flowContainer struct {
+	open, close int
+	items       []textSpan
+}
+
+func scanFlow(data []byte, open int) (flowContainer, error) {
+	c := flowContainer{open: open}
+	if open >= len(data) || (data[open] != '[' && data[open] != '{') {
+		return c, fmt.Errorf("expected [ or { at offset %d", open)
+	}
+	depth, itemStart, itemEnd := 0, -1, -1
+	mark := func(start, end int) {
+		if itemStart < 0 {
+			itemStart = start
+		}
+		itemEnd = end
+	}
+	for i := open + 1; i < len(data); {
+		switch ch := data[i]; {
+		case ch == '"' || ch == '\'':
+			end := skipQuoted(data, i)
+			mark(i, end)
+			i = end
+			continue
+		case ch == '#' && isSpace(data[i-1]):
+			for i < len(data) && data[i] != '\n' {
+				i++
+			}
+			continue
+		case ch == '[' || ch == '{':
+			depth++
+			mark(i, i+1)
+		case ch == ']' || ch == '}':
+			if depth == 0 {
+				if itemStart >= 0 {
+					c.items = append(c.items, textSpan{itemStart, itemEnd})
+				}
+				c.close = i
+				return c, nil
+			}
+			depth--
+			mark(i, i+1)
+		case ch == ',' && depth == 0:
+			if itemStart >= 0 {
+				c.items = append(c.items, textSpan{itemStart, itemEnd})
+			}
+			itemStart = -1
+		case isSpace(ch):
+		default:
+			mark(i, i+1)
+		}
+		i++
+	}
+	return c, fmt.Errorf("unterminated %c at offset %d", data[open], open)
+}
+
+func skipQuoted(data []byte, i int) int {
+	quote := data[i]
+	delim := []byte{quote}
+	if bytes.HasPrefix(data[i:], []byte{quote, quote, quote}) {
+		delim = []byte{quote, quote, quote}
+	}
+	for j := i + len(delim); j < len(data); j++ {
+		switch {
+		case quote == '"' && data[j] == '\\':
+			j++
+		case bytes.HasPrefix(data[j:], delim):
+			return j + len(delim)
+		}
+	}
+	return len(data)
+}
+
+func isSpace(ch byte) bool {
+	return ch == ' ' || ch == '\t' || ch == '\n' || ch == '\r'
+}
+
+// insertFlow appends entries after the last item of c, reusing the separator
+// between its existing items. render receives the indentation of the items
+// when the container spans several lines and "" when it is written inline.
+// An empty container is expanded onto several lines when expand is set.
+func insertFlow(data []byte, c flowContainer, expand bool, unit string, render func(indent string) []string) []byte {
+	if n := len(c.items); n > 0 {
+		last := c.items[n-1]
+		var sep string
+		if n >= 2 {
+			sep = string(data[c.items[n-2].end:last.start])
+		} else if lead := data[c.open+1 : c.items[0].start]; bytes.IndexByte(lead, '\n') >= 0 {
+			sep = "," + string(lead)
+		} else {
+			sep = ", "
+		}
+		indent := ""
+		if i := strings.LastIndexByte(sep, '\n'); i >= 0 {
+			indent = sep[i+1:]
+			sep = ",\n" + indent
+		}
+		entries := render(indent)
+		return splice(data, last.end, last.end, sep+strings.Join(entries, sep))
+	}
+
+	if !expand {
+		return splice(data, c.open+1, c.close, strings.Join(render(""), ", "))
+	}
+	base := lineIndent(data, c.open)
+	indent := base + unit
+	inner := "\n" + indent + strings.Join(render(indent), ",\n"+indent) + "\n" + base
+	return splice(data, c.open+1, c.close, inner)
+}
+
+func removeFlow(data []byte, c flowContainer, i int) []byte {
+	switch {
+	case len(c.items) == 1:
+		return splice(data, c.open+1, c.close, "")
+	case i > 0:
+		return splice(data, c.items[i-1].end, c.items[i].end, "")
+	}
+	return splice(data, c.items[0].start, c.items[1].start, "")
+}
+
+func splice(data []byte, start, end int, text string) []byte {
+	out := make([]byte, 0, len(data)-(end-start)+len(text))
+	out = append(out, data[:start]...)
+	out = append(out, text...)
+	return append(out, data[end:]...)
+}
+
+func lineStart(data []byte, offset int) int {
+	return bytes.LastIndexByte(data[:offset], '\n') + 1
+}
+
+func nextLine(data []byte, offset int) int {
+	if i := bytes.IndexByte(data[offset:], '\n'); i >= 0 {
+		return offset + i + 1
+	}
+	return len(data)
+}
+
+func lineIndent(data []byte, offset int) string {
+	start := lineStart(data, offset)
+	end := start
+	for end < len(data) && (data[end] == ' ' || data[end] == '\t') {
+		end++
+	}
+	return string(data[start:end])
+}
+
+// indentUnit guesses the indentation a file uses from its first indented
+// line.
+func indentUnit(data []byte, fallback string) string {
+	for _, line := range strings.Split(string(data), "\n") {
+		trimmed := strings.TrimLeft(line, " \t")
+		if trimmed != "" && len(trimmed) < len(line) {
+			return line[:len(line)-len(trimmed)]
+		}
+	}
+	return fallback
+}
+
+func withNewline(data []byte) []byte {
+	if len(data) > 0 && data[len(data)-1] != '\n' {
+		return append(append([]byte(nil), data...), '\n')
+	}
+	return data
+}
+
+func pairIndex(mapping *yaml.Node, key string) int {
+	for i := 0; i+1 < len(mapping.Content); i += 2 {
+		if mapping.Content[i].Value == key {
+			return i / 2
+		}
+	}
+	return -1
+}
+
+func newMapping() *yaml.Node {
+	return &yaml.Node{Kind: yaml.MappingNode, Tag: "!!map"}
+}
+
+func newScalar(tag, value string) *yaml.Node {
+	return &yaml.Node{Kind: yaml.ScalarNode, Tag: tag, Value: value}
+}
+
+func mappingValue(mapping *yaml.Node, key string) *yaml.Node {
+	for i := 0; i+1 < len(mapping.Content); i += 2 {
+		if mapping.Content[i].Value == key {
+			return mapping.Content[i+1]
+		}
+	}
+	return nil
+}
+
+func setMappingValue(mapping *yaml.Node, key string, value *yaml.Node) {
+	for i := 0; i+1 < len(mapping.Content); i += 2 {
+		if mapping.Content[i].Value == key {
+			mapping.Content[i+1] = value
+			return
+		}
+	}
+	mapping.Content = append(mapping.Content, newScalar("!!str", key), value)
+}
+
+func deleteMappingValue(mapping *yaml.Node, key string) {
+	for i := 0; i+1 < len(mapping.Content); i += 2 {
+		if mapping.Content[i].Value == key {
+			mapping.Content = append(mapping.Content[:i], mapping.Content[i+2:]...)
+			return
+		}
+	}
+}
+
+func describeNode(node *yaml.Node) string {
+	switch node.Kind {
+	case yaml.MappingNode:
+		return "a map"
+	case yaml.SequenceNode:
+		return "a list"
+	case yaml.ScalarNode:
+		if node.ShortTag() == "!!str" {
+			return strconv.Quote(node.Value)
+		}
+		return node.Value
+	}
+	return "an unsupported value"
+}
@@ -0,0 +1,175 @@
+package template
+
+import (
+	"testing"
+
+	"templater/internal/testutil/fs"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gopkg.in/yaml.v3"
+)
+
+func mergeRoundTrip(t *testing.T, name, existing, fragment string) (string, string) {
+	codec, ok := codecFor(name)
+	require.True(t, ok)
+	memfs := fs.NewMemoryFS()
+	memfs.AddFile("fragment", []byte(fragment))
+
+	merged, changes, conflicts, err := mergeFile(memfs, mergeFragment{Source: "fragment", Path: name, codec: codec}, []byte(existing))
+	require.NoError(t, err)
+	require.Empty(t, conflicts)
+	removed, _, kept, err := unmergeFile(codec, merged, changes)
+	require.NoError(t, err)
+	require.Empty(t, kept)
+	return string(merged), string(removed)
+}
+
+func TestCodecFor(t *testing.T) {
+	for name, want := range map[string]mergeCodec{
+		"config.yml":         yamlCodec{},
+		"deploy/values.yaml": yamlCodec{},
+		"package.json":       jsonCodec{},
+		"pyproject.toml":     tomlCodec{},
+		"go.mod":             goModCodec{},
+		"tools/go.mod":       goModCodec{},
+		"requirements.txt":   nil,
+		"Dockerfile":         nil,
+	} {
+		codec, ok := codecFor(name)
+		assert.Equal(t, want != nil, ok, name)
+		assert.Equal(t, want, codec, name)
+	}
+}
+
+func TestYAMLCodec_KeepsLayoutAndComments(t *testing.T) {
+	existing := "# app\nserver:\n    port: 8080 # http\n\nplugins:\n- cache\n\nname: app\n"
+
+	merged, removed := mergeRoundTrip(t, "config.yml", existing, "server:\n  tls: true\nplugins: [auth]\nextra:\n  a: 1\n")
+
+	assert.Equal(t, "# app\nserver:\n    port: 8080 # http\n    tls: true\n\nplugins:\n- cache\n- auth\n\nname: app\nextra:\n    a: 1\n", merged)
+	assert.Equal(t, existing, removed)
+}
+
+func TestYAMLCodec_EditsFlowCollections(t *testing.T) {
+	existing := "tags: [web]\nmeta: {a: 1}\n"
+
+	merged, removed := mergeRoundTrip(t, "config.yml", existing, "tags: [auth]\nmeta: {b: 2}\n")
+
+	assert.Equal(t, "tags: [web, auth]\nmeta: {a: 1, b: 2}\n", merged)
+	assert.Equal(t, existing, removed)
+}
+
+func TestJSONCodec_KeepsIndentation(t *testing.T) {
+	existing := "{\n    \"name\": \"app\",\n    \"deps\": {\"a\": \"1\"},\n    \"files\": []\n}\n"
+
+	merged, removed := mergeRoundTrip(t, "package.json", existing, `{"deps": {"b": "2"}, "files": ["x"], "scripts": {"t": "go"}}`)
+
+	assert.Equal(t, "{\n    \"name\": \"app\",\n    \"deps\": {\"a\": \"1\", \"b\": \"2\"},\n    \"files\": [\n        \"x\"\n    ],\n"+
+		"    \"scripts\": {\n        \"t\": \"go\"\n    }\n}\n", merged)
+	assert.Equal(t, existing, removed)
+}
+
+func TestJSONCodec_RejectsInvalidJSON(t *testing.T) {
+	_, err := jsonCodec{}.decode([]byte("name: app"), false)
+
+	assert.EqualError(t, err, "invalid JSON")
+}
+
+func TestTOMLCodec_KeepsCommentsAndLayout(t *testing.T) {
+	existing := `# project
+title = "app" # name
+
+[tool.lint]
+rules = [
+  "E",
+]
+opts = {strict = true}
+
+[[bin]]
+name = "app"
+`
+
+	merged, removed := mergeRoundTrip(t, "pyproject.toml", existing, `version = 2
+
+[tool.lint]
+rules = ["W"]
+opts = {fix = true}
+ratio = 0.5
+
+[tool.auth]
+enabled = true
+
+[[bin]]
+name = "auth"
+`)
+
+	assert.Equal(t, `# project
+title = "app" # name
+version = 2
+
+[tool.lint]
+rules = [
+  "E",
+  "W",
+]
+opts = {strict = true, fix = true}
+ratio = 0.5
+
+[[bin]]
+name = "app"
+
+[tool.auth]
+enabled = true
+
+[[bin]]
+name = "auth"
+`, merged)
+	assert.Equal(t, existing, removed)
+}
+
+func TestTOMLCodec_ExtendsDottedKeys(t *testing.T) {
+	existing := "tool.auth.enabled = true\n\n[owner]\nname = \"Tom\"\n"
+
+	merged, removed := mergeRoundTrip(t, "pyproject.toml", existing, "[tool.auth]\nlevel = 2\n")
+
+	assert.Equal(t, "tool.auth.enabled = true\ntool.auth.level = 2\n\n[owner]\nname = \"Tom\"\n", merged)
+	assert.Equal(t, existing, removed)
+}
+
+func TestTOMLCodec_RejectsInvalidTOML(t *testing.T) {
+	_, err := tomlCodec{}.decode([]byte("a = 1\na = 2\n"), false)
+
+	assert.Error(t, err)
+}
+
+func TestGoModCodec_AddsToRequireBlock(t *testing.T) {
+	existing := "module example.com/app\n\ngo 1.22\n\nrequire (\n\tgithub.com/a/a v1.0.0 // indirect\n)\n"
+
+	merged, removed := mergeRoundTrip(t, "go.mod", existing, "require github.com/b/b v1.2.0\n")
+
+	assert.Equal(t, "module example.com/app\n\ngo 1.22\n\nrequire (\n\tgithub.com/a/a v1.0.0 // indirect\n\tgithub.com/b/b v1.2.0\n)\n", merged)
+	assert.Equal(t, existing, removed)
+}
+
+func TestGoModCodec_AddsRequireDirective(t *testing.T) {
+	existing := "module example.com/app\n\ngo 1.22\n"
+
+	merged, removed := mergeRoundTrip(t, "go.mod", existing, "require github.com/b/b v1.2.0\n")
+
+	assert.Equal(t, "module example.com/app\n\ngo 1.22\n\nrequire github.com/b/b v1.2.0\n", merged)
+	assert.Equal(t, existing, removed)
+}
+
+func TestGoModCodec_FragmentsOnlyRequire(t *testing.T) {
+	_, err := goModCodec{}.decode([]byte("require github.com/a/a v1.0.0\nreplace github.com/a/a => ../a\n"), true)
+
+	assert.EqualError(t, err, "line 2: go.mod fragments may only contain require directives")
+}
+
+func TestDescribeNode(t *testing.T) {
+	assert.Equal(t, `"x"`, describeNode(newScalar("!!str", "x")))
+	assert.Equal(t, "8080", describeNode(newScalar("!!int", "8080")))
+	assert.Equal(t, "a map", describeNode(newMapping()))
+	assert.Equal(t, "a list", describeNode(&yaml.Node{Kind: yaml.SequenceNode}))
+}
@@ -0,0 +1,112 @@
+package template
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+
+	"golang.org/x/mod/modfile"
+	"gopkg.in/yaml.v3"
+)
+
+type goModCodec struct{}
+
+func (goModCodec) decode(data []byte, fragment bool) (*yaml.Node, error) {
+	f, err := modfile.Parse("go.mod", data, nil)
+	if err != nil {
+		return nil, err
+	}
+	if fragment {
+		for _, stmt := range f.Syntax.Stmt {
+			start, _ := stmt.Span()
+			switch stmt := stmt.(type) {
+			case *modfile.Line:
+				if stmt.Token[0] != "require" {
+					return nil, fmt.Errorf("line %d: go.mod fragments may only contain require directives", start.Line)
+				}
+			case *modfile.LineBlock:
+				if stmt.Token[0] != "require" {
+					return nil, fmt.Errorf("line %d: go.mod fragments may only contain require directives", start.Line)
+				}
+			}
+		}
+	}
+
+	root := newMapping()
+	if len(f.Require) > 0 {
+		modules := newMapping()
+		for _, r := range f.Require {
+			setMappingValue(modules, r.Mod.Path, newScalar("!!str", r.Mod.Version))
+		}
+		setMappingValue(root, "require", modules)
+	}
+	return root, nil
+}
+
+func (goModCodec) set(data []byte, key []string, value *yaml.Node) ([]byte, error) {
+	if key[0] != "require" {
+		return nil, fmt.Errorf("cannot write %q to go.mod", key[0])
+	}
+	return editGoMod(data, func(f *modfile.File) {
+		if len(key) == 2 {
+			f.AddNewRequire(key[1], value.Value, false)
+			return
+		}
+		for i := 0; i+1 < len(value.Content); i += 2 {
+			f.AddNewRequire(value.Content[i].Value, value.Content[i+1].Value, false)
+		}
+	})
+}
+
+func (goModCodec) remove(data []byte, key []string) ([]byte, error) {
+	if key[0] != "require" {
+		return nil, fmt.Errorf("cannot write %q to go.mod", key[0])
+	}
+	return editGoMod(data, func(f *modfile.File) {
+		for _, r := range f.Require {
+			if len(key) == 1 || r.Mod.Path == key[1] {
+				f.DropRequire(r.Mod.Path)
+			}
+		}
+	})
+}
+
+func (goModCodec) appendItems(data []byte, key []string, items []yaml.Node) ([]byte, error) {
+	return nil, errors.New("go.mod has no lists")
+}
+
+func (goModCodec) removeItem(data []byte, key []string, index int) ([]byte, error) {
+	return nil, errors.New("go.mod has no lists")
+}
+
+func editGoMod(data []byte, edit func(*modfile.File)) ([]byte, error) {
+	f, err := modfile.Parse("go.mod", data, nil)
+	if err != nil {
+		return nil, err
+	}
+	edit(f)
+	dropDeletedLines(f.Syntax)
+	return modfile.Format(f.Syntax), nil
+}
+
+// dropDeletedLines removes the lines an edit deleted. Unlike Cleanup it keeps
+// a block that is left with a single line, so removing a merge restores the
+// file's original layout.
+func dropDeletedLines(syntax *modfile.FileSyntax) {
+	var stmts []modfile.Expr
+	for _, stmt := range syntax.Stmt {
+		switch stmt := stmt.(type) {
+		case *modfile.Line:
+			if stmt.Token == nil {
+				continue
+			}
+		case *modfile.LineBlock:
+			stmt.Line = slices.DeleteFunc(stmt.Line, func(line *modfile.Line) bool { return line.Token == nil })
+			if len(stmt.Line) == 0 {
+				continue
+			}
+		}
+		stmts = append(stmts, stmt)
+	}
+	syntax.Stmt = stmts
+}
@@ -0,0 +1,176 @@
+package template
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+
+	"gopkg.in/yaml.v3"
+)
+
+type jsonCodec struct{}
+
+func (jsonCodec) decode(data []byte, fragment bool) (*yaml.Node, error) {
+	if len(bytes.TrimSpace(data)) == 0 {
+		return newMapping(), nil
+	}
+	if !json.Valid(data) {
+		return nil, errors.New("invalid JSON")
+	}
+	return yamlCodec{}.decode(data, fragment)
+}
+
+func (c jsonCodec) set(data []byte, key []string, value *yaml.Node) ([]byte, error) {
+	data = jsonDocument(data)
+	container, _, err := c.locate(data, key[:len(key)-1])
+	if err != nil {
+		return nil, err
+	}
+	unit := indentUnit(data, "  ")
+	return insertFlow(data, container, true, unit, func(indent string) []string {
+		var out bytes.Buffer
+		writeJSONString(&out, key[len(key)-1])
+		out.WriteString(": ")
+		writeJSON(&out, value, indent, jsonUnit(indent, unit))
+		return []string{out.String()}
+	}), nil
+}
+
+func (c jsonCodec) appendItems(data []byte, key []string, items []yaml.Node) ([]byte, error) {
+	container, _, err := c.locate(data, key)
+	if err != nil {
+		return nil, err
+	}
+	unit := indentUnit(data, "  ")
+	return insertFlow(data, container, true, unit, func(indent string) []string {
+		entries := make([]string, len(items))
+		for i := range items {
+			var out bytes.Buffer
+			writeJSON(&out, &items[i], indent, jsonUnit(indent, unit))
+			entries[i] = out.String()
+		}
+		return entries
+	}), nil
+}
+
+func (c jsonCodec) remove(data []byte, key []string) ([]byte, error) {
+	container, parent, err := c.locate(data, key[:len(key)-1])
+	if err != nil {
+		return nil, err
+	}
+	i := pairIndex(parent, key[len(key)-1])
+	if i < 0 {
+		return nil, fmt.Errorf("%s not found", displayKey(key))
+	}
+	return removeFlow(data, container, i), nil
+}
+
+func (c jsonCodec) removeItem(data []byte, key []string, index int) ([]byte, error) {
+	container, _, err := c.locate(data, key)
+	if err != nil {
+		return nil, err
+	}
+	return removeFlow(data, container, index), nil
+}
+
+// locate finds the object or array at key, walking the members of each
+// object in the order the decoder reported them.
+func (c jsonCodec) locate(data []byte, key []string) (flowContainer, *yaml.Node, error) {
+	node, err := c.decode(data, false)
+	if err != nil {
+		return flowContainer{}, nil, err
+	}
+	container, err := scanFlow(data, len(data)-len(bytes.TrimLeft(data, " \t\r\n")))
+	if err != nil {
+		return container, nil, err
+	}
+	for n, name := range key {
+		i := pairIndex(node, name)
+		if i < 0 || i >= len(container.items) {
+			return container, nil, fmt.Errorf("%s not found", displayKey(key[:n+1]))
+		}
+		item := container.items[i]
+		value := skipQuoted(data, item.start)
+		value += bytes.IndexByte(data[value:item.end], ':') + 1
+		for value < item.end && isSpace(data[value]) {
+			value++
+		}
+		if container, err = scanFlow(data, value); err != nil {
+			return container, nil, fmt.Errorf("%s: %w", displayKey(key[:n+1]), err)
+		}
+		node = mappingValue(node, name)
+	}
+	return container, node, nil
+}
+
+func jsonDocument(data []byte) []byte {
+	if len(bytes.TrimSpace(data)) == 0 {
+		return []byte("{}\n")
+	}
+	return data
+}
+
+// jsonUnit writes values inline inside a container that is itself written on
+// one line.
+func jsonUnit(indent, unit string) string {
+	if indent == "" {
+		return ""
+	}
+	return unit
+}
+
+func writeJSON(out *bytes.Buffer, node *yaml.Node, indent, unit string) {
+	open, sep, closing := "\n", ",\n", "\n"+indent
+	if unit == "" {
+		open, sep, closing = "", ", ", ""
+	}
+	switch node.Kind {
+	case yaml.MappingNode:
+		if len(node.Content) == 0 {
+			out.WriteString("{}")
+			return
+		}
+		out.WriteString("{" + open)
+		for i := 0; i < len(node.Content); i += 2 {
+			if i > 0 {
+				out.WriteString(sep)
+			}
+			out.WriteString(indent + unit)
+			writeJSONString(out, node.Content[i].Value)
+			out.WriteString(": ")
+			writeJSON(out, node.Content[i+1], indent+unit, unit)
+		}
+		out.WriteString(closing + "}")
+	case yaml.SequenceNode:
+		if len(node.Content) == 0 {
+			out.WriteString("[]")
+			return
+		}
+		out.WriteString("[" + open)
+		for i, item := range node.Content {
+			if i > 0 {
+				out.WriteString(sep)
+			}
+			out.WriteString(indent + unit)
+			writeJSON(out, item, indent+unit, unit)
+		}
+		out.WriteString(closing + "]")
+	default:
+		switch node.ShortTag() {
+		case "!!int", "!!float", "!!bool":
+			out.WriteString(node.Value)
+		case "!!null":
+			out.WriteString("null")
+		default:
+			writeJSONString(out, node.Value)
+		}
+	}
+}
+
+func writeJSONString(out *bytes.Buffer, s string) {
+	encoder := json.NewEncoder(out)
+	encoder.SetEscapeHTML(false)
+	encoder.Encode(s)
+	out.Truncate(out.Len() - 1)
+}
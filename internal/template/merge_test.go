@@ -0,0 +1,261 @@
+package template
+
+import (
+	"context"
+	"testing"
+
+	"templater/internal/testutil/executor"
+	"templater/internal/testutil/fs"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const packageJSON = `{
+  "name": "app",
+  "dependencies": {
+    "react": "^18.0.0"
+  }
+}
+`
+
+func TestMergeFragments_RejectsUnknownFormat(t *testing.T) {
+	memfs := fs.NewMemoryFS()
+	memfs.AddDir("templates/auth/merge")
+	memfs.AddFile("templates/auth/merge/requirements.txt", []byte("flask\n"))
+
+	_, err := mergeFragments(memfs, "templates", "auth")
+
+	assert.EqualError(t, err, "templates/auth/merge/requirements.txt: unsupported merge format (expected .json, .yml, .yaml, .toml or go.mod)")
+}
+
+func TestMergeFile_ReportsEveryConflictingKey(t *testing.T) {
+	memfs := fs.NewMemoryFS()
+	memfs.AddFile("templates/legacy.yml", []byte("server:\n  port: 9090\n  tls: [a]\nname: app\n"))
+	fragment := mergeFragment{Source: "templates/legacy.yml", Path: "config.yml", codec: yamlCodec{}}
+
+	_, changes, conflicts, err := mergeFile(memfs, fragment, []byte("server:\n  port: 8080\n  tls: true\nname: app\n"))
+	require.NoError(t, err)
+
+	assert.Empty(t, changes)
+	assert.Equal(t, []MergeConflict{
+		{Key: "server.port", Existing: "8080", Wanted: "9090"},
+		{Key: "server.tls", Existing: "true", Wanted: "a list"},
+	}, conflicts)
+}
+
+func TestMergeFile_UnchangedFileIsNotRewritten(t *testing.T) {
+	memfs := fs.NewMemoryFS()
+	memfs.AddFile("templates/auth/merge/package.json", []byte(`{"dependencies": {"passport": "^0.7.0"}, "keywords": ["auth"]}`))
+	existing := []byte("{\"dependencies\": {\"passport\": \"^0.7.0\"}, \"keywords\": [\"auth\", \"web\"]}")
+
+	merged, changes, conflicts, err := mergeFile(memfs, mergeFragment{Source: "templates/auth/merge/package.json", Path: "package.json", codec: jsonCodec{}}, existing)
+	require.NoError(t, err)
+
+	assert.Empty(t, changes)
+	assert.Empty(t, conflicts)
+	assert.Equal(t, existing, merged)
+}
+
+func TestApplyFeatures_MergesFragments(t *testing.T) {
+	memfs := fs.NewMemoryFS()
+	memfs.AddDir("templates")
+	memfs.AddDir("templates/auth")
+	memfs.AddFile("templates/auth/base.patch", []byte(authPatch))
+	memfs.AddDir("templates/auth/merge")
+	memfs.AddFile("templates/auth/merge/package.json", []byte(`{"dependencies": {"passport": "^0.7.0"}, "keywords": ["auth"]}`))
+	memfs.AddFile("templates/auth/merge/config.yml", []byte("auth:\n  provider: oauth\n"))
+	memfs.AddDir("templates/database")
+	memfs.AddDir("templates/database/merge")
+	memfs.AddFile("templates/database/merge/package.json", []byte(`{"dependencies": {"pg": "^8.11.0"}}`))
+	memfs.AddDir("project")
+	memfs.AddFile("project/package.json", []byte(packageJSON))
+
+	_, err := ApplyFeatures(context.Background(), memfs, &executor.FakeExecutor{}, "templates", "project", []string{"auth", "database"}, ApplyOptions{})
+	require.NoError(t, err)
+
+	data, err := memfs.ReadFile("project/package.json")
+	require.NoError(t, err)
+	assert.Equal(t, `{
+  "name": "app",
+  "dependencies": {
+    "react": "^18.0.0",
+    "passport": "^0.7.0",
+    "pg": "^8.11.0"
+  },
+  "keywords": [
+    "auth"
+  ]
+}
+`, string(data))
+
+	data, err = memfs.ReadFile("project/config.yml")
+	require.NoError(t, err)
+	assert.Equal(t, "auth:\n  provider: oauth\n", string(data))
+
+	state, err := ReadState(memfs, "project")
+	require.NoError(t, err)
+	require.Len(t, state.Merges, 3)
+	assert.Equal(t, "config.yml", state.Merges[0].Path)
+	assert.True(t, state.Merges[0].Created)
+	assert.Equal(t, "package.json", state.Merges[1].Path)
+	assert.Equal(t, []string{"dependencies", "passport"}, state.Merges[1].Changes[0].Key)
+	assert.Equal(t, []string{"keywords"}, state.Merges[1].Changes[1].Key)
+	assert.Equal(t, "database", state.Merges[2].Feature)
+}
+
+func TestApplyFeatures_MergeConflictIsReportedBeforeApplying(t *testing.T) {
+	memfs := fs.NewMemoryFS()
+	memfs.AddDir("templates")
+	memfs.AddDir("templates/auth")
+	memfs.AddFile("templates/auth/base.patch", []byte(authPatch))
+	memfs.AddDir("templates/auth/merge")
+	memfs.AddFile("templates/auth/merge/package.json", []byte(`{"dependencies": {"passport": "^0.7.0"}, "keywords": ["auth"]}`))
+	memfs.AddFile("templates/auth/merge/config.yml", []byte("auth:\n  provider: oauth\n"))
+	memfs.AddDir("templates/database")
+	memfs.AddDir("templates/database/merge")
+	memfs.AddFile("templates/database/merge/package.json", []byte(`{"dependencies": {"pg": "^8.11.0"}}`))
+	memfs.AddDir("project")
+	memfs.AddFile("project/package.json", []byte(packageJSON))
+	memfs.AddFile("templates/auth/merge/package.json", []byte(`{"dependencies": {"react": "^17.0.0"}}`))
+	exec := &executor.FakeExecutor{}
+
+	_, err := ApplyFeatures(context.Background(), memfs, exec, "templates", "project", []string{"auth"}, ApplyOptions{})
+
+	var overlap *OverlapError
+	require.ErrorAs(t, err, &overlap)
+	assert.Equal(t, OverlapKey, overlap.Overlaps[0].Kind)
+	assert.EqualError(t, err, `failed to apply auth: package.json: dependencies.react is "^18.0.0" but auth would set "^17.0.0"`)
+	assert.Empty(t, exec.Commands)
+}
+
+func TestApplyFeatures_RollbackRestoresMergedFiles(t *testing.T) {
+	memfs := fs.NewMemoryFS()
+	memfs.AddDir("templates")
+	memfs.AddDir("templates/auth")
+	memfs.AddFile("templates/auth/base.patch", []byte(authPatch))
+	memfs.AddDir("templates/auth/merge")
+	memfs.AddFile("templates/auth/merge/package.json", []byte(`{"dependencies": {"passport": "^0.7.0"}, "keywords": ["auth"]}`))
+	memfs.AddFile("templates/auth/merge/config.yml", []byte("auth:\n  provider: oauth\n"))
+	memfs.AddDir("templates/database")
+	memfs.AddDir("templates/database/merge")
+	memfs.AddFile("templates/database/merge/package.json", []byte(`{"dependencies": {"pg": "^8.11.0"}}`))
+	memfs.AddDir("project")
+	memfs.AddFile("project/package.json", []byte(packageJSON))
+	memfs.AddFile("templates/database/base.patch", []byte("database"))
+	exec := &executor.FakeExecutor{ExitCodes: map[string]int{applyCommand("project", "templates/database/base.patch"): 1}}
+
+	_, err := ApplyFeatures(context.Background(), memfs, exec, "templates", "project", []string{"auth", "database"}, ApplyOptions{})
+	require.Error(t, err)
+
+	data, err := memfs.ReadFile("project/package.json")
+	require.NoError(t, err)
+	assert.Equal(t, packageJSON, string(data))
+	_, err = memfs.ReadFile("project/config.yml")
+	assert.Error(t, err)
+	_, err = memfs.ReadFile(statePath("project"))
+	assert.Error(t, err)
+}
+
+func TestRemoveFeatures_ReversesMerges(t *testing.T) {
+	memfs := fs.NewMemoryFS()
+	memfs.AddDir("templates")
+	memfs.AddDir("templates/auth")
+	memfs.AddFile("templates/auth/base.patch", []byte(authPatch))
+	memfs.AddDir("templates/auth/merge")
+	memfs.AddFile("templates/auth/merge/package.json", []byte(`{"dependencies": {"passport": "^0.7.0"}, "keywords": ["auth"]}`))
+	memfs.AddFile("templates/auth/merge/config.yml", []byte("auth:\n  provider: oauth\n"))
+	memfs.AddDir("templates/database")
+	memfs.AddDir("templates/database/merge")
+	memfs.AddFile("templates/database/merge/package.json", []byte(`{"dependencies": {"pg": "^8.11.0"}}`))
+	memfs.AddDir("project")
+	memfs.AddFile("project/package.json", []byte(packageJSON))
+	applyAndRecord(t, memfs, "auth", "database")
+
+	result, err := RemoveFeatures(context.Background(), memfs, &executor.FakeExecutor{}, "templates", "project", []string{"auth"}, ApplyOptions{})
+	require.NoError(t, err)
+
+	assert.Empty(t, result.Kept)
+	data, err := memfs.ReadFile("project/package.json")
+	require.NoError(t, err)
+	assert.Equal(t, `{
+  "name": "app",
+  "dependencies": {
+    "react": "^18.0.0",
+    "pg": "^8.11.0"
+  }
+}
+`, string(data))
+	_, err = memfs.ReadFile("project/config.yml")
+	assert.Error(t, err)
+
+	state, err := ReadState(memfs, "project")
+	require.NoError(t, err)
+	require.Len(t, state.Merges, 1)
+	assert.Equal(t, "database", state.Merges[0].Feature)
+}
+
+func TestRemoveFeatures_KeepsModifiedKeys(t *testing.T) {
+	memfs := fs.NewMemoryFS()
+	memfs.AddDir("templates")
+	memfs.AddDir("templates/auth")
+	memfs.AddFile("templates/auth/base.patch", []byte(authPatch))
+	memfs.AddDir("templates/auth/merge")
+	memfs.AddFile("templates/auth/merge/package.json", []byte(`{"dependencies": {"passport": "^0.7.0"}, "keywords": ["auth"]}`))
+	memfs.AddFile("templates/auth/merge/config.yml", []byte("auth:\n  provider: oauth\n"))
+	memfs.AddDir("templates/database")
+	memfs.AddDir("templates/database/merge")
+	memfs.AddFile("templates/database/merge/package.json", []byte(`{"dependencies": {"pg": "^8.11.0"}}`))
+	memfs.AddDir("project")
+	memfs.AddFile("project/package.json", []byte(packageJSON))
+	applyAndRecord(t, memfs, "database")
+	memfs.AddFile("project/package.json", []byte(`{"name": "app", "dependencies": {"react": "^18.0.0", "pg": "^8.12.0"}}`))
+
+	result, err := RemoveFeatures(context.Background(), memfs, &executor.FakeExecutor{}, "templates", "project", []string{"database"}, ApplyOptions{})
+	require.NoError(t, err)
+
+	assert.Equal(t, []string{"package.json: dependencies.pg"}, result.Kept)
+	data, err := memfs.ReadFile("project/package.json")
+	require.NoError(t, err)
+	assert.Contains(t, string(data), `"pg": "^8.12.0"`)
+}
+
+func TestPreviewFeatures_IncludesMerges(t *testing.T) {
+	memfs := fs.NewMemoryFS()
+	memfs.AddDir("templates")
+	memfs.AddDir("templates/auth")
+	memfs.AddFile("templates/auth/base.patch", []byte(authPatch))
+	memfs.AddDir("templates/auth/merge")
+	memfs.AddFile("templates/auth/merge/package.json", []byte(`{"dependencies": {"passport": "^0.7.0"}, "keywords": ["auth"]}`))
+	memfs.AddFile("templates/auth/merge/config.yml", []byte("auth:\n  provider: oauth\n"))
+	memfs.AddDir("templates/database")
+	memfs.AddDir("templates/database/merge")
+	memfs.AddFile("templates/database/merge/package.json", []byte(`{"dependencies": {"pg": "^8.11.0"}}`))
+	memfs.AddDir("project")
+	memfs.AddFile("project/package.json", []byte(packageJSON))
+
+	preview, err := PreviewFeatures(memfs, "templates", "project", []string{"auth"}, nil)
+	require.NoError(t, err)
+
+	require.Len(t, preview.Changes, 3)
+	assert.Equal(t, "config.yml", preview.Changes[1].Path)
+	assert.True(t, preview.Changes[1].IsAdded())
+	assert.Equal(t, "package.json", preview.Changes[2].Path)
+	assert.Contains(t, preview.Changes[2].New, `"passport": "^0.7.0"`)
+}
+
+func TestUnmergeFile_RemovesAppendedItems(t *testing.T) {
+	memfs := fs.NewMemoryFS()
+	memfs.AddFile("templates/auth/merge/config.yml", []byte("tags: [auth]\n"))
+	fragment := mergeFragment{Source: "templates/auth/merge/config.yml", Path: "config.yml", codec: yamlCodec{}}
+
+	merged, changes, _, err := mergeFile(memfs, fragment, []byte("tags: [web]\n"))
+	require.NoError(t, err)
+	assert.Equal(t, "tags: [web, auth]\n", string(merged))
+
+	data, empty, kept, err := unmergeFile(yamlCodec{}, merged, changes)
+	require.NoError(t, err)
+	assert.Equal(t, "tags: [web]\n", string(data))
+	assert.False(t, empty)
+	assert.Empty(t, kept)
+}
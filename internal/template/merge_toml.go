@@ -0,0 +1,586 @@
+package template
+
+import (
+	"bytes"
+	"fmt"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/pelletier/go-toml/v2"
+	"github.com/pelletier/go-toml/v2/unstable"
+	"gopkg.in/yaml.v3"
+)
+
+type tomlCodec struct{}
+
+type tomlDefKind int
+
+const (
+	tomlImplicit    tomlDefKind = iota // a table only named by deeper headers
+	tomlHeader                         // [table]
+	tomlDotted                         // a table named by a dotted key
+	tomlValue                          // key = value
+	tomlMember                         // a key of an inline table
+	tomlArrayTables                    // [[table]]
+)
+
+type tomlDef struct {
+	kind   tomlDefKind
+	expr   int
+	value  int // offset of the value of a key/value or member
+	parent int // offset of the inline table holding a member
+	index  int // position of a member in its inline table
+}
+
+type tomlExpr struct {
+	kind    unstable.Kind
+	keys    []string // the table of a header, or the full key of a key/value
+	start   int      // start of the expression's first line
+	section int      // index of the header a key/value sits under, -1 for the top level
+}
+
+type tomlDoc struct {
+	data  []byte
+	root  *yaml.Node
+	exprs []tomlExpr
+	defs  map[string]tomlDef
+}
+
+func (tomlCodec) decode(data []byte, fragment bool) (*yaml.Node, error) {
+	doc, err := parseTOML(data)
+	if err != nil {
+		return nil, err
+	}
+	return doc.root, nil
+}
+
+func parseTOML(data []byte) (*tomlDoc, error) {
+	var check map[string]any
+	if err := toml.Unmarshal(data, &check); err != nil {
+		return nil, err
+	}
+
+	doc := &tomlDoc{data: data, root: newMapping(), defs: make(map[string]tomlDef)}
+	current, section := doc.root, -1
+	var sectionKeys []string
+	parser := unstable.Parser{}
+	parser.Reset(data)
+	for parser.NextExpression() {
+		e := parser.Expression()
+		keys, first, keyEnd := tomlKeyParts(e)
+		expr := tomlExpr{kind: e.Kind, start: lineStart(data, first), section: section}
+		index := len(doc.exprs)
+
+		switch e.Kind {
+		case unstable.Table, unstable.ArrayTable:
+			expr.keys, expr.section = keys, -1
+			parent := doc.table(doc.root, nil, keys[:len(keys)-1], tomlDef{kind: tomlImplicit, expr: index})
+			name := keys[len(keys)-1]
+			if e.Kind == unstable.Table {
+				current = doc.table(parent, keys[:len(keys)-1], keys[len(keys)-1:], tomlDef{kind: tomlHeader, expr: index})
+				doc.defs[tomlPath(keys)] = tomlDef{kind: tomlHeader, expr: index}
+			} else {
+				seq := mappingValue(parent, name)
+				if seq == nil {
+					seq = &yaml.Node{Kind: yaml.SequenceNode, Tag: "!!seq"}
+					setMappingValue(parent, name, seq)
+					doc.defs[tomlPath(keys)] = tomlDef{kind: tomlArrayTables, expr: index}
+				}
+				current = newMapping()
+				seq.Content = append(seq.Content, current)
+			}
+			section, sectionKeys = index, keys
+		case unstable.KeyValue:
+			expr.keys = append(slices.Clone(sectionKeys), keys...)
+			parent := doc.table(current, sectionKeys, keys[:len(keys)-1], tomlDef{kind: tomlDotted, expr: index})
+			value := valueOffset(data, keyEnd)
+			node, err := doc.node(e.Value(), expr.keys, value)
+			if err != nil {
+				return nil, err
+			}
+			setMappingValue(parent, keys[len(keys)-1], node)
+			doc.defs[tomlPath(expr.keys)] = tomlDef{kind: tomlValue, expr: index, value: value}
+		}
+		doc.exprs = append(doc.exprs, expr)
+	}
+	if err := parser.Error(); err != nil {
+		return nil, err
+	}
+	return doc, nil
+}
+
+// table walks keys below parent, creating the tables it has not seen yet and
+// recording def for them; headers descend into the last table of an array.
+func (doc *tomlDoc) table(parent *yaml.Node, prefix, keys []string, def tomlDef) *yaml.Node {
+	full := slices.Clone(prefix)
+	for _, name := range keys {
+		full = append(full, name)
+		child := mappingValue(parent, name)
+		if child == nil {
+			child = newMapping()
+			setMappingValue(parent, name, child)
+			doc.defs[tomlPath(full)] = def
+		}
+		if child.Kind == yaml.SequenceNode && len(child.Content) > 0 {
+			child = child.Content[len(child.Content)-1]
+		}
+		parent = child
+	}
+	return parent
+}
+
+func (doc *tomlDoc) node(n *unstable.Node, keys []string, offset int) (*yaml.Node, error) {
+	switch n.Kind {
+	case unstable.String:
+		return newScalar("!!str", string(n.Data)), nil
+	case unstable.Integer:
+		return newScalar("!!int", string(n.Data)), nil
+	case unstable.Float:
+		return newScalar("!!float", string(n.Data)), nil
+	case unstable.Bool:
+		return newScalar("!!bool", string(n.Data)), nil
+	case unstable.LocalDate, unstable.LocalTime, unstable.LocalDateTime, unstable.DateTime:
+		return newScalar("!!timestamp", string(n.Data)), nil
+	case unstable.Array:
+		seq := &yaml.Node{Kind: yaml.SequenceNode, Tag: "!!seq"}
+		for it := n.Children(); it.Next(); {
+			item, err := doc.node(it.Node(), keys, -1)
+			if err != nil {
+				return nil, err
+			}
+			seq.Content = append(seq.Content, item)
+		}
+		return seq, nil
+	case unstable.InlineTable:
+		table := newMapping()
+		index := 0
+		for it := n.Children(); it.Next(); index++ {
+			member := it.Node()
+			names, _, keyEnd := tomlKeyParts(member)
+			full := append(slices.Clone(keys), names...)
+			value := valueOffset(doc.data, keyEnd)
+			child, err := doc.node(member.Value(), full, value)
+			if err != nil {
+				return nil, err
+			}
+			setMappingValue(doc.table(table, keys, names[:len(names)-1], tomlDef{kind: tomlImplicit}), names[len(names)-1], child)
+			doc.defs[tomlPath(full)] = tomlDef{kind: tomlMember, value: value, parent: offset, index: index}
+		}
+		return table, nil
+	}
+	return nil, fmt.Errorf("%s: unsupported TOML value", displayKey(keys))
+}
+
+func tomlKeyParts(n *unstable.Node) (keys []string, first, end int) {
+	first = -1
+	for it := n.Key(); it.Next(); {
+		key := it.Node()
+		if first < 0 {
+			first = int(key.Raw.Offset)
+		}
+		keys = append(keys, string(key.Data))
+		end = int(key.Raw.Offset + key.Raw.Length)
+	}
+	return keys, first, end
+}
+
+func valueOffset(data []byte, keyEnd int) int {
+	offset := keyEnd + bytes.IndexByte(data[keyEnd:], '=') + 1
+	for offset < len(data) && (data[offset] == ' ' || data[offset] == '\t') {
+		offset++
+	}
+	return offset
+}
+
+func tomlPath(keys []string) string {
+	return strings.Join(keys, "\x00")
+}
+
+func (tomlCodec) set(data []byte, key []string, value *yaml.Node) ([]byte, error) {
+	doc, err := parseTOML(withNewline(data))
+	if err != nil {
+		return nil, err
+	}
+	parentKeys, name := key[:len(key)-1], key[len(key)-1]
+	line := formatTOMLKey(name) + " = "
+	if len(parentKeys) == 0 {
+		return doc.insert(-1, key, value)
+	}
+
+	def, ok := doc.defs[tomlPath(parentKeys)]
+	switch {
+	case !ok:
+	case def.kind == tomlHeader:
+		return doc.insert(def.expr, key, value)
+	case def.kind == tomlImplicit:
+		return doc.appendTable(parentKeys, name, value)
+	case def.kind == tomlDotted:
+		section := doc.exprs[def.expr].section
+		sectionKeys := []string(nil)
+		if section >= 0 {
+			sectionKeys = doc.exprs[section].keys
+		}
+		var relative []string
+		for _, k := range key[len(sectionKeys):] {
+			relative = append(relative, formatTOMLKey(k))
+		}
+		formatted, err := formatTOMLValue(value)
+		if err != nil {
+			return nil, err
+		}
+		at := doc.lastValue(section, parentKeys)
+		return splice(doc.data, at, at, strings.Join(relative, ".")+" = "+formatted+"\n"), nil
+	case (def.kind == tomlValue || def.kind == tomlMember) && doc.data[def.value] == '{':
+		formatted, err := formatTOMLValue(value)
+		if err != nil {
+			return nil, err
+		}
+		container, err := scanFlow(doc.data, def.value)
+		if err != nil {
+			return nil, err
+		}
+		return insertFlow(doc.data, container, false, "", func(string) []string { return []string{line + formatted} }), nil
+	}
+	return nil, fmt.Errorf("%s is not a table", displayKey(parentKeys))
+}
+
+// insert adds key to the table opened by the header at section, or to the top
+// level; tables become sections of their own at the end of the file.
+func (doc *tomlDoc) insert(section int, key []string, value *yaml.Node) ([]byte, error) {
+	if value.Kind == yaml.MappingNode {
+		return doc.appendTable(key[:len(key)-1], key[len(key)-1], value)
+	}
+	if isArrayOfTables(value) {
+		return doc.appendArrayTables(key, value.Content)
+	}
+	formatted, err := formatTOMLValue(value)
+	if err != nil {
+		return nil, err
+	}
+	line := formatTOMLKey(key[len(key)-1]) + " = " + formatted + "\n"
+
+	at := doc.lastValue(section, nil)
+	switch {
+	case at >= 0:
+	case section >= 0:
+		at = nextLine(doc.data, doc.exprs[section].start)
+	default:
+		at = len(doc.data)
+		for _, e := range doc.exprs {
+			if e.kind != unstable.KeyValue {
+				at, line = e.start, line+"\n"
+				break
+			}
+		}
+	}
+	return splice(doc.data, at, at, line), nil
+}
+
+func (doc *tomlDoc) appendTable(parentKeys []string, name string, value *yaml.Node) ([]byte, error) {
+	table := newMapping()
+	table.Content = append(table.Content, newScalar("!!str", name), value)
+	var keys []string
+	for _, k := range parentKeys {
+		keys = append(keys, formatTOMLKey(k))
+	}
+	var out bytes.Buffer
+	if err := writeTOMLTable(&out, table, keys, false); err != nil {
+		return nil, err
+	}
+	return doc.appendSection(out.String()), nil
+}
+
+func (doc *tomlDoc) appendArrayTables(key []string, tables []*yaml.Node) ([]byte, error) {
+	var keys []string
+	for _, k := range key {
+		keys = append(keys, formatTOMLKey(k))
+	}
+	var out bytes.Buffer
+	for _, table := range tables {
+		if err := writeTOMLTable(&out, table, keys, true); err != nil {
+			return nil, err
+		}
+	}
+	return doc.appendSection(out.String()), nil
+}
+
+func isArrayOfTables(node *yaml.Node) bool {
+	if node.Kind != yaml.SequenceNode || len(node.Content) == 0 {
+		return false
+	}
+	for _, item := range node.Content {
+		if item.Kind != yaml.MappingNode {
+			return false
+		}
+	}
+	return true
+}
+
+func (doc *tomlDoc) appendSection(text string) []byte {
+	if len(bytes.TrimSpace(doc.data)) == 0 {
+		return []byte(text)
+	}
+	return append(append(slices.Clone(doc.data), '\n'), text...)
+}
+
+// lastValue returns the end of the last key/value under section whose key
+// starts with prefix, or -1 when there is none.
+func (doc *tomlDoc) lastValue(section int, prefix []string) int {
+	at := -1
+	for i, e := range doc.exprs {
+		if e.kind == unstable.KeyValue && e.section == section && hasKeyPrefix(e.keys, prefix) {
+			at = doc.valueEnd(i)
+		}
+	}
+	return at
+}
+
+// valueEnd returns the end of the i-th expression, leaving the blank lines
+// and comments that follow it to the next one.
+func (doc *tomlDoc) valueEnd(i int) int {
+	end := len(doc.data)
+	if i+1 < len(doc.exprs) {
+		end = doc.exprs[i+1].start
+	}
+	for end > doc.exprs[i].start {
+		start := lineStart(doc.data, end-1)
+		line := strings.TrimSpace(string(doc.data[start:end]))
+		if start == doc.exprs[i].start || (line != "" && !strings.HasPrefix(line, "#")) {
+			break
+		}
+		end = start
+	}
+	return end
+}
+
+// sectionEnd returns where the section opened by the i-th header ends: at the
+// next header that is not one of its sub-tables, keeping a comment written
+// directly above that header.
+func (doc *tomlDoc) sectionEnd(i int, within []string) int {
+	end := len(doc.data)
+	for j := i + 1; j < len(doc.exprs); j++ {
+		e := doc.exprs[j]
+		if e.kind == unstable.KeyValue || (within != nil && hasKeyPrefix(e.keys, within) && len(e.keys) > len(within)) {
+			continue
+		}
+		end = e.start
+		for end > doc.exprs[i].start {
+			start := lineStart(doc.data, end-1)
+			if !strings.HasPrefix(strings.TrimSpace(string(doc.data[start:end])), "#") {
+				break
+			}
+			end = start
+		}
+		break
+	}
+	return end
+}
+
+func (tomlCodec) appendItems(data []byte, key []string, items []yaml.Node) ([]byte, error) {
+	doc, err := parseTOML(withNewline(data))
+	if err != nil {
+		return nil, err
+	}
+	def, ok := doc.defs[tomlPath(key)]
+	switch {
+	case ok && def.kind == tomlArrayTables:
+		tables := make([]*yaml.Node, len(items))
+		for i := range items {
+			if items[i].Kind != yaml.MappingNode {
+				return nil, fmt.Errorf("%s: cannot add %s to an array of tables", displayKey(key), describeNode(&items[i]))
+			}
+			tables[i] = &items[i]
+		}
+		return doc.appendArrayTables(key, tables)
+	case ok && (def.kind == tomlValue || def.kind == tomlMember) && doc.data[def.value] == '[':
+		entries := make([]string, len(items))
+		for i := range items {
+			if entries[i], err = formatTOMLValue(&items[i]); err != nil {
+				return nil, err
+			}
+		}
+		container, err := scanFlow(doc.data, def.value)
+		if err != nil {
+			return nil, err
+		}
+		return insertFlow(doc.data, container, false, "", func(string) []string { return entries }), nil
+	}
+	return nil, fmt.Errorf("%s is not an array", displayKey(key))
+}
+
+func (tomlCodec) remove(data []byte, key []string) ([]byte, error) {
+	doc, err := parseTOML(data)
+	if err != nil {
+		return nil, err
+	}
+	def, ok := doc.defs[tomlPath(key)]
+	if !ok {
+		return nil, fmt.Errorf("%s not found", displayKey(key))
+	}
+	if def.kind == tomlMember {
+		container, err := scanFlow(data, def.parent)
+		if err != nil {
+			return nil, err
+		}
+		return removeFlow(data, container, def.index), nil
+	}
+
+	var spans []textSpan
+	removed := make(map[int]bool)
+	for i, e := range doc.exprs {
+		switch {
+		case !hasKeyPrefix(e.keys, key):
+		case e.kind != unstable.KeyValue:
+			removed[i] = true
+			spans = append(spans, textSpan{e.start, doc.sectionEnd(i, nil)})
+		case !removed[e.section]:
+			spans = append(spans, textSpan{e.start, doc.valueEnd(i)})
+		}
+	}
+	return doc.cut(spans), nil
+}
+
+func (tomlCodec) removeItem(data []byte, key []string, index int) ([]byte, error) {
+	doc, err := parseTOML(data)
+	if err != nil {
+		return nil, err
+	}
+	def, ok := doc.defs[tomlPath(key)]
+	switch {
+	case ok && def.kind == tomlArrayTables:
+		for i, e := range doc.exprs {
+			if e.kind != unstable.ArrayTable || !slices.Equal(e.keys, key) {
+				continue
+			}
+			if index == 0 {
+				return doc.cut([]textSpan{{e.start, doc.sectionEnd(i, key)}}), nil
+			}
+			index--
+		}
+	case ok && (def.kind == tomlValue || def.kind == tomlMember) && data[def.value] == '[':
+		container, err := scanFlow(data, def.value)
+		if err != nil {
+			return nil, err
+		}
+		return removeFlow(data, container, index), nil
+	}
+	return nil, fmt.Errorf("%s: item %d not found", displayKey(key), index)
+}
+
+// cut removes spans from the document; a span reaching the end of the file
+// also takes the blank lines that separated it from what came before.
+func (doc *tomlDoc) cut(spans []textSpan) []byte {
+	sort.Slice(spans, func(i, j int) bool { return spans[i].start < spans[j].start })
+	var merged []textSpan
+	for _, s := range spans {
+		if n := len(merged); n > 0 && s.start <= merged[n-1].end {
+			merged[n-1].end = max(merged[n-1].end, s.end)
+			continue
+		}
+		merged = append(merged, s)
+	}
+
+	data := doc.data
+	for i := len(merged) - 1; i >= 0; i-- {
+		s := merged[i]
+		if s.end == len(doc.data) {
+			for s.start > 0 {
+				start := lineStart(data, s.start-1)
+				if strings.TrimSpace(string(data[start:s.start])) != "" {
+					break
+				}
+				s.start = start
+			}
+		}
+		data = splice(data, s.start, s.end, "")
+	}
+	return data
+}
+
+func hasKeyPrefix(keys, prefix []string) bool {
+	return len(keys) >= len(prefix) && slices.Equal(keys[:len(prefix)], prefix)
+}
+
+func writeTOMLTable(out *bytes.Buffer, table *yaml.Node, keys []string, array bool) error {
+	var tables []int
+	wroteHeader := false
+	writeHeader := func() {
+		if wroteHeader || len(keys) == 0 {
+			return
+		}
+		if out.Len() > 0 {
+			out.WriteByte('\n')
+		}
+		if array {
+			out.WriteString("[[" + strings.Join(keys, ".") + "]]\n")
+		} else {
+			out.WriteString("[" + strings.Join(keys, ".") + "]\n")
+		}
+		wroteHeader = true
+	}
+	if array {
+		writeHeader()
+	}
+	for i := 0; i < len(table.Content); i += 2 {
+		value := table.Content[i+1]
+		if value.Kind == yaml.MappingNode {
+			tables = append(tables, i)
+			continue
+		}
+		writeHeader()
+		formatted, err := formatTOMLValue(value)
+		if err != nil {
+			return err
+		}
+		out.WriteString(formatTOMLKey(table.Content[i].Value) + " = " + formatted + "\n")
+	}
+	for _, i := range tables {
+		sub := append(slices.Clone(keys), formatTOMLKey(table.Content[i].Value))
+		if err := writeTOMLTable(out, table.Content[i+1], sub, false); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func formatTOMLKey(key string) string {
+	if key == "" || strings.ContainsAny(key, " .'\"[]{}#=") {
+		return strconv.Quote(key)
+	}
+	return key
+}
+
+func formatTOMLValue(node *yaml.Node) (string, error) {
+	switch node.Kind {
+	case yaml.SequenceNode:
+		items := make([]string, len(node.Content))
+		for i, item := range node.Content {
+			formatted, err := formatTOMLValue(item)
+			if err != nil {
+				return "", err
+			}
+			items[i] = formatted
+		}
+		return "[" + strings.Join(items, ", ") + "]", nil
+	case yaml.MappingNode:
+		members := make([]string, 0, len(node.Content)/2)
+		for i := 0; i+1 < len(node.Content); i += 2 {
+			formatted, err := formatTOMLValue(node.Content[i+1])
+			if err != nil {
+				return "", err
+			}
+			members = append(members, formatTOMLKey(node.Content[i].Value)+" = "+formatted)
+		}
+		return "{" + strings.Join(members, ", ") + "}", nil
+	case yaml.ScalarNode:
+		switch node.ShortTag() {
+		case "!!int", "!!float", "!!bool", "!!timestamp":
+			return node.Value, nil
+		case "!!str":
+			return strconv.Quote(node.Value), nil
+		}
+	}
+	return "", fmt.Errorf("cannot write %s as TOML", describeNode(node))
+}
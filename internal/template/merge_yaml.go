@@ -0,0 +1,231 @@
+package template
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+	"unicode/utf8"
+
+	"gopkg.in/yaml.v3"
+)
+
+type yamlCodec struct{}
+
+func (yamlCodec) decode(data []byte, fragment bool) (*yaml.Node, error) {
+	var doc yaml.Node
+	if err := yaml.Unmarshal(data, &doc); err != nil {
+		return nil, err
+	}
+	if len(doc.Content) == 0 {
+		return newMapping(), nil
+	}
+	return doc.Content[0], nil
+}
+
+func (c yamlCodec) set(data []byte, key []string, value *yaml.Node) ([]byte, error) {
+	data = withNewline(data)
+	parent, err := c.locate(data, key[:len(key)-1], yaml.MappingNode)
+	if err != nil {
+		return nil, err
+	}
+	name := key[len(key)-1]
+	if parent.Style&yaml.FlowStyle != 0 {
+		container, err := scanFlow(data, yamlOffset(data, parent))
+		if err != nil {
+			return nil, err
+		}
+		entry := yamlFlow(newScalar("!!str", name)) + ": " + yamlFlow(value)
+		return insertFlow(data, container, false, "", func(string) []string { return []string{entry} }), nil
+	}
+
+	entry := newMapping()
+	entry.Content = append(entry.Content, newScalar("!!str", name), value)
+	text, err := yamlBlock(entry, yamlIndentUnit(data))
+	if err != nil {
+		return nil, err
+	}
+	if len(parent.Content) == 0 {
+		return append(data, text...), nil
+	}
+	indent := parent.Content[0].Column - 1
+	end := yamlEntryEnd(data, parent, len(parent.Content)/2-1)
+	return splice(data, end, end, indentLines(text, indent)), nil
+}
+
+func (c yamlCodec) appendItems(data []byte, key []string, items []yaml.Node) ([]byte, error) {
+	data = withNewline(data)
+	seq, err := c.locate(data, key, yaml.SequenceNode)
+	if err != nil {
+		return nil, err
+	}
+	if seq.Style&yaml.FlowStyle != 0 {
+		container, err := scanFlow(data, yamlOffset(data, seq))
+		if err != nil {
+			return nil, err
+		}
+		return insertFlow(data, container, false, "", func(string) []string {
+			entries := make([]string, len(items))
+			for i := range items {
+				entries[i] = yamlFlow(&items[i])
+			}
+			return entries
+		}), nil
+	}
+
+	added := &yaml.Node{Kind: yaml.SequenceNode, Tag: "!!seq"}
+	for i := range items {
+		added.Content = append(added.Content, &items[i])
+	}
+	text, err := yamlBlock(added, yamlIndentUnit(data))
+	if err != nil {
+		return nil, err
+	}
+	end := yamlItemEnd(data, seq, len(seq.Content)-1)
+	return splice(data, end, end, indentLines(text, seq.Column-1)), nil
+}
+
+func (c yamlCodec) remove(data []byte, key []string) ([]byte, error) {
+	parent, err := c.locate(data, key[:len(key)-1], yaml.MappingNode)
+	if err != nil {
+		return nil, err
+	}
+	i := pairIndex(parent, key[len(key)-1])
+	if i < 0 {
+		return nil, fmt.Errorf("%s not found", displayKey(key))
+	}
+	if parent.Style&yaml.FlowStyle != 0 {
+		container, err := scanFlow(data, yamlOffset(data, parent))
+		if err != nil {
+			return nil, err
+		}
+		return removeFlow(data, container, i), nil
+	}
+	start := lineStart(data, yamlOffset(data, parent.Content[2*i]))
+	return splice(data, start, yamlEntryEnd(data, parent, i), ""), nil
+}
+
+func (c yamlCodec) removeItem(data []byte, key []string, index int) ([]byte, error) {
+	seq, err := c.locate(data, key, yaml.SequenceNode)
+	if err != nil {
+		return nil, err
+	}
+	if seq.Style&yaml.FlowStyle != 0 {
+		container, err := scanFlow(data, yamlOffset(data, seq))
+		if err != nil {
+			return nil, err
+		}
+		return removeFlow(data, container, index), nil
+	}
+	start := lineStart(data, yamlOffset(data, seq.Content[index]))
+	return splice(data, start, yamlItemEnd(data, seq, index), ""), nil
+}
+
+func (c yamlCodec) locate(data []byte, key []string, kind yaml.Kind) (*yaml.Node, error) {
+	doc, err := c.decode(data, false)
+	if err != nil {
+		return nil, err
+	}
+	node := lookupNode(doc, key)
+	if node == nil || node.Kind != kind {
+		return nil, fmt.Errorf("%s not found", displayKey(key))
+	}
+	return node, nil
+}
+
+// yamlEntryEnd returns the offset just past the last line of the i-th entry
+// of a block mapping: every following line indented deeper than its key, plus
+// the dashes of a sequence written at the key's own indentation.
+func yamlEntryEnd(data []byte, mapping *yaml.Node, i int) int {
+	key, value := mapping.Content[2*i], resolveAlias(mapping.Content[2*i+1])
+	indent := key.Column - 1
+	flush := value.Kind == yaml.SequenceNode && value.Style&yaml.FlowStyle == 0 && value.Column-1 == indent
+	return yamlBlockEnd(data, yamlOffset(data, key), func(depth int, line string) bool {
+		return depth > indent || (flush && depth == indent && (line == "-" || strings.HasPrefix(line, "- ")))
+	})
+}
+
+func yamlItemEnd(data []byte, seq *yaml.Node, i int) int {
+	dash := seq.Column - 1
+	return yamlBlockEnd(data, yamlOffset(data, seq.Content[i]), func(depth int, _ string) bool {
+		return depth > dash
+	})
+}
+
+// yamlBlockEnd scans the lines after the one holding offset while inside
+// accepts them, and returns the end of the last non-blank line it kept.
+func yamlBlockEnd(data []byte, offset int, inside func(depth int, line string) bool) int {
+	end := nextLine(data, offset)
+	for next := end; next < len(data); {
+		lineEnd := nextLine(data, next)
+		line := strings.TrimRight(string(data[next:lineEnd]), "\r\n")
+		trimmed := strings.TrimLeft(line, " ")
+		if trimmed != "" {
+			if !inside(len(line)-len(trimmed), trimmed) {
+				break
+			}
+			end = lineEnd
+		}
+		next = lineEnd
+	}
+	return end
+}
+
+func yamlOffset(data []byte, node *yaml.Node) int {
+	offset := 0
+	for line := 1; line < node.Line; line++ {
+		offset = nextLine(data, offset)
+	}
+	for col := 1; col < node.Column && offset < len(data); col++ {
+		_, size := utf8.DecodeRune(data[offset:])
+		offset += size
+	}
+	return offset
+}
+
+func yamlIndentUnit(data []byte) int {
+	if unit := len(indentUnit(data, "  ")); unit >= 2 {
+		return unit
+	}
+	return 2
+}
+
+func yamlBlock(node *yaml.Node, unit int) (string, error) {
+	var out bytes.Buffer
+	encoder := yaml.NewEncoder(&out)
+	encoder.SetIndent(unit)
+	if err := encoder.Encode(node); err != nil {
+		return "", err
+	}
+	if err := encoder.Close(); err != nil {
+		return "", err
+	}
+	return out.String(), nil
+}
+
+func yamlFlow(node *yaml.Node) string {
+	flow := copyNode(node)
+	var setFlow func(*yaml.Node)
+	setFlow = func(n *yaml.Node) {
+		if n.Kind != yaml.ScalarNode {
+			n.Style |= yaml.FlowStyle
+		}
+		n.HeadComment, n.LineComment, n.FootComment = "", "", ""
+		for _, child := range n.Content {
+			setFlow(child)
+		}
+	}
+	setFlow(flow)
+	out, _ := yaml.Marshal(flow)
+	return strings.TrimSuffix(string(out), "\n")
+}
+
+func indentLines(text string, indent int) string {
+	prefix := strings.Repeat(" ", indent)
+	lines := strings.SplitAfter(text, "\n")
+	for i, line := range lines {
+		if strings.TrimSpace(line) != "" {
+			lines[i] = prefix + line
+		}
+	}
+	return strings.Join(lines, "")
+}
@@ -0,0 +1,191 @@
+package template
+
+import (
+	"bytes"
+	"fmt"
+	"path"
+	"sort"
+	"strings"
+
+	"templater/internal/fs"
+	"templater/internal/patch"
+)
+
+type OverlapKind int
+
+const (
+	OverlapExists OverlapKind = iota
+	OverlapMissing
+	OverlapShared
+	OverlapKey
+)
+
+type Overlap struct {
+	Path      string
+	Kind      OverlapKind
+	Features  []string
+	PatchPath string
+	Deleted   bool
+	Key       MergeConflict
+}
+
+func (o Overlap) Blocking() bool {
+	return o.Kind != OverlapShared
+}
+
+func (o Overlap) String() string {
+	features := make([]string, len(o.Features))
+	for i, feature := range o.Features {
+		features[i] = displayFeature(feature)
+	}
+
+	switch o.Kind {
+	case OverlapExists:
+		return fmt.Sprintf("%s would be created by %s but already exists", o.Path, features[0])
+	case OverlapMissing:
+		verb := "modified"
+		if o.Deleted {
+			verb = "deleted"
+		}
+		return fmt.Sprintf("%s would be %s by %s but does not exist", o.Path, verb, features[0])
+	case OverlapKey:
+		return fmt.Sprintf("%s: %s is %s but %s would set %s", o.Path, o.Key.Key, o.Key.Existing, features[0], o.Key.Wanted)
+	}
+	return fmt.Sprintf("%s is touched by %s", o.Path, strings.Join(features, ", "))
+}
+
+func CheckOverlaps(overlaps []Overlap) error {
+	var blocking []Overlap
+	for _, o := range overlaps {
+		if o.Blocking() {
+			blocking = append(blocking, o)
+		}
+	}
+	if len(blocking) == 0 {
+		return nil
+	}
+	return &OverlapError{Overlaps: blocking}
+}
+
+func analyzeOverlaps(fileSystem fs.FileSystem, sources Sources, targetPath string, refs []FeatureRef, previous map[string]FeatureRef, vars map[string]map[string]string) ([]Overlap, error) {
+	merged := make(map[string][]byte)
+	exists := make(map[string]bool)
+	lookup := func(name string) bool {
+		if e, ok := exists[name]; ok {
+			return e
+		}
+		_, err := fileSystem.Stat(path.Join(targetPath, name))
+		exists[name] = err == nil
+		return exists[name]
+	}
+
+	var overlaps []Overlap
+	touchedBy := make(map[string][]string)
+	for _, ref := range refs {
+		_, baseApplied := previous[ref.Name]
+		templatePath, local := sources.locate(ref)
+		patches, err := refPatches(fileSystem, templatePath, local, baseApplied)
+		if err != nil {
+			return nil, err
+		}
+
+		for _, patchPath := range patches {
+			data, err := fileSystem.ReadFile(patchPath)
+			if err != nil {
+				return nil, err
+			}
+			files, err := patch.Parse(data)
+			if err != nil {
+				return nil, fmt.Errorf("%s: %w", patchPath, err)
+			}
+
+			for _, f := range files {
+				name := f.Path()
+				source := name
+				if f.OldPath != "" {
+					source = f.OldPath
+				}
+
+				switch {
+				case f.IsNew && lookup(name):
+					overlaps = append(overlaps, Overlap{Path: name, Kind: OverlapExists, Features: []string{ref.String()}, PatchPath: patchPath})
+				case !f.IsNew && !lookup(source):
+					overlaps = append(overlaps, Overlap{Path: source, Kind: OverlapMissing, Features: []string{ref.String()}, PatchPath: patchPath, Deleted: f.IsDeleted})
+				}
+
+				if source != name {
+					exists[source] = false
+				}
+				exists[name] = !f.IsDeleted
+
+				touch(touchedBy, name, ref.String())
+			}
+		}
+
+		if baseApplied {
+			continue
+		}
+		files, err := overlayFiles(fileSystem, templatePath, local, vars[ref.Name])
+		if err != nil {
+			return nil, err
+		}
+		for _, f := range files {
+			if lookup(f.Path) && !sameContent(fileSystem, f.Data, path.Join(targetPath, f.Path)) {
+				overlaps = append(overlaps, Overlap{Path: f.Path, Kind: OverlapExists, Features: []string{ref.String()}, PatchPath: f.Source})
+			}
+			exists[f.Path] = true
+			touch(touchedBy, f.Path, ref.String())
+		}
+
+		fragments, err := mergeFragments(fileSystem, templatePath, local.Name)
+		if err != nil {
+			return nil, err
+		}
+		for _, fragment := range fragments {
+			existing, ok := merged[fragment.Path]
+			if !ok {
+				existing, _ = fileSystem.ReadFile(path.Join(targetPath, fragment.Path))
+			}
+			result, _, conflicts, err := mergeFile(fileSystem, fragment, existing)
+			if err != nil {
+				return nil, err
+			}
+			overlaps = append(overlaps, mergeOverlaps(ref.String(), fragment, conflicts)...)
+			merged[fragment.Path] = result
+			exists[fragment.Path] = true
+		}
+
+		manifest, err := ReadManifest(fileSystem, templatePath, local.Name)
+		if err != nil {
+			return nil, err
+		}
+		for _, inj := range manifest.Inject {
+			if !lookup(inj.File) {
+				overlaps = append(overlaps, Overlap{Path: inj.File, Kind: OverlapMissing, Features: []string{ref.String()}, PatchPath: manifestPath(templatePath, local.Name)})
+			}
+		}
+	}
+
+	var shared []string
+	for name, users := range touchedBy {
+		if len(users) > 1 {
+			shared = append(shared, name)
+		}
+	}
+	sort.Strings(shared)
+	for _, name := range shared {
+		overlaps = append(overlaps, Overlap{Path: name, Kind: OverlapShared, Features: touchedBy[name]})
+	}
+	return overlaps, nil
+}
+
+func touch(touchedBy map[string][]string, name, feature string) {
+	if users := touchedBy[name]; len(users) == 0 || users[len(users)-1] != feature {
+		touchedBy[name] = append(users, feature)
+	}
+}
+
+func sameContent(fileSystem fs.FileSystem, data []byte, filePath string) bool {
+	existing, err := fileSystem.ReadFile(filePath)
+	return err == nil && bytes.Equal(existing, data)
+}
@@ -0,0 +1,151 @@
+package template
+
+import (
+	"context"
+	"testing"
+
+	"templater/internal/testutil/executor"
+	"templater/internal/testutil/fs"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const newComposePatch = "diff --git a/docker-compose.yml b/docker-compose.yml\n" +
+	"new file mode 100644\n" +
+	"--- /dev/null\n" +
+	"+++ b/docker-compose.yml\n" +
+	"@@ -0,0 +1 @@\n" +
+	"+services: {}\n"
+
+const deleteReadmePatch = "diff --git a/README.md b/README.md\n" +
+	"deleted file mode 100644\n" +
+	"--- a/README.md\n" +
+	"+++ /dev/null\n" +
+	"@@ -1 +0,0 @@\n" +
+	"-# Project\n"
+
+const modifyAuthPatch = "diff --git a/auth.go b/auth.go\n" +
+	"--- a/auth.go\n" +
+	"+++ b/auth.go\n" +
+	"@@ -1 +1,2 @@\n" +
+	" package auth\n" +
+	"+// oauth\n"
+
+func TestAnalyzeOverlaps_FileCreatedByEarlierFeatureIsNotMissing(t *testing.T) {
+	memfs := fs.NewMemoryFS()
+	memfs.AddDir("templates")
+	memfs.AddDir("templates/auth")
+	memfs.AddFile("templates/auth/base.patch", []byte(authPatch))
+	memfs.AddDir("templates/auth/oauth")
+	memfs.AddFile("templates/auth/oauth/base.patch", []byte(modifyAuthPatch))
+	memfs.AddDir("templates/database")
+	memfs.AddFile("templates/database/base.patch", []byte(newComposePatch+deleteReadmePatch))
+	memfs.AddDir("project")
+	memfs.AddFile("project/README.md", []byte("# Project\n"))
+
+	overlaps, err := analyzeOverlaps(memfs, Sources{"": "templates"}, "project", []FeatureRef{{Name: "auth"}, {Name: "auth/oauth"}}, nil, nil)
+	require.NoError(t, err)
+
+	assert.Equal(t, []Overlap{
+		{Path: "auth.go", Kind: OverlapShared, Features: []string{"auth", "auth/oauth"}},
+	}, overlaps)
+	assert.NoError(t, CheckOverlaps(overlaps))
+}
+
+func TestAnalyzeOverlaps_ReportsExistingAndMissingPaths(t *testing.T) {
+	memfs := fs.NewMemoryFS()
+	memfs.AddDir("templates")
+	memfs.AddDir("templates/auth")
+	memfs.AddFile("templates/auth/base.patch", []byte(authPatch))
+	memfs.AddDir("templates/auth/oauth")
+	memfs.AddFile("templates/auth/oauth/base.patch", []byte(modifyAuthPatch))
+	memfs.AddDir("templates/database")
+	memfs.AddFile("templates/database/base.patch", []byte(newComposePatch+deleteReadmePatch))
+	memfs.AddDir("project")
+	memfs.AddFile("project/docker-compose.yml", []byte("services: {}\n"))
+
+	overlaps, err := analyzeOverlaps(memfs, Sources{"": "templates"}, "project", []FeatureRef{{Name: "auth/oauth"}, {Name: "database"}}, nil, nil)
+	require.NoError(t, err)
+
+	require.Len(t, overlaps, 3)
+	assert.Equal(t, "auth.go would be modified by auth/oauth but does not exist", overlaps[0].String())
+	assert.Equal(t, "docker-compose.yml would be created by database but already exists", overlaps[1].String())
+	assert.Equal(t, "README.md would be deleted by database but does not exist", overlaps[2].String())
+	assert.Equal(t, "templates/database/base.patch", overlaps[1].PatchPath)
+}
+
+func TestApplyFeatures_StopsOnOverlapsBeforeRunningCommands(t *testing.T) {
+	memfs := fs.NewMemoryFS()
+	memfs.AddDir("templates")
+	memfs.AddDir("templates/auth")
+	memfs.AddFile("templates/auth/base.patch", []byte(authPatch))
+	memfs.AddDir("templates/auth/oauth")
+	memfs.AddFile("templates/auth/oauth/base.patch", []byte(modifyAuthPatch))
+	memfs.AddDir("templates/database")
+	memfs.AddFile("templates/database/base.patch", []byte(newComposePatch+deleteReadmePatch))
+	memfs.AddDir("project")
+	memfs.AddFile("project/README.md", []byte("# Project\n"))
+	memfs.AddFile("project/docker-compose.yml", []byte("services: {}\n"))
+	exec := &executor.FakeExecutor{}
+
+	_, err := ApplyFeatures(context.Background(), memfs, exec, "templates", "project", []string{"database"}, ApplyOptions{})
+
+	var overlapErr *OverlapError
+	require.ErrorAs(t, err, &overlapErr)
+	assert.Equal(t, "failed to apply database: docker-compose.yml would be created by database but already exists", err.Error())
+	assert.Equal(t, "docker-compose.yml", overlapErr.Overlaps[0].Path)
+	assert.Equal(t, OverlapExists, overlapErr.Overlaps[0].Kind)
+	assert.Empty(t, exec.Commands)
+}
+
+func TestApplyFeatures_ReportsMalformedPatchBeforeRunningCommands(t *testing.T) {
+	memfs := fs.NewMemoryFS()
+	memfs.AddDir("templates")
+	memfs.AddDir("templates/auth")
+	memfs.AddFile("templates/auth/base.patch", []byte(authPatch))
+	memfs.AddDir("templates/auth/oauth")
+	memfs.AddFile("templates/auth/oauth/base.patch", []byte(modifyAuthPatch))
+	memfs.AddDir("templates/database")
+	memfs.AddFile("templates/database/base.patch", []byte(newComposePatch+deleteReadmePatch))
+	memfs.AddDir("project")
+	memfs.AddFile("templates/database/base.patch", []byte("diff --git a/db.go b/db.go\n--- a/db.go\n+++ b/db.go\n@@ bogus @@\n"))
+	exec := &executor.FakeExecutor{}
+
+	_, err := ApplyFeatures(context.Background(), memfs, exec, "templates", "project", []string{"database"}, ApplyOptions{})
+
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "templates/database/base.patch: ")
+	assert.Empty(t, exec.Commands)
+}
+
+func TestOverlapError_ListsEveryPath(t *testing.T) {
+	err := &OverlapError{Overlaps: []Overlap{
+		{Path: "a.txt", Kind: OverlapExists, Features: []string{""}},
+		{Path: "b.txt", Kind: OverlapMissing, Features: []string{"db"}},
+	}}
+
+	assert.Equal(t, "failed to apply (root): 2 paths would not apply:\n"+
+		"  a.txt would be created by (root) but already exists\n"+
+		"  b.txt would be modified by db but does not exist", err.Error())
+}
+
+func TestDryRun_ReportsOverlaps(t *testing.T) {
+	memfs := fs.NewMemoryFS()
+	memfs.AddDir("templates")
+	memfs.AddDir("templates/auth")
+	memfs.AddFile("templates/auth/base.patch", []byte(authPatch))
+	memfs.AddDir("templates/auth/oauth")
+	memfs.AddFile("templates/auth/oauth/base.patch", []byte(modifyAuthPatch))
+	memfs.AddDir("templates/database")
+	memfs.AddFile("templates/database/base.patch", []byte(newComposePatch+deleteReadmePatch))
+	memfs.AddDir("project")
+	memfs.AddFile("project/README.md", []byte("# Project\n"))
+
+	result, err := DryRun(memfs, "templates", "project", []string{"auth/oauth", "database"}, nil)
+	require.NoError(t, err)
+
+	assert.Equal(t, []string{"auth", "auth/oauth", "database"}, result.WouldApply)
+	require.Len(t, result.Overlaps, 1)
+	assert.Equal(t, OverlapShared, result.Overlaps[0].Kind)
+}
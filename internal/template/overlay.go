@@ -0,0 +1,176 @@
+package template
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"os"
+	"path"
+	"sort"
+
+	"templater/internal/fs"
+)
+
+const (
+	filesDir     = "files"
+	templatesDir = "templates"
+)
+
+type overlayFile struct {
+	Source string
+	Path   string
+	Data   []byte
+	Mode   os.FileMode
+}
+
+func overlayFiles(fileSystem fs.FileSystem, templatePath string, ref FeatureRef, vars map[string]string) ([]overlayFile, error) {
+	root := path.Join(templatePath, ref.Name, filesDir)
+	names, err := walkFiles(fileSystem, root)
+	if err != nil {
+		return nil, err
+	}
+	var files []overlayFile
+	for _, name := range names {
+		source := path.Join(root, name)
+		data, err := fileSystem.ReadFile(source)
+		if err != nil {
+			return nil, err
+		}
+		info, err := fileSystem.Stat(source)
+		if err != nil {
+			return nil, err
+		}
+		files = append(files, overlayFile{Source: source, Path: name, Data: data, Mode: info.Mode().Perm()})
+	}
+
+	rendered, err := renderTemplates(fileSystem, templatePath, ref, vars)
+	if err != nil {
+		return nil, err
+	}
+	for _, r := range rendered {
+		for _, f := range files {
+			if f.Path == r.Path {
+				return nil, fmt.Errorf("%s: %s is also provided by %s", r.Source, r.Path, f.Source)
+			}
+		}
+	}
+	files = append(files, rendered...)
+
+	sort.Slice(files, func(i, j int) bool { return files[i].Path < files[j].Path })
+	return files, nil
+}
+
+func walkFiles(fileSystem fs.FileSystem, root string) ([]string, error) {
+	if _, err := fileSystem.Stat(root); err != nil {
+		return nil, nil
+	}
+
+	var names []string
+	var walk func(rel string) error
+	walk = func(rel string) error {
+		entries, err := fileSystem.ReadDir(path.Join(root, rel))
+		if err != nil {
+			return err
+		}
+		for _, entry := range entries {
+			child := path.Join(rel, entry.Name())
+			if entry.IsDir() {
+				if err := walk(child); err != nil {
+					return err
+				}
+				continue
+			}
+			names = append(names, child)
+		}
+		return nil
+	}
+	if err := walk(""); err != nil {
+		return nil, err
+	}
+	sort.Strings(names)
+	return names, nil
+}
+
+func (a *applier) overlay(ref FeatureRef) ([]overlayFile, error) {
+	if _, baseApplied := a.previous[ref.Name]; baseApplied {
+		return nil, nil
+	}
+	templatePath, local := a.sources.locate(ref)
+	return overlayFiles(a.fileSystem, templatePath, local, a.opts.Vars[ref.Name])
+}
+
+func (a *applier) copyOverlay(ref FeatureRef) ([]FileState, error) {
+	files, err := a.overlay(ref)
+	if err != nil {
+		return nil, err
+	}
+
+	var records []FileState
+	for _, f := range files {
+		data := f.Data
+		targetFile := path.Join(a.targetPath, f.Path)
+		existing, err := a.fileSystem.ReadFile(targetFile)
+		switch {
+		case err == nil && !bytes.Equal(existing, data):
+			return nil, a.abortOverlay(ref, overlayConflict(ref.String(), f))
+		case err == nil:
+			continue
+		case !errors.Is(err, os.ErrNotExist):
+			return nil, a.abortOverlay(ref, err)
+		}
+		if err := a.fileSystem.WriteFile(targetFile, data); err != nil {
+			return nil, a.abortOverlay(ref, err)
+		}
+		a.created[ref.Name] = append(a.created[ref.Name], targetFile)
+		if f.Mode&0o111 != 0 {
+			if err := a.fileSystem.Chmod(targetFile, f.Mode); err != nil {
+				return nil, a.abortOverlay(ref, err)
+			}
+		}
+		records = append(records, FileState{Path: f.Path, Feature: ref.Name, SHA256: checksum(data)})
+	}
+	return records, nil
+}
+
+func (a *applier) abortOverlay(ref FeatureRef, cause error) error {
+	a.removeOverlay(ref)
+	return cause
+}
+
+func (a *applier) removeOverlay(ref FeatureRef) error {
+	created := a.created[ref.Name]
+	for i := len(created) - 1; i >= 0; i-- {
+		if err := a.fileSystem.Remove(created[i]); err != nil && !errors.Is(err, os.ErrNotExist) {
+			return err
+		}
+	}
+	delete(a.created, ref.Name)
+	return nil
+}
+
+func (a *applier) recordState(added State) error {
+	if len(added.Patches) == 0 && len(added.Files) == 0 && len(added.Merges) == 0 && len(added.Injections) == 0 {
+		return nil
+	}
+	state, err := ReadState(a.fileSystem, a.targetPath)
+	if err != nil {
+		return err
+	}
+	state.Patches = append(state.Patches, added.Patches...)
+	for _, f := range added.Files {
+		state.put(f)
+	}
+	state.Merges = append(state.Merges, added.Merges...)
+	state.Injections = append(state.Injections, added.Injections...)
+	return WriteState(a.fileSystem, a.targetPath, state)
+}
+
+func overlayConflict(feature string, f overlayFile) *PatchConflictError {
+	return &PatchConflictError{
+		Feature:   feature,
+		PatchPath: f.Source,
+		File:      f.Path,
+		Reason:    "already exists in working directory",
+		Stderr:    "error: " + f.Path + ": already exists in working directory",
+	}
+}
@@ -0,0 +1,229 @@
+package template
+
+import (
+	"context"
+	"os"
+	"testing"
+
+	"templater/internal/testutil/executor"
+	"templater/internal/testutil/fs"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var logo = []byte{0x89, 'P', 'N', 'G', 0x00, 0x01}
+
+func TestOverlayFiles_WalksTreeInOrder(t *testing.T) {
+	memfs := fs.NewMemoryFS()
+	memfs.AddDir("templates")
+	memfs.AddDir("templates/auth")
+	memfs.AddDir("templates/auth/files")
+	memfs.AddDir("templates/auth/files/assets")
+	memfs.AddFile("templates/auth/files/assets/logo.png", logo)
+	memfs.AddFile("templates/auth/files/LICENSE", []byte("MIT\n"))
+
+	files, err := overlayFiles(memfs, "templates", FeatureRef{Name: "auth"}, nil)
+	require.NoError(t, err)
+
+	assert.Equal(t, []overlayFile{
+		{Source: "templates/auth/files/LICENSE", Path: "LICENSE", Data: []byte("MIT\n")},
+		{Source: "templates/auth/files/assets/logo.png", Path: "assets/logo.png", Data: logo},
+	}, files)
+}
+
+func TestListFeatures_IgnoresFilesDirectory(t *testing.T) {
+	memfs := fs.NewMemoryFS()
+	memfs.AddDir("templates")
+	memfs.AddDir("templates/auth")
+	memfs.AddFile("templates/auth/base.patch", []byte(authPatch))
+	memfs.AddDir("templates/auth/files")
+	memfs.AddDir("templates/auth/files/assets")
+	memfs.AddFile("templates/auth/files/assets/logo.png", logo)
+	memfs.AddFile("templates/auth/files/LICENSE", []byte("MIT\n"))
+
+	features, err := ListFeatures(memfs, "templates")
+	require.NoError(t, err)
+
+	assert.Equal(t, []string{"auth"}, features)
+}
+
+func TestApplyFeatures_CopiesOverlayAndRecordsChecksums(t *testing.T) {
+	memfs := fs.NewMemoryFS()
+	memfs.AddDir("templates")
+	memfs.AddDir("templates/auth")
+	memfs.AddFile("templates/auth/base.patch", []byte(authPatch))
+	memfs.AddDir("templates/auth/files")
+	memfs.AddDir("templates/auth/files/assets")
+	memfs.AddFile("templates/auth/files/assets/logo.png", logo)
+	memfs.AddFile("templates/auth/files/LICENSE", []byte("MIT\n"))
+	memfs.AddDir("project")
+
+	_, err := ApplyFeatures(context.Background(), memfs, &executor.FakeExecutor{}, "templates", "project", []string{"auth"}, ApplyOptions{})
+	require.NoError(t, err)
+
+	data, err := memfs.ReadFile("project/assets/logo.png")
+	require.NoError(t, err)
+	assert.Equal(t, logo, data)
+
+	state, err := ReadState(memfs, "project")
+	require.NoError(t, err)
+	assert.Equal(t, []FileState{
+		{Path: "LICENSE", Feature: "auth", SHA256: checksum([]byte("MIT\n"))},
+		{Path: "assets/logo.png", Feature: "auth", SHA256: checksum(logo)},
+	}, state.Files)
+}
+
+func TestApplyFeatures_OverlayAcceptsIdenticalExistingFile(t *testing.T) {
+	memfs := fs.NewMemoryFS()
+	memfs.AddDir("templates")
+	memfs.AddDir("templates/auth")
+	memfs.AddFile("templates/auth/base.patch", []byte(authPatch))
+	memfs.AddDir("templates/auth/files")
+	memfs.AddDir("templates/auth/files/assets")
+	memfs.AddFile("templates/auth/files/assets/logo.png", logo)
+	memfs.AddFile("templates/auth/files/LICENSE", []byte("MIT\n"))
+	memfs.AddDir("project")
+	memfs.AddFile("project/LICENSE", []byte("MIT\n"))
+
+	_, err := ApplyFeatures(context.Background(), memfs, &executor.FakeExecutor{}, "templates", "project", []string{"auth"}, ApplyOptions{})
+	require.NoError(t, err)
+
+	state, err := ReadState(memfs, "project")
+	require.NoError(t, err)
+	assert.Equal(t, []FileState{{Path: "assets/logo.png", Feature: "auth", SHA256: checksum(logo)}}, state.Files)
+}
+
+func TestApplyFeatures_SharedOverlayFileStaysWithFirstFeature(t *testing.T) {
+	memfs := fs.NewMemoryFS()
+	memfs.AddDir("templates")
+	memfs.AddDir("templates/auth")
+	memfs.AddFile("templates/auth/base.patch", []byte(authPatch))
+	memfs.AddDir("templates/auth/files")
+	memfs.AddDir("templates/auth/files/assets")
+	memfs.AddFile("templates/auth/files/assets/logo.png", logo)
+	memfs.AddFile("templates/auth/files/LICENSE", []byte("MIT\n"))
+	memfs.AddDir("project")
+	memfs.AddDir("templates/billing")
+	memfs.AddDir("templates/billing/files")
+	memfs.AddFile("templates/billing/files/LICENSE", []byte("MIT\n"))
+
+	_, err := ApplyFeatures(context.Background(), memfs, &executor.FakeExecutor{}, "templates", "project", []string{"auth"}, ApplyOptions{})
+	require.NoError(t, err)
+	_, err = ApplyFeatures(context.Background(), memfs, &executor.FakeExecutor{}, "templates", "project", []string{"billing"}, ApplyOptions{})
+	require.NoError(t, err)
+
+	state, err := ReadState(memfs, "project")
+	require.NoError(t, err)
+	assert.Equal(t, FileState{Path: "LICENSE", Feature: "auth", SHA256: checksum([]byte("MIT\n"))}, state.Files[0])
+}
+
+func TestApplyFeatures_OverlayKeepsExecutableMode(t *testing.T) {
+	memfs := fs.NewMemoryFS()
+	memfs.AddDir("templates")
+	memfs.AddDir("templates/auth")
+	memfs.AddFile("templates/auth/base.patch", []byte(authPatch))
+	memfs.AddDir("templates/auth/files")
+	memfs.AddDir("templates/auth/files/assets")
+	memfs.AddFile("templates/auth/files/assets/logo.png", logo)
+	memfs.AddFile("templates/auth/files/LICENSE", []byte("MIT\n"))
+	memfs.AddDir("project")
+	memfs.AddDir("templates/auth/files/bin")
+	memfs.AddFile("templates/auth/files/bin/setup", []byte("#!/bin/sh\n"))
+	require.NoError(t, memfs.Chmod("templates/auth/files/bin/setup", 0o755))
+
+	_, err := ApplyFeatures(context.Background(), memfs, &executor.FakeExecutor{}, "templates", "project", []string{"auth"}, ApplyOptions{})
+	require.NoError(t, err)
+
+	info, err := memfs.Stat("project/bin/setup")
+	require.NoError(t, err)
+	assert.Equal(t, os.FileMode(0o755), info.Mode())
+}
+
+func TestApplyFeatures_OverlayConflictIsReportedBeforeApplying(t *testing.T) {
+	memfs := fs.NewMemoryFS()
+	memfs.AddDir("templates")
+	memfs.AddDir("templates/auth")
+	memfs.AddFile("templates/auth/base.patch", []byte(authPatch))
+	memfs.AddDir("templates/auth/files")
+	memfs.AddDir("templates/auth/files/assets")
+	memfs.AddFile("templates/auth/files/assets/logo.png", logo)
+	memfs.AddFile("templates/auth/files/LICENSE", []byte("MIT\n"))
+	memfs.AddDir("project")
+	memfs.AddFile("project/LICENSE", []byte("Apache-2.0\n"))
+	exec := &executor.FakeExecutor{}
+
+	_, err := ApplyFeatures(context.Background(), memfs, exec, "templates", "project", []string{"auth"}, ApplyOptions{})
+
+	var overlap *OverlapError
+	require.ErrorAs(t, err, &overlap)
+	assert.Equal(t, "LICENSE", overlap.Overlaps[0].Path)
+	assert.Equal(t, "templates/auth/files/LICENSE", overlap.Overlaps[0].PatchPath)
+	assert.Empty(t, exec.Commands)
+}
+
+func TestApplyFeatures_RollbackRemovesOverlayAndRestoresState(t *testing.T) {
+	memfs := fs.NewMemoryFS()
+	memfs.AddDir("templates")
+	memfs.AddDir("templates/auth")
+	memfs.AddFile("templates/auth/base.patch", []byte(authPatch))
+	memfs.AddDir("templates/auth/files")
+	memfs.AddDir("templates/auth/files/assets")
+	memfs.AddFile("templates/auth/files/assets/logo.png", logo)
+	memfs.AddFile("templates/auth/files/LICENSE", []byte("MIT\n"))
+	memfs.AddDir("project")
+	memfs.AddDir("templates/database")
+	memfs.AddFile("templates/database/base.patch", []byte("database"))
+	exec := &executor.FakeExecutor{ExitCodes: map[string]int{applyCommand("project", "templates/database/base.patch"): 1}}
+
+	_, err := ApplyFeatures(context.Background(), memfs, exec, "templates", "project", []string{"auth", "database"}, ApplyOptions{})
+	require.Error(t, err)
+
+	_, err = memfs.ReadFile("project/assets/logo.png")
+	assert.Error(t, err)
+	_, err = memfs.ReadFile(statePath("project"))
+	assert.Error(t, err)
+}
+
+func TestApplyFeatures_RollbackRemovesOverlay(t *testing.T) {
+	memfs := fs.NewMemoryFS()
+	memfs.AddDir("templates")
+	memfs.AddDir("templates/auth")
+	memfs.AddFile("templates/auth/base.patch", []byte(authPatch))
+	memfs.AddDir("templates/auth/files")
+	memfs.AddDir("templates/auth/files/assets")
+	memfs.AddFile("templates/auth/files/assets/logo.png", logo)
+	memfs.AddFile("templates/auth/files/LICENSE", []byte("MIT\n"))
+	memfs.AddDir("project")
+	memfs.AddDir("templates/billing")
+	memfs.AddFile("templates/billing/base.patch", []byte("billing"))
+	exec := &executor.FakeExecutor{ExitCodes: map[string]int{applyCommand("project", "templates/billing/base.patch"): 1}}
+
+	_, err := ApplyFeatures(context.Background(), memfs, exec, "templates", "project", []string{"auth", "billing"}, ApplyOptions{})
+	require.Error(t, err)
+
+	_, err = memfs.ReadFile("project/LICENSE")
+	assert.Error(t, err)
+}
+
+func TestPreviewFeatures_IncludesOverlay(t *testing.T) {
+	memfs := fs.NewMemoryFS()
+	memfs.AddDir("templates")
+	memfs.AddDir("templates/auth")
+	memfs.AddFile("templates/auth/base.patch", []byte(authPatch))
+	memfs.AddDir("templates/auth/files")
+	memfs.AddDir("templates/auth/files/assets")
+	memfs.AddFile("templates/auth/files/assets/logo.png", logo)
+	memfs.AddFile("templates/auth/files/LICENSE", []byte("MIT\n"))
+	memfs.AddDir("project")
+
+	preview, err := PreviewFeatures(memfs, "templates", "project", []string{"auth"}, nil)
+	require.NoError(t, err)
+
+	require.Len(t, preview.Changes, 3)
+	assert.Equal(t, "LICENSE", preview.Changes[0].Path)
+	assert.Equal(t, "MIT\n", preview.Changes[0].New)
+	assert.Equal(t, "assets/logo.png", preview.Changes[1].Path)
+	assert.True(t, preview.Changes[1].Binary)
+	assert.Equal(t, "auth.go", preview.Changes[2].Path)
+}
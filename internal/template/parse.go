@@ -1,25 +1,190 @@
 package template
 
 import (
+	"fmt"
+	"path"
+	"regexp"
 	"strings"
 
 	"templater/internal/fs"
+
+	"gopkg.in/yaml.v3"
 )
 
-func ParseFeaturesFile(fileSystem fs.FileSystem, path string) ([]string, error) {
-	data, err := fileSystem.ReadFile(path)
-	if err != nil {
+type FeatureList struct {
+	Description string
+	Features    []string
+	Vars        map[string]map[string]string
+}
+
+type featuresYml struct {
+	Description string         `yaml:"description"`
+	Include     []string       `yaml:"include"`
+	Features    []featureEntry `yaml:"features"`
+}
+
+type featureEntry struct {
+	Name string            `yaml:"name"`
+	Vars map[string]string `yaml:"vars"`
+}
+
+func (e *featureEntry) UnmarshalYAML(node *yaml.Node) error {
+	if node.Kind == yaml.ScalarNode {
+		return node.Decode(&e.Name)
+	}
+	type plain featureEntry
+	return node.Decode((*plain)(e))
+}
+
+var varNamePattern = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
+
+func ParseFeaturesFile(fileSystem fs.FileSystem, filePath string) (*FeatureList, error) {
+	list := &FeatureList{Vars: make(map[string]map[string]string)}
+	if err := list.parseFile(fileSystem, filePath, nil); err != nil {
 		return nil, err
 	}
+	return list, nil
+}
 
-	lines := strings.Split(string(data), "\n")
-	var features []string
-	for _, line := range lines {
-		trimmed := strings.TrimSpace(line)
-		if trimmed != "" {
-			features = append(features, trimmed)
+func (l *FeatureList) parseFile(fileSystem fs.FileSystem, filePath string, stack []string) error {
+	filePath = path.Clean(filePath)
+	for i, included := range stack {
+		if included == filePath {
+			return fmt.Errorf("include cycle: %s", strings.Join(append(stack[i:], filePath), " -> "))
 		}
 	}
+	stack = append(stack, filePath)
+
+	data, err := fileSystem.ReadFile(filePath)
+	if err != nil {
+		return err
+	}
+
+	if ext := path.Ext(filePath); ext == ".yml" || ext == ".yaml" {
+		return l.parseYAML(fileSystem, filePath, data, stack)
+	}
+	return l.parseText(fileSystem, filePath, data, stack)
+}
+
+// includePath resolves an include relative to the file that names it; an
+// absolute include is used as is.
+func includePath(filePath, include string) string {
+	if path.IsAbs(include) {
+		return include
+	}
+	return path.Join(path.Dir(filePath), include)
+}
+
+func (l *FeatureList) parseText(fileSystem fs.FileSystem, filePath string, data []byte, stack []string) error {
+	for i, line := range strings.Split(string(data), "\n") {
+		fields, err := splitFields(line)
+		if err != nil {
+			return fmt.Errorf("%s:%d: %w", filePath, i+1, err)
+		}
+		if len(fields) == 0 {
+			continue
+		}
 
-	return features, nil
+		if fields[0] == "include" {
+			if len(fields) != 2 {
+				return fmt.Errorf("%s:%d: include expects exactly one path", filePath, i+1)
+			}
+			if err := l.parseFile(fileSystem, includePath(filePath, fields[1]), stack); err != nil {
+				return err
+			}
+			continue
+		}
+
+		vars := make(map[string]string)
+		for _, assignment := range fields[1:] {
+			name, value, ok := strings.Cut(assignment, "=")
+			if !ok {
+				return fmt.Errorf("%s:%d: expected name=value, got %q", filePath, i+1, assignment)
+			}
+			vars[name] = value
+		}
+		if err := l.add(fields[0], vars); err != nil {
+			return fmt.Errorf("%s:%d: %w", filePath, i+1, err)
+		}
+	}
+	return nil
+}
+
+func (l *FeatureList) parseYAML(fileSystem fs.FileSystem, filePath string, data []byte, stack []string) error {
+	var doc featuresYml
+	if err := yaml.Unmarshal(data, &doc); err != nil {
+		return fmt.Errorf("%s: %w", filePath, err)
+	}
+	if len(stack) == 1 {
+		l.Description = doc.Description
+	}
+
+	for _, include := range doc.Include {
+		if err := l.parseFile(fileSystem, includePath(filePath, include), stack); err != nil {
+			return err
+		}
+	}
+	for _, entry := range doc.Features {
+		if err := l.add(entry.Name, entry.Vars); err != nil {
+			return fmt.Errorf("%s: %w", filePath, err)
+		}
+	}
+	return nil
+}
+
+func (l *FeatureList) add(feature string, vars map[string]string) error {
+	ref, err := ParseFeatureRef(feature)
+	if err != nil {
+		return err
+	}
+	if ref.Name == "" {
+		return fmt.Errorf("missing feature name")
+	}
+
+	l.Features = append(l.Features, feature)
+	for name, value := range vars {
+		if !varNamePattern.MatchString(name) {
+			return fmt.Errorf("invalid variable name %q for %s", name, ref.Name)
+		}
+		if l.Vars[ref.Name] == nil {
+			l.Vars[ref.Name] = make(map[string]string)
+		}
+		l.Vars[ref.Name][name] = value
+	}
+	return nil
+}
+
+func splitFields(line string) ([]string, error) {
+	var fields []string
+	var current strings.Builder
+	inField, quoted := false, false
+
+	for _, r := range line {
+		switch {
+		case quoted && r == '"':
+			quoted = false
+		case quoted:
+			current.WriteRune(r)
+		case r == '"':
+			quoted, inField = true, true
+		case r == '#' && !inField:
+			return fields, nil
+		case r == ' ' || r == '\t' || r == '\r':
+			if inField {
+				fields = append(fields, current.String())
+				current.Reset()
+				inField = false
+			}
+		default:
+			current.WriteRune(r)
+			inField = true
+		}
+	}
+	if quoted {
+		return nil, fmt.Errorf("unterminated quote")
+	}
+	if inField {
+		fields = append(fields, current.String())
+	}
+	return fields, nil
 }
@@ -12,28 +12,123 @@ func TestParseFeaturesFile(t *testing.T) {
 	memfs := fs.NewMemoryFS()
 	memfs.AddFile("features.txt", []byte("auth/oauth/google\nauth/oauth/github\ndatabase/migrations\n"))
 
-	features, err := ParseFeaturesFile(memfs, "features.txt")
+	list, err := ParseFeaturesFile(memfs, "features.txt")
 	require.NoError(t, err)
 
-	assert.Equal(t, []string{"auth/oauth/google", "auth/oauth/github", "database/migrations"}, features)
+	assert.Equal(t, []string{"auth/oauth/google", "auth/oauth/github", "database/migrations"}, list.Features)
 }
 
 func TestParseFeaturesFile_IgnoresEmptyLines(t *testing.T) {
 	memfs := fs.NewMemoryFS()
 	memfs.AddFile("features.txt", []byte("auth\n\ndatabase\n\n"))
 
-	features, err := ParseFeaturesFile(memfs, "features.txt")
+	list, err := ParseFeaturesFile(memfs, "features.txt")
 	require.NoError(t, err)
 
-	assert.Equal(t, []string{"auth", "database"}, features)
+	assert.Equal(t, []string{"auth", "database"}, list.Features)
 }
 
 func TestParseFeaturesFile_TrimsWhitespace(t *testing.T) {
 	memfs := fs.NewMemoryFS()
 	memfs.AddFile("features.txt", []byte("  auth  \n  database  \n"))
 
-	features, err := ParseFeaturesFile(memfs, "features.txt")
+	list, err := ParseFeaturesFile(memfs, "features.txt")
 	require.NoError(t, err)
 
-	assert.Equal(t, []string{"auth", "database"}, features)
+	assert.Equal(t, []string{"auth", "database"}, list.Features)
+}
+
+func TestParseFeaturesFile_IgnoresComments(t *testing.T) {
+	memfs := fs.NewMemoryFS()
+	memfs.AddFile("features.txt", []byte("# web stack\nauth # login\n\n  # indented comment\ndatabase\n"))
+
+	list, err := ParseFeaturesFile(memfs, "features.txt")
+	require.NoError(t, err)
+
+	assert.Equal(t, []string{"auth", "database"}, list.Features)
+}
+
+func TestParseFeaturesFile_InlineVariables(t *testing.T) {
+	memfs := fs.NewMemoryFS()
+	memfs.AddFile("features.txt", []byte("auth/oauth provider=github title=\"Sign in\"\nci/github[cache] runner=ubuntu\n"))
+
+	list, err := ParseFeaturesFile(memfs, "features.txt")
+	require.NoError(t, err)
+
+	assert.Equal(t, []string{"auth/oauth", "ci/github[cache]"}, list.Features)
+	assert.Equal(t, map[string]map[string]string{
+		"auth/oauth": {"provider": "github", "title": "Sign in"},
+		"ci/github":  {"runner": "ubuntu"},
+	}, list.Vars)
+}
+
+func TestParseFeaturesFile_RejectsMalformedLines(t *testing.T) {
+	memfs := fs.NewMemoryFS()
+	memfs.AddFile("missing-value.txt", []byte("auth\ndatabase engine\n"))
+	memfs.AddFile("bad-name.txt", []byte("auth 1st=yes\n"))
+	memfs.AddFile("quote.txt", []byte("auth title=\"open\n"))
+
+	_, err := ParseFeaturesFile(memfs, "missing-value.txt")
+	assert.EqualError(t, err, `missing-value.txt:2: expected name=value, got "engine"`)
+	_, err = ParseFeaturesFile(memfs, "bad-name.txt")
+	assert.EqualError(t, err, `bad-name.txt:1: invalid variable name "1st" for auth`)
+	_, err = ParseFeaturesFile(memfs, "quote.txt")
+	assert.EqualError(t, err, "quote.txt:1: unterminated quote")
+}
+
+func TestParseFeaturesFile_IncludesRelativeToFile(t *testing.T) {
+	memfs := fs.NewMemoryFS()
+	memfs.AddFile("stacks/web.txt", []byte("include base.txt\nauth\n"))
+	memfs.AddFile("stacks/base.txt", []byte("logging level=debug\n"))
+	memfs.AddFile("features.txt", []byte("include stacks/web.txt\ndatabase\n"))
+
+	list, err := ParseFeaturesFile(memfs, "features.txt")
+	require.NoError(t, err)
+
+	assert.Equal(t, []string{"logging", "auth", "database"}, list.Features)
+	assert.Equal(t, map[string]map[string]string{"logging": {"level": "debug"}}, list.Vars)
+}
+
+func TestParseFeaturesFile_AbsoluteIncludes(t *testing.T) {
+	memfs := fs.NewMemoryFS()
+	memfs.AddFile("/shared/base.txt", []byte("logging\n"))
+	memfs.AddFile("/shared/ci.yml", []byte("features:\n  - ci/github\n"))
+	memfs.AddFile("project/features.txt", []byte("include /shared/base.txt\nauth\n"))
+	memfs.AddFile("project/features.yml", []byte("include:\n  - /shared/ci.yml\nfeatures:\n  - database\n"))
+
+	list, err := ParseFeaturesFile(memfs, "project/features.txt")
+	require.NoError(t, err)
+	assert.Equal(t, []string{"logging", "auth"}, list.Features)
+
+	list, err = ParseFeaturesFile(memfs, "project/features.yml")
+	require.NoError(t, err)
+	assert.Equal(t, []string{"ci/github", "database"}, list.Features)
+}
+
+func TestParseFeaturesFile_DetectsIncludeCycle(t *testing.T) {
+	memfs := fs.NewMemoryFS()
+	memfs.AddFile("a.txt", []byte("include b.txt\n"))
+	memfs.AddFile("b.txt", []byte("include ./a.txt\n"))
+
+	_, err := ParseFeaturesFile(memfs, "a.txt")
+	assert.EqualError(t, err, "include cycle: a.txt -> b.txt -> a.txt")
+}
+
+func TestParseFeaturesFile_YAML(t *testing.T) {
+	memfs := fs.NewMemoryFS()
+	memfs.AddFile("stacks/base.txt", []byte("logging\n"))
+	memfs.AddFile("features.yml", []byte(`include:
+  - stacks/base.txt
+features:
+  - auth
+  - name: ci/github[cache]
+    vars:
+      runner: ubuntu
+`))
+
+	list, err := ParseFeaturesFile(memfs, "features.yml")
+	require.NoError(t, err)
+
+	assert.Equal(t, []string{"logging", "auth", "ci/github[cache]"}, list.Features)
+	assert.Equal(t, map[string]map[string]string{"ci/github": {"runner": "ubuntu"}}, list.Vars)
 }
@@ -0,0 +1,195 @@
+package template
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path"
+	"regexp"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+
+	"templater/internal/fs"
+	"templater/internal/patch"
+)
+
+const (
+	seriesDir   = "patches"
+	variantsDir = "variants"
+)
+
+var reservedDirs = map[string]bool{
+	filesDir:     true,
+	"hooks":      true,
+	mergeDir:     true,
+	seriesDir:    true,
+	templatesDir: true,
+	variantsDir:  true,
+}
+
+func featurePatches(fileSystem fs.FileSystem, templatePath, feature string) ([]string, error) {
+	var patches []string
+
+	basePatch := path.Join(templatePath, feature, "base.patch")
+	if _, err := fileSystem.Stat(basePatch); err == nil {
+		patches = append(patches, basePatch)
+	}
+
+	series, err := seriesPatches(fileSystem, path.Join(templatePath, feature, seriesDir))
+	if err != nil {
+		return nil, err
+	}
+	return append(patches, series...), nil
+}
+
+func seriesPatches(fileSystem fs.FileSystem, dir string) ([]string, error) {
+	entries, err := fileSystem.ReadDir(dir)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var names []string
+	for _, entry := range entries {
+		if !entry.IsDir() && strings.HasSuffix(entry.Name(), ".patch") {
+			names = append(names, entry.Name())
+		}
+	}
+	sort.Strings(names)
+
+	patches := make([]string, len(names))
+	for i, name := range names {
+		patches[i] = path.Join(dir, name)
+	}
+	return patches, nil
+}
+
+func featureVariants(fileSystem fs.FileSystem, templatePath, feature string) ([]string, error) {
+	patches, err := seriesPatches(fileSystem, path.Join(templatePath, feature, variantsDir))
+	if err != nil {
+		return nil, err
+	}
+
+	variants := make([]string, len(patches))
+	for i, patchPath := range patches {
+		variants[i] = strings.TrimSuffix(path.Base(patchPath), ".patch")
+	}
+	return variants, nil
+}
+
+func variantPatch(templatePath, feature, variant string) string {
+	return path.Join(templatePath, feature, variantsDir, variant+".patch")
+}
+
+func checkVariants(fileSystem fs.FileSystem, sources Sources, ref FeatureRef) error {
+	if len(ref.Variants) == 0 {
+		return nil
+	}
+	templatePath, local := sources.locate(ref)
+	available, err := featureVariants(fileSystem, templatePath, local.Name)
+	if err != nil {
+		return err
+	}
+	for _, variant := range ref.Variants {
+		if !slices.Contains(available, variant) {
+			return &VariantNotFoundError{Feature: ref.Name, Variant: variant, Available: available}
+		}
+	}
+	return nil
+}
+
+func refPatches(fileSystem fs.FileSystem, templatePath string, ref FeatureRef, baseApplied bool) ([]string, error) {
+	var patches []string
+	if !baseApplied {
+		var err error
+		if patches, err = featurePatches(fileSystem, templatePath, ref.Name); err != nil {
+			return nil, err
+		}
+	}
+	for _, variant := range ref.Variants {
+		patches = append(patches, variantPatch(templatePath, ref.Name, variant))
+	}
+	return patches, nil
+}
+
+func readFeaturePatch(fileSystem fs.FileSystem, templatePath, feature string) ([]patch.File, error) {
+	patches, err := featurePatches(fileSystem, templatePath, feature)
+	if err != nil {
+		return nil, err
+	}
+
+	var files []patch.File
+	for _, patchPath := range patches {
+		data, err := fileSystem.ReadFile(patchPath)
+		if err != nil {
+			return nil, err
+		}
+		parsed, err := patch.Parse(data)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", patchPath, err)
+		}
+		files = append(files, parsed...)
+	}
+	return files, nil
+}
+
+func featureHooks(fileSystem fs.FileSystem, templatePath, feature string) ([]string, error) {
+	entries, err := fileSystem.ReadDir(path.Join(templatePath, feature, "hooks"))
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var hooks []string
+	for _, entry := range entries {
+		if !entry.IsDir() {
+			hooks = append(hooks, entry.Name())
+		}
+	}
+	return hooks, nil
+}
+
+var (
+	seriesNumberPattern = regexp.MustCompile(`^(\d+)-`)
+	slugPattern         = regexp.MustCompile(`[^a-z0-9]+`)
+)
+
+func SplitPatch(fileSystem fs.FileSystem, featureDir string, data []byte) ([]string, error) {
+	sections, err := patch.Split(data)
+	if err != nil {
+		return nil, err
+	}
+	if len(sections) == 0 {
+		return nil, fmt.Errorf("no file diffs found to split")
+	}
+
+	dir := path.Join(featureDir, seriesDir)
+	existing, err := seriesPatches(fileSystem, dir)
+	if err != nil {
+		return nil, err
+	}
+	next := 1
+	for _, patchPath := range existing {
+		if m := seriesNumberPattern.FindStringSubmatch(path.Base(patchPath)); m != nil {
+			n, _ := strconv.Atoi(m[1])
+			next = max(next, n+1)
+		}
+	}
+
+	var written []string
+	for i, section := range sections {
+		slug := strings.Trim(slugPattern.ReplaceAllString(strings.ToLower(section.Path), "-"), "-")
+		patchPath := path.Join(dir, fmt.Sprintf("%04d-%s.patch", next+i, slug))
+		if err := fileSystem.WriteFile(patchPath, section.Data); err != nil {
+			return written, err
+		}
+		written = append(written, patchPath)
+	}
+	return written, nil
+}
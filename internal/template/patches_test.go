@@ -0,0 +1,167 @@
+package template
+
+import (
+	"context"
+	"testing"
+
+	"templater/internal/testutil/executor"
+	"templater/internal/testutil/fs"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestFeaturePatches_BaseThenSeriesInOrder(t *testing.T) {
+	memfs := fs.NewMemoryFS()
+	memfs.AddDir("templates")
+	memfs.AddDir("templates/auth")
+	memfs.AddDir("templates/auth/patches")
+	memfs.AddDir("templates/auth/hooks")
+	memfs.AddFile("templates/auth/base.patch", []byte("base"))
+	memfs.AddFile("templates/auth/patches/0002-routes.patch", []byte("routes"))
+	memfs.AddFile("templates/auth/patches/0001-models.patch", []byte("models"))
+	memfs.AddFile("templates/auth/patches/README", []byte("notes"))
+	memfs.AddDir("project")
+
+	patches, err := featurePatches(memfs, "templates", "auth")
+	require.NoError(t, err)
+
+	assert.Equal(t, []string{
+		"templates/auth/base.patch",
+		"templates/auth/patches/0001-models.patch",
+		"templates/auth/patches/0002-routes.patch",
+	}, patches)
+}
+
+func TestListFeatures_SeriesOnlyFeatureAndReservedDirs(t *testing.T) {
+	memfs := fs.NewMemoryFS()
+	memfs.AddDir("templates")
+	memfs.AddDir("templates/auth")
+	memfs.AddDir("templates/auth/patches")
+	memfs.AddDir("templates/auth/hooks")
+	memfs.AddFile("templates/auth/base.patch", []byte("base"))
+	memfs.AddFile("templates/auth/patches/0002-routes.patch", []byte("routes"))
+	memfs.AddFile("templates/auth/patches/0001-models.patch", []byte("models"))
+	memfs.AddFile("templates/auth/patches/README", []byte("notes"))
+	memfs.AddDir("project")
+	memfs.AddDir("templates/billing")
+	memfs.AddDir("templates/billing/patches")
+	memfs.AddFile("templates/billing/patches/0001-invoices.patch", []byte("invoices"))
+	memfs.AddFile("templates/auth/hooks/base.patch", []byte("not a feature"))
+
+	features, err := ListFeatures(memfs, "templates")
+	require.NoError(t, err)
+
+	assert.Equal(t, []string{"auth", "billing"}, features)
+}
+
+func TestApplyFeature_AppliesSeriesInOrder(t *testing.T) {
+	memfs := fs.NewMemoryFS()
+	memfs.AddDir("templates")
+	memfs.AddDir("templates/auth")
+	memfs.AddDir("templates/auth/patches")
+	memfs.AddDir("templates/auth/hooks")
+	memfs.AddFile("templates/auth/base.patch", []byte("base"))
+	memfs.AddFile("templates/auth/patches/0002-routes.patch", []byte("routes"))
+	memfs.AddFile("templates/auth/patches/0001-models.patch", []byte("models"))
+	memfs.AddFile("templates/auth/patches/README", []byte("notes"))
+	memfs.AddDir("project")
+
+	exec := &executor.FakeExecutor{}
+
+	err := ApplyFeature(context.Background(), memfs, exec, "templates", "project", "auth")
+	require.NoError(t, err)
+
+	require.Len(t, exec.Commands, 3)
+	assert.Equal(t, applyCommand("project", "templates/auth/base.patch"), exec.Commands[0].Command)
+	assert.Equal(t, applyCommand("project", "templates/auth/patches/0001-models.patch"), exec.Commands[1].Command)
+	assert.Equal(t, applyCommand("project", "templates/auth/patches/0002-routes.patch"), exec.Commands[2].Command)
+}
+
+func TestApplyFeature_ReversesOnlyAppliedPatchesOfSeries(t *testing.T) {
+	memfs := fs.NewMemoryFS()
+	memfs.AddDir("templates")
+	memfs.AddDir("templates/auth")
+	memfs.AddDir("templates/auth/patches")
+	memfs.AddDir("templates/auth/hooks")
+	memfs.AddFile("templates/auth/base.patch", []byte("base"))
+	memfs.AddFile("templates/auth/patches/0002-routes.patch", []byte("routes"))
+	memfs.AddFile("templates/auth/patches/0001-models.patch", []byte("models"))
+	memfs.AddFile("templates/auth/patches/README", []byte("notes"))
+	memfs.AddDir("project")
+
+	exec := &executor.FakeExecutor{
+		ExitCodes: map[string]int{
+			applyCommand("project", "templates/auth/patches/0002-routes.patch"): 1,
+		},
+		Stderr: "error: patch failed: routes.go:1",
+	}
+
+	err := ApplyFeature(context.Background(), memfs, exec, "templates", "project", "auth")
+
+	var conflict *PatchConflictError
+	require.ErrorAs(t, err, &conflict)
+	assert.Equal(t, "templates/auth/patches/0002-routes.patch", conflict.PatchPath)
+
+	var commands []string
+	for _, c := range exec.Commands {
+		commands = append(commands, c.Command)
+	}
+	assert.Equal(t, []string{
+		applyCommand("project", "templates/auth/base.patch"),
+		applyCommand("project", "templates/auth/patches/0001-models.patch"),
+		applyCommand("project", "templates/auth/patches/0002-routes.patch"),
+		reverseCommand("project", "templates/auth/patches/0001-models.patch"),
+		reverseCommand("project", "templates/auth/base.patch"),
+	}, commands)
+}
+
+func TestApplyFeatures_RollbackReversesWholeSeries(t *testing.T) {
+	memfs := fs.NewMemoryFS()
+	memfs.AddDir("templates")
+	memfs.AddDir("templates/auth")
+	memfs.AddDir("templates/auth/patches")
+	memfs.AddDir("templates/auth/hooks")
+	memfs.AddFile("templates/auth/base.patch", []byte("base"))
+	memfs.AddFile("templates/auth/patches/0002-routes.patch", []byte("routes"))
+	memfs.AddFile("templates/auth/patches/0001-models.patch", []byte("models"))
+	memfs.AddFile("templates/auth/patches/README", []byte("notes"))
+	memfs.AddDir("project")
+	memfs.AddDir("templates/auth/oauth")
+	memfs.AddFile("templates/auth/oauth/base.patch", []byte("oauth"))
+	exec := &executor.FakeExecutor{
+		ExitCodes: map[string]int{
+			applyCommand("project", "templates/auth/oauth/base.patch"): 1,
+		},
+	}
+
+	_, err := ApplyFeatures(context.Background(), memfs, exec, "templates", "project", []string{"auth/oauth"}, ApplyOptions{})
+	require.Error(t, err)
+
+	n := len(exec.Commands)
+	assert.Equal(t, reverseCommand("project", "templates/auth/patches/0002-routes.patch"), exec.Commands[n-3].Command)
+	assert.Equal(t, reverseCommand("project", "templates/auth/patches/0001-models.patch"), exec.Commands[n-2].Command)
+	assert.Equal(t, reverseCommand("project", "templates/auth/base.patch"), exec.Commands[n-1].Command)
+}
+
+func TestSplitPatch_WritesNumberedSeries(t *testing.T) {
+	memfs := fs.NewMemoryFS()
+	memfs.AddDir("templates/auth/patches")
+	memfs.AddFile("templates/auth/patches/0003-existing.patch", []byte("existing"))
+
+	written, err := SplitPatch(memfs, "templates/auth", []byte(newAuthFilePatch+conflictingPatch))
+	require.NoError(t, err)
+
+	assert.Equal(t, []string{
+		"templates/auth/patches/0004-auth-txt.patch",
+		"templates/auth/patches/0005-file-txt.patch",
+	}, written)
+	data, err := memfs.ReadFile("templates/auth/patches/0005-file-txt.patch")
+	require.NoError(t, err)
+	assert.Equal(t, conflictingPatch, string(data))
+}
+
+func TestSplitPatch_RejectsInputWithoutDiffs(t *testing.T) {
+	_, err := SplitPatch(fs.NewMemoryFS(), "templates/auth", []byte("patch content"))
+	assert.EqualError(t, err, "no file diffs found to split")
+}
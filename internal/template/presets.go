@@ -0,0 +1,77 @@
+package template
+
+import (
+	"errors"
+	"os"
+	"path"
+	"sort"
+	"strings"
+
+	"templater/internal/fs"
+)
+
+const presetsDir = "presets"
+
+var presetExtensions = []string{".yml", ".yaml", ".txt"}
+
+type Preset struct {
+	Name        string
+	Description string
+	Features    []string
+}
+
+func ListPresets(fileSystem fs.FileSystem, templatePath string) ([]Preset, error) {
+	entries, err := fileSystem.ReadDir(path.Join(templatePath, presetsDir))
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var presets []Preset
+	for _, entry := range entries {
+		name, ok := presetName(entry.Name())
+		if entry.IsDir() || !ok {
+			continue
+		}
+		list, err := ParseFeaturesFile(fileSystem, path.Join(templatePath, presetsDir, entry.Name()))
+		if err != nil {
+			return nil, err
+		}
+		presets = append(presets, Preset{Name: name, Description: list.Description, Features: list.Features})
+	}
+	sort.Slice(presets, func(i, j int) bool { return presets[i].Name < presets[j].Name })
+	return presets, nil
+}
+
+func LoadPreset(fileSystem fs.FileSystem, templatePath, name string) (*FeatureList, error) {
+	for _, ext := range presetExtensions {
+		if strings.Contains(name, "/") || strings.HasPrefix(name, ".") {
+			break
+		}
+		presetPath := path.Join(templatePath, presetsDir, name+ext)
+		if _, err := fileSystem.Stat(presetPath); err == nil {
+			return ParseFeaturesFile(fileSystem, presetPath)
+		}
+	}
+
+	presets, err := ListPresets(fileSystem, templatePath)
+	if err != nil {
+		return nil, err
+	}
+	notFound := &PresetNotFoundError{Name: name}
+	for _, preset := range presets {
+		notFound.Available = append(notFound.Available, preset.Name)
+	}
+	return nil, notFound
+}
+
+func presetName(fileName string) (string, bool) {
+	for _, ext := range presetExtensions {
+		if name, ok := strings.CutSuffix(fileName, ext); ok && name != "" {
+			return name, true
+		}
+	}
+	return "", false
+}
@@ -0,0 +1,109 @@
+package template
+
+import (
+	"testing"
+
+	"templater/internal/testutil/fs"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestListPresets(t *testing.T) {
+	memfs := fs.NewMemoryFS()
+	memfs.AddDir("templates")
+	memfs.AddDir("templates/presets")
+	memfs.AddFile("templates/presets/web-service.yml", []byte(`description: Standard HTTP service
+include:
+  - common.txt
+features:
+  - name: ci/github[cache]
+    vars:
+      runner: ubuntu
+`))
+	memfs.AddFile("templates/presets/common.txt", []byte("auth\nlogging\n"))
+	memfs.AddFile("templates/presets/README.md", []byte("not a preset"))
+
+	presets, err := ListPresets(memfs, "templates")
+	require.NoError(t, err)
+
+	assert.Equal(t, []Preset{
+		{Name: "common", Features: []string{"auth", "logging"}},
+		{Name: "web-service", Description: "Standard HTTP service", Features: []string{"auth", "logging", "ci/github[cache]"}},
+	}, presets)
+}
+
+func TestListPresets_NoPresetsDir(t *testing.T) {
+	memfs := fs.NewMemoryFS()
+	memfs.AddDir("templates")
+
+	presets, err := ListPresets(memfs, "templates")
+	require.NoError(t, err)
+	assert.Empty(t, presets)
+}
+
+func TestLoadPreset(t *testing.T) {
+	memfs := fs.NewMemoryFS()
+	memfs.AddDir("templates")
+	memfs.AddDir("templates/presets")
+	memfs.AddFile("templates/presets/web-service.yml", []byte(`description: Standard HTTP service
+include:
+  - common.txt
+features:
+  - name: ci/github[cache]
+    vars:
+      runner: ubuntu
+`))
+	memfs.AddFile("templates/presets/common.txt", []byte("auth\nlogging\n"))
+	memfs.AddFile("templates/presets/README.md", []byte("not a preset"))
+
+	list, err := LoadPreset(memfs, "templates", "web-service")
+	require.NoError(t, err)
+
+	assert.Equal(t, []string{"auth", "logging", "ci/github[cache]"}, list.Features)
+	assert.Equal(t, map[string]map[string]string{"ci/github": {"runner": "ubuntu"}}, list.Vars)
+}
+
+func TestLoadPreset_NotFound(t *testing.T) {
+	memfs := fs.NewMemoryFS()
+	memfs.AddDir("templates")
+	memfs.AddDir("templates/presets")
+	memfs.AddFile("templates/presets/web-service.yml", []byte(`description: Standard HTTP service
+include:
+  - common.txt
+features:
+  - name: ci/github[cache]
+    vars:
+      runner: ubuntu
+`))
+	memfs.AddFile("templates/presets/common.txt", []byte("auth\nlogging\n"))
+	memfs.AddFile("templates/presets/README.md", []byte("not a preset"))
+
+	_, err := LoadPreset(memfs, "templates", "worker")
+
+	var notFound *PresetNotFoundError
+	require.ErrorAs(t, err, &notFound)
+	assert.EqualError(t, err, "preset not found: worker (available: common, web-service)")
+}
+
+func TestLoadPreset_RejectsPathsOutsidePresets(t *testing.T) {
+	memfs := fs.NewMemoryFS()
+	memfs.AddDir("templates")
+	memfs.AddDir("templates/presets")
+	memfs.AddFile("templates/presets/web-service.yml", []byte(`description: Standard HTTP service
+include:
+  - common.txt
+features:
+  - name: ci/github[cache]
+    vars:
+      runner: ubuntu
+`))
+	memfs.AddFile("templates/presets/common.txt", []byte("auth\nlogging\n"))
+	memfs.AddFile("templates/presets/README.md", []byte("not a preset"))
+	memfs.AddFile("templates/secret.txt", []byte("auth\n"))
+
+	_, err := LoadPreset(memfs, "templates", "../secret")
+
+	var notFound *PresetNotFoundError
+	assert.ErrorAs(t, err, &notFound)
+}
@@ -0,0 +1,262 @@
+package template
+
+import (
+	"bytes"
+	"errors"
+	"os"
+	"path"
+	"sort"
+	"unicode/utf8"
+
+	"templater/internal/fs"
+	"templater/internal/patch"
+)
+
+type FileChange struct {
+	Path      string
+	Old       string
+	New       string
+	OldExists bool
+	NewExists bool
+	Binary    bool
+}
+
+func (c FileChange) IsAdded() bool {
+	return !c.OldExists && c.NewExists
+}
+
+func (c FileChange) IsDeleted() bool {
+	return c.OldExists && !c.NewExists
+}
+
+type Preview struct {
+	Features []string
+	Changes  []FileChange
+}
+
+func PreviewFeatures(fileSystem fs.FileSystem, templatePath, targetPath string, features []string, vars map[string]map[string]string) (*Preview, error) {
+	resolved, err := resolveFeatures(fileSystem, templatePath, targetPath, features, vars)
+	if err != nil {
+		return nil, err
+	}
+	return preview(fileSystem, targetPath, resolved, vars)
+}
+
+func preview(fileSystem fs.FileSystem, targetPath string, resolved *resolvedFeatures, vars map[string]map[string]string) (*Preview, error) {
+	target := newVirtualTarget(fileSystem, targetPath)
+	for _, feature := range resolved.toApply {
+		ref, err := ParseFeatureRef(feature)
+		if err != nil {
+			return nil, err
+		}
+		_, baseApplied := resolved.previous[ref.Name]
+		templatePath, local := resolved.sources.locate(ref)
+		patches, err := refPatches(fileSystem, templatePath, local, baseApplied)
+		if err != nil {
+			return nil, err
+		}
+		for _, patchPath := range patches {
+			if err := target.applyPatch(feature, patchPath); err != nil {
+				return nil, err
+			}
+		}
+		if baseApplied {
+			continue
+		}
+		files, err := overlayFiles(fileSystem, templatePath, local, vars[ref.Name])
+		if err != nil {
+			return nil, err
+		}
+		for _, f := range files {
+			if err := target.addFile(feature, f); err != nil {
+				return nil, err
+			}
+		}
+		fragments, err := mergeFragments(fileSystem, templatePath, local.Name)
+		if err != nil {
+			return nil, err
+		}
+		for _, fragment := range fragments {
+			if err := target.merge(feature, fragment); err != nil {
+				return nil, err
+			}
+		}
+		manifest, err := ReadManifest(fileSystem, templatePath, local.Name)
+		if err != nil {
+			return nil, err
+		}
+		for _, inj := range manifest.Inject {
+			if err := target.inject(feature, manifestPath(templatePath, local.Name), ref.Name, inj); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	return &Preview{
+		Features: resolved.toApply,
+		Changes:  target.changes(),
+	}, nil
+}
+
+type virtualTarget struct {
+	fileSystem fs.FileSystem
+	root       string
+	files      map[string]*virtualFile
+}
+
+type virtualFile struct {
+	original       string
+	originalExists bool
+	content        string
+	exists         bool
+	binary         bool
+}
+
+func newVirtualTarget(fileSystem fs.FileSystem, root string) *virtualTarget {
+	return &virtualTarget{
+		fileSystem: fileSystem,
+		root:       root,
+		files:      make(map[string]*virtualFile),
+	}
+}
+
+func (v *virtualTarget) file(name string) (*virtualFile, error) {
+	if f, ok := v.files[name]; ok {
+		return f, nil
+	}
+
+	f := &virtualFile{}
+	data, err := v.fileSystem.ReadFile(path.Join(v.root, name))
+	switch {
+	case err == nil:
+		f.original, f.originalExists = string(data), true
+	case !errors.Is(err, os.ErrNotExist):
+		return nil, err
+	}
+	f.content, f.exists = f.original, f.originalExists
+	v.files[name] = f
+	return f, nil
+}
+
+func (v *virtualTarget) applyPatch(feature, patchPath string) error {
+	data, err := v.fileSystem.ReadFile(patchPath)
+	if err != nil {
+		return err
+	}
+	files, err := patch.Parse(data)
+	if err != nil {
+		return err
+	}
+
+	for _, diff := range files {
+		f, err := v.file(diff.Path())
+		if err != nil {
+			return err
+		}
+		if diff.IsBinary {
+			f.binary, f.exists = true, !diff.IsDeleted
+			continue
+		}
+
+		content, err := patch.Apply(f.content, f.exists, diff)
+		if err != nil {
+			return v.conflict(feature, patchPath, err)
+		}
+		f.content, f.exists = content, !diff.IsDeleted
+	}
+	return nil
+}
+
+func (v *virtualTarget) addFile(feature string, overlay overlayFile) error {
+	data := overlay.Data
+	f, err := v.file(overlay.Path)
+	if err != nil {
+		return err
+	}
+	if f.exists && f.content != string(data) {
+		return overlayConflict(feature, overlay)
+	}
+	f.content, f.exists = string(data), true
+	f.binary = f.binary || !utf8.Valid(data) || bytes.IndexByte(data, 0) >= 0
+	return nil
+}
+
+func (v *virtualTarget) merge(feature string, fragment mergeFragment) error {
+	f, err := v.file(fragment.Path)
+	if err != nil {
+		return err
+	}
+	var existing []byte
+	if f.exists {
+		existing = []byte(f.content)
+	}
+	merged, _, conflicts, err := mergeFile(v.fileSystem, fragment, existing)
+	if err != nil {
+		return err
+	}
+	if len(conflicts) > 0 {
+		return &OverlapError{Overlaps: mergeOverlaps(feature, fragment, conflicts)}
+	}
+	f.content, f.exists = string(merged), true
+	return nil
+}
+
+func (v *virtualTarget) inject(feature, source, name string, inj Injection) error {
+	f, err := v.file(inj.File)
+	if err != nil {
+		return err
+	}
+	if !f.exists {
+		return injectionConflict(feature, source, inj, errors.New("No such file or directory"))
+	}
+	injected, _, err := injectSnippet(f.content, inj, name)
+	if err != nil {
+		return injectionConflict(feature, source, inj, err)
+	}
+	f.content = injected
+	return nil
+}
+
+func (v *virtualTarget) conflict(feature, patchPath string, err error) error {
+	var hunkErr *patch.HunkError
+	if !errors.As(err, &hunkErr) {
+		return err
+	}
+
+	conflict := &PatchConflictError{
+		Feature:   feature,
+		PatchPath: patchPath,
+		File:      hunkErr.File,
+		Line:      hunkErr.Line,
+		Reason:    hunkErr.Reason,
+		Stderr:    "error: " + hunkErr.Error(),
+	}
+	if len(hunkErr.Hunk.Lines) > 0 {
+		conflict.Hunk = &hunkErr.Hunk
+		if f, ok := v.files[hunkErr.File]; ok && hunkErr.Line > 0 {
+			lines, _ := patch.SplitLines(f.content)
+			from := min(hunkErr.Line-1, len(lines))
+			conflict.Found = lines[from:min(from+hunkErr.Hunk.OldLines, len(lines))]
+		}
+	}
+	return conflict
+}
+
+func (v *virtualTarget) changes() []FileChange {
+	var changes []FileChange
+	for name, f := range v.files {
+		if f.exists == f.originalExists && f.content == f.original && !f.binary {
+			continue
+		}
+		changes = append(changes, FileChange{
+			Path:      name,
+			Old:       f.original,
+			New:       f.content,
+			OldExists: f.originalExists,
+			NewExists: f.exists,
+			Binary:    f.binary,
+		})
+	}
+	sort.Slice(changes, func(i, j int) bool { return changes[i].Path < changes[j].Path })
+	return changes
+}
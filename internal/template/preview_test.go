@@ -0,0 +1,85 @@
+package template
+
+import (
+	"testing"
+
+	"templater/internal/testutil/fs"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPreviewFeatures_CombinesDependencyChain(t *testing.T) {
+	memfs := fs.NewMemoryFS()
+	memfs.AddDir("templates")
+	memfs.AddDir("templates/auth")
+	memfs.AddDir("templates/auth/oauth")
+	memfs.AddFile("templates/auth/base.patch", []byte(newAuthFilePatch))
+	memfs.AddFile("templates/auth/oauth/base.patch", []byte(modifyAuthFilePatch))
+	memfs.AddDir("project")
+
+	preview, err := PreviewFeatures(memfs, "templates", "project", []string{"auth/oauth"}, nil)
+	require.NoError(t, err)
+
+	assert.Equal(t, []string{"auth", "auth/oauth"}, preview.Features)
+	assert.Equal(t, []FileChange{
+		{Path: "auth.txt", New: "auth feature\noauth provider\n", NewExists: true},
+	}, preview.Changes)
+	_, err = memfs.ReadFile("project/auth.txt")
+	assert.Error(t, err, "preview must not touch the target")
+}
+
+func TestPreviewFeatures_ModifiesExistingFile(t *testing.T) {
+	memfs := fs.NewMemoryFS()
+	memfs.AddDir("templates")
+	memfs.AddDir("templates/auth")
+	memfs.AddFile("templates/auth/base.patch", []byte(conflictingPatch))
+	memfs.AddDir("project")
+	memfs.AddFile("project/file.txt", []byte("a\nx\nc\n"))
+
+	preview, err := PreviewFeatures(memfs, "templates", "project", []string{"auth"}, nil)
+	require.NoError(t, err)
+
+	require.Len(t, preview.Changes, 1)
+	change := preview.Changes[0]
+	assert.Equal(t, "a\nx\nc\n", change.Old)
+	assert.Equal(t, "a\ny\nc\n", change.New)
+	assert.False(t, change.IsAdded())
+}
+
+func TestPreviewFeatures_ReportsConflict(t *testing.T) {
+	memfs := fs.NewMemoryFS()
+	memfs.AddDir("templates")
+	memfs.AddDir("templates/auth")
+	memfs.AddFile("templates/auth/base.patch", []byte(conflictingPatch))
+	memfs.AddDir("project")
+	memfs.AddFile("project/file.txt", []byte("a\nb\nc\n"))
+
+	_, err := PreviewFeatures(memfs, "templates", "project", []string{"auth"}, nil)
+
+	var conflict *PatchConflictError
+	require.ErrorAs(t, err, &conflict)
+	assert.Equal(t, "file.txt", conflict.File)
+	assert.Equal(t, "@@ -1,3 +1,3 @@", conflict.Hunk.Header)
+	assert.Equal(t, []string{"a", "b", "c"}, conflict.Found)
+	assert.Equal(t, "failed to apply auth: error: file.txt:1: patch does not apply", conflict.Error())
+}
+
+func TestPreviewFeatures_SkipsAlreadyApplied(t *testing.T) {
+	memfs := fs.NewMemoryFS()
+	memfs.AddDir("templates")
+	memfs.AddDir("templates/auth")
+	memfs.AddDir("templates/auth/oauth")
+	memfs.AddFile("templates/auth/base.patch", []byte(newAuthFilePatch))
+	memfs.AddFile("templates/auth/oauth/base.patch", []byte(modifyAuthFilePatch))
+	memfs.AddDir("project")
+	memfs.AddFile("project/auth.txt", []byte("auth feature\n"))
+	memfs.AddFile("project/.templater/applied.yml", []byte("applied:\n  - auth\n"))
+
+	preview, err := PreviewFeatures(memfs, "templates", "project", []string{"auth/oauth"}, nil)
+	require.NoError(t, err)
+
+	assert.Equal(t, []string{"auth/oauth"}, preview.Features)
+	require.Len(t, preview.Changes, 1)
+	assert.Equal(t, "auth feature\noauth provider\n", preview.Changes[0].New)
+}
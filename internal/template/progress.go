@@ -0,0 +1,44 @@
+package template
+
+import "time"
+
+type FeatureState int
+
+const (
+	FeaturePending FeatureState = iota
+	FeatureRunning
+	FeatureDone
+	FeatureFailed
+	FeatureRolledBack
+)
+
+func (s FeatureState) String() string {
+	switch s {
+	case FeaturePending:
+		return "pending"
+	case FeatureRunning:
+		return "running"
+	case FeatureDone:
+		return "done"
+	case FeatureFailed:
+		return "failed"
+	case FeatureRolledBack:
+		return "rolled back"
+	}
+	return "unknown"
+}
+
+type ProgressEvent struct {
+	Feature string
+	State   FeatureState
+	Elapsed time.Duration
+	Output  string
+}
+
+type ProgressFunc func(event ProgressEvent)
+
+func (o ApplyOptions) report(event ProgressEvent) {
+	if o.Progress != nil {
+		o.Progress(event)
+	}
+}
@@ -0,0 +1,81 @@
+package template
+
+import (
+	"fmt"
+	"regexp"
+	"slices"
+	"strings"
+)
+
+type FeatureRef struct {
+	Name     string
+	Variants []string
+}
+
+var variantNamePattern = regexp.MustCompile(`^[A-Za-z0-9][A-Za-z0-9_.-]*$`)
+
+func ParseFeatureRef(ref string) (FeatureRef, error) {
+	if source, name, ok := strings.Cut(ref, ":"); ok && (!variantNamePattern.MatchString(source) || strings.Contains(name, ":")) {
+		return FeatureRef{}, fmt.Errorf("invalid feature reference %q: expected source:feature", ref)
+	}
+	open := strings.IndexByte(ref, '[')
+	if open < 0 {
+		if strings.ContainsRune(ref, ']') {
+			return FeatureRef{}, fmt.Errorf("invalid feature reference %q: unbalanced brackets", ref)
+		}
+		return FeatureRef{Name: ref}, nil
+	}
+	if !strings.HasSuffix(ref, "]") || strings.Count(ref, "[") != 1 || strings.Count(ref, "]") != 1 {
+		return FeatureRef{}, fmt.Errorf("invalid feature reference %q: expected feature[variant,...]", ref)
+	}
+
+	parsed := FeatureRef{Name: ref[:open]}
+	for _, variant := range strings.Split(ref[open+1:len(ref)-1], ",") {
+		variant = strings.TrimSpace(variant)
+		if !variantNamePattern.MatchString(variant) {
+			return FeatureRef{}, fmt.Errorf("invalid feature reference %q: bad variant name %q", ref, variant)
+		}
+		if !slices.Contains(parsed.Variants, variant) {
+			parsed.Variants = append(parsed.Variants, variant)
+		}
+	}
+	return parsed, nil
+}
+
+func (r FeatureRef) String() string {
+	if len(r.Variants) == 0 {
+		return r.Name
+	}
+	return r.Name + "[" + strings.Join(r.Variants, ",") + "]"
+}
+
+func (r FeatureRef) with(other FeatureRef) FeatureRef {
+	merged := FeatureRef{Name: r.Name, Variants: slices.Clone(r.Variants)}
+	for _, variant := range other.Variants {
+		if !slices.Contains(merged.Variants, variant) {
+			merged.Variants = append(merged.Variants, variant)
+		}
+	}
+	return merged
+}
+
+func (r FeatureRef) without(other FeatureRef) FeatureRef {
+	remaining := FeatureRef{Name: r.Name}
+	for _, variant := range r.Variants {
+		if !slices.Contains(other.Variants, variant) {
+			remaining.Variants = append(remaining.Variants, variant)
+		}
+	}
+	return remaining
+}
+
+func parseFeatureRefs(refs []string) ([]FeatureRef, error) {
+	parsed := make([]FeatureRef, len(refs))
+	for i, ref := range refs {
+		var err error
+		if parsed[i], err = ParseFeatureRef(ref); err != nil {
+			return nil, err
+		}
+	}
+	return parsed, nil
+}
@@ -0,0 +1,48 @@
+package template
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseFeatureRef_PlainName(t *testing.T) {
+	ref, err := ParseFeatureRef("ci/github")
+	require.NoError(t, err)
+	assert.Equal(t, FeatureRef{Name: "ci/github"}, ref)
+	assert.Equal(t, "ci/github", ref.String())
+}
+
+func TestParseFeatureRef_Variants(t *testing.T) {
+	ref, err := ParseFeatureRef("ci/github[cache, matrix,cache]")
+	require.NoError(t, err)
+	assert.Equal(t, FeatureRef{Name: "ci/github", Variants: []string{"cache", "matrix"}}, ref)
+	assert.Equal(t, "ci/github[cache,matrix]", ref.String())
+}
+
+func TestParseFeatureRef_Invalid(t *testing.T) {
+	for _, ref := range []string{"ci/github[cache", "ci/github]", "ci/github[]", "ci/github[a][b]", "ci/github[a b]"} {
+		_, err := ParseFeatureRef(ref)
+		assert.ErrorContains(t, err, "invalid feature reference", ref)
+	}
+}
+
+func TestParseFeatureRef_Source(t *testing.T) {
+	ref, err := ParseFeatureRef("infra:ci/github[cache]")
+	require.NoError(t, err)
+	assert.Equal(t, FeatureRef{Name: "infra:ci/github", Variants: []string{"cache"}}, ref)
+
+	for _, ref := range []string{":ci/github", "in fra:ci", "infra:ci:github"} {
+		_, err := ParseFeatureRef(ref)
+		assert.ErrorContains(t, err, "expected source:feature", ref)
+	}
+}
+
+func TestFeatureRef_WithAndWithout(t *testing.T) {
+	applied := FeatureRef{Name: "ci/github", Variants: []string{"cache"}}
+	requested := FeatureRef{Name: "ci/github", Variants: []string{"matrix", "cache"}}
+
+	assert.Equal(t, FeatureRef{Name: "ci/github", Variants: []string{"cache", "matrix"}}, applied.with(requested))
+	assert.Equal(t, FeatureRef{Name: "ci/github", Variants: []string{"matrix"}}, requested.without(applied))
+}
@@ -0,0 +1,239 @@
+package template
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path"
+	"slices"
+
+	"templater/internal/executor"
+	"templater/internal/fs"
+)
+
+type RemoveResult struct {
+	Removed []string
+	Kept    []string
+}
+
+func RemoveFeatures(ctx context.Context, fileSystem fs.FileSystem, exec executor.Executor, templatePath, targetPath string, features []string, opts ApplyOptions) (*RemoveResult, error) {
+	applied, err := readAppliedRefs(fileSystem, targetPath)
+	if err != nil {
+		return nil, err
+	}
+
+	removing := make(map[string]bool)
+	var refs []FeatureRef
+	for _, feature := range features {
+		requested, err := ParseFeatureRef(feature)
+		if err != nil {
+			return nil, err
+		}
+		ref, ok := applied[requested.Name]
+		if !ok {
+			return nil, fmt.Errorf("%s is not applied to %s", displayFeature(requested.Name), targetPath)
+		}
+		if !removing[ref.Name] {
+			removing[ref.Name] = true
+			refs = append(refs, ref)
+		}
+	}
+
+	sources, err := ReadSources(fileSystem, templatePath, targetPath)
+	if err != nil {
+		return nil, err
+	}
+	c := newCatalog(fileSystem, sources)
+	for _, ref := range refs {
+		if err := sources.check(ref.Name); err != nil {
+			return nil, err
+		}
+	}
+	for name := range applied {
+		if removing[name] {
+			continue
+		}
+		for _, ref := range refs {
+			depends, err := c.dependsOn(name, ref.Name)
+			if err != nil {
+				return nil, err
+			}
+			if depends {
+				return nil, fmt.Errorf("cannot remove %s: %s depends on it", displayFeature(ref.Name), displayFeature(name))
+			}
+		}
+	}
+
+	refs, err = removalPlan(c, refs)
+	if err != nil {
+		return nil, err
+	}
+
+	a, err := newApplier(fileSystem, exec, templatePath, targetPath, refs, opts)
+	if err != nil {
+		return nil, err
+	}
+
+	result := &RemoveResult{}
+	for _, ref := range refs {
+		kept, err := a.remove(ctx, ref)
+		if err != nil {
+			return result, err
+		}
+		result.Removed = append(result.Removed, ref.String())
+		result.Kept = append(result.Kept, kept...)
+	}
+	return result, nil
+}
+
+func removalPlan(c *catalog, refs []FeatureRef) ([]FeatureRef, error) {
+	var order []string
+	for _, ref := range refs {
+		deps, err := c.dependencies(ref.Name, nil)
+		var notFound *FeatureNotFoundError
+		switch {
+		case errors.As(err, &notFound):
+			deps = []string{ref.Name}
+		case err != nil:
+			return nil, err
+		}
+		for _, dep := range deps {
+			if !slices.Contains(order, dep) {
+				order = append(order, dep)
+			}
+		}
+	}
+
+	var plan []FeatureRef
+	for i := len(order) - 1; i >= 0; i-- {
+		for _, ref := range refs {
+			if ref.Name == order[i] {
+				plan = append(plan, ref)
+			}
+		}
+	}
+	return plan, nil
+}
+
+func (a *applier) remove(ctx context.Context, ref FeatureRef) ([]string, error) {
+	if err := a.snapshotMetadata(); err != nil {
+		return nil, err
+	}
+	state, err := ReadState(a.fileSystem, a.targetPath)
+	if err != nil {
+		return nil, err
+	}
+	patches, err := a.appliedPatches(ref, state)
+	if err != nil {
+		return nil, err
+	}
+
+	// Undo in the reverse of the apply order: injections, merges and the
+	// overlay may change files the patches touched, so the patches can only
+	// be reversed once those are gone.
+	kept, err := a.removeRecorded(ref, state)
+	if err != nil {
+		return nil, a.undoRemove(context.WithoutCancel(ctx), ref, nil, err)
+	}
+	for i := len(patches) - 1; i >= 0; i-- {
+		if err := a.reversePatch(ctx, ref, patches[i]); err != nil {
+			return nil, a.undoRemove(context.WithoutCancel(ctx), ref, patches[i+1:], err)
+		}
+	}
+
+	if err := a.forgetRecorded(ref, state); err != nil {
+		return nil, a.undoRemove(context.WithoutCancel(ctx), ref, patches, err)
+	}
+	delete(a.backups, ref.Name)
+	return kept, nil
+}
+
+// removeRecorded undoes the injections, merges and overlay files recorded for
+// ref, in that order, and returns what it kept because it was edited.
+func (a *applier) removeRecorded(ref FeatureRef, state *State) ([]string, error) {
+	files := state.featureFiles(ref.Name)
+	merges := state.featureMerges(ref.Name)
+	injections := state.featureInjections(ref.Name)
+	var kept []string
+	for i := len(injections) - 1; i >= 0; i-- {
+		removed, err := a.eject(ref, injections[i])
+		if err != nil {
+			return nil, err
+		}
+		if !removed {
+			kept = append(kept, fmt.Sprintf("%s: %s block at %q", injections[i].Path, displayFeature(ref.Name), injections[i].Anchor))
+		}
+	}
+	for i := len(merges) - 1; i >= 0; i-- {
+		keys, err := a.unmerge(ref, merges[i])
+		if err != nil {
+			return nil, err
+		}
+		kept = append(kept, keys...)
+	}
+	for _, f := range files {
+		filePath := path.Join(a.targetPath, f.Path)
+		data, err := a.fileSystem.ReadFile(filePath)
+		switch {
+		case os.IsNotExist(err):
+		case err != nil:
+			return nil, err
+		case checksum(data) != f.SHA256:
+			kept = append(kept, f.Path)
+		default:
+			a.backup(ref, filePath, data, true)
+			if err := a.fileSystem.Remove(filePath); err != nil {
+				return nil, err
+			}
+		}
+	}
+	return kept, nil
+}
+
+// forgetRecorded drops ref from state.yml and applied.yml and prunes the
+// patches only it had cached.
+func (a *applier) forgetRecorded(ref FeatureRef, state *State) error {
+	recorded := state.featurePatchStates(ref.Name)
+	if len(recorded) > 0 || len(state.featureFiles(ref.Name)) > 0 || len(state.featureMerges(ref.Name)) > 0 || len(state.featureInjections(ref.Name)) > 0 {
+		state.forget(ref.Name)
+		if err := WriteState(a.fileSystem, a.targetPath, state); err != nil {
+			return err
+		}
+	}
+
+	remaining, err := ReadApplied(a.fileSystem, a.targetPath)
+	if err != nil {
+		return err
+	}
+	var others []string
+	for _, entry := range remaining {
+		if r, err := ParseFeatureRef(entry); err != nil || r.Name != ref.Name {
+			others = append(others, entry)
+		}
+	}
+	if err := WriteApplied(a.fileSystem, a.targetPath, others); err != nil {
+		return err
+	}
+	return a.pruneCache(ref, recorded, state)
+}
+
+func (a *applier) undoRemove(ctx context.Context, ref FeatureRef, patches []string, cause error) error {
+	var failed []string
+	for _, patchPath := range patches {
+		if err := a.applyPatch(ctx, ref, patchPath, nil); err != nil {
+			failed = append(failed, ref.String())
+			break
+		}
+	}
+	if err := a.restoreBackups(ref); err != nil {
+		failed = append(failed, ref.String())
+	}
+	if err := a.restoreMetadata(); err != nil {
+		failed = append(failed, ".templater")
+	}
+	if len(failed) > 0 {
+		return &RollbackError{Cause: cause, Failed: slices.Compact(failed)}
+	}
+	return cause
+}
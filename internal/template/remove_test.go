@@ -0,0 +1,232 @@
+package template
+
+import (
+	"context"
+	"testing"
+
+	"templater/internal/testutil/executor"
+	"templater/internal/testutil/fs"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func applyAndRecord(t *testing.T, memfs *fs.MemoryFS, features ...string) {
+	result, err := ApplyFeatures(context.Background(), memfs, &executor.FakeExecutor{}, "templates", "project", features, ApplyOptions{})
+	require.NoError(t, err)
+	require.NoError(t, RecordApplied(memfs, "project", result.Applied, result.TemplateVersions))
+}
+
+func TestRemoveFeatures_ReversesPatchesAndDeletesOverlay(t *testing.T) {
+	memfs := fs.NewMemoryFS()
+	memfs.AddDir("templates")
+	memfs.AddDir("templates/auth")
+	memfs.AddFile("templates/auth/base.patch", []byte(authPatch))
+	memfs.AddDir("templates/auth/files")
+	memfs.AddDir("templates/auth/files/assets")
+	memfs.AddFile("templates/auth/files/assets/logo.png", logo)
+	memfs.AddFile("templates/auth/files/LICENSE", []byte("MIT\n"))
+	memfs.AddDir("project")
+	applyAndRecord(t, memfs, "auth")
+	exec := &executor.FakeExecutor{}
+
+	result, err := RemoveFeatures(context.Background(), memfs, exec, "templates", "project", []string{"auth"}, ApplyOptions{})
+	require.NoError(t, err)
+
+	assert.Equal(t, []string{"auth"}, result.Removed)
+	assert.Empty(t, result.Kept)
+	assert.Equal(t, []string{reverseCommand("project", patchCachePath("project", checksum([]byte(authPatch))))}, commandsOf(exec))
+	_, err = memfs.ReadFile("project/LICENSE")
+	assert.Error(t, err)
+
+	applied, err := ReadApplied(memfs, "project")
+	require.NoError(t, err)
+	assert.Empty(t, applied)
+	state, err := ReadState(memfs, "project")
+	require.NoError(t, err)
+	assert.Empty(t, state.Files)
+}
+
+func TestRemoveFeatures_KeepsModifiedFiles(t *testing.T) {
+	memfs := fs.NewMemoryFS()
+	memfs.AddDir("templates")
+	memfs.AddDir("templates/auth")
+	memfs.AddFile("templates/auth/base.patch", []byte(authPatch))
+	memfs.AddDir("templates/auth/files")
+	memfs.AddDir("templates/auth/files/assets")
+	memfs.AddFile("templates/auth/files/assets/logo.png", logo)
+	memfs.AddFile("templates/auth/files/LICENSE", []byte("MIT\n"))
+	memfs.AddDir("project")
+	applyAndRecord(t, memfs, "auth")
+	memfs.AddFile("project/LICENSE", []byte("MIT, edited\n"))
+
+	result, err := RemoveFeatures(context.Background(), memfs, &executor.FakeExecutor{}, "templates", "project", []string{"auth"}, ApplyOptions{})
+	require.NoError(t, err)
+
+	assert.Equal(t, []string{"LICENSE"}, result.Kept)
+	data, err := memfs.ReadFile("project/LICENSE")
+	require.NoError(t, err)
+	assert.Equal(t, "MIT, edited\n", string(data))
+	_, err = memfs.ReadFile("project/assets/logo.png")
+	assert.Error(t, err)
+}
+
+func TestRemoveFeatures_KeepsPreexistingIdenticalFile(t *testing.T) {
+	memfs := fs.NewMemoryFS()
+	memfs.AddDir("templates")
+	memfs.AddDir("templates/auth")
+	memfs.AddFile("templates/auth/base.patch", []byte(authPatch))
+	memfs.AddDir("templates/auth/files")
+	memfs.AddDir("templates/auth/files/assets")
+	memfs.AddFile("templates/auth/files/assets/logo.png", logo)
+	memfs.AddFile("templates/auth/files/LICENSE", []byte("MIT\n"))
+	memfs.AddDir("project")
+	memfs.AddFile("project/LICENSE", []byte("MIT\n"))
+	applyAndRecord(t, memfs, "auth")
+
+	_, err := RemoveFeatures(context.Background(), memfs, &executor.FakeExecutor{}, "templates", "project", []string{"auth"}, ApplyOptions{})
+	require.NoError(t, err)
+
+	data, err := memfs.ReadFile("project/LICENSE")
+	require.NoError(t, err)
+	assert.Equal(t, "MIT\n", string(data))
+	_, err = memfs.ReadFile("project/assets/logo.png")
+	assert.Error(t, err)
+}
+
+func TestRemoveFeatures_NotApplied(t *testing.T) {
+	memfs := fs.NewMemoryFS()
+	memfs.AddDir("templates")
+	memfs.AddDir("templates/auth")
+	memfs.AddFile("templates/auth/base.patch", []byte(authPatch))
+	memfs.AddDir("project")
+
+	_, err := RemoveFeatures(context.Background(), memfs, &executor.FakeExecutor{}, "templates", "project", []string{"auth"}, ApplyOptions{})
+
+	assert.EqualError(t, err, "auth is not applied to project")
+}
+
+func TestRemoveFeatures_RefusesWhenDependedOn(t *testing.T) {
+	memfs := fs.NewMemoryFS()
+	memfs.AddDir("templates")
+	memfs.AddDir("templates/auth")
+	memfs.AddFile("templates/auth/base.patch", []byte(authPatch))
+	memfs.AddDir("project")
+	applyAndRecord(t, memfs, "auth")
+	memfs.AddDir("templates/auth/oauth")
+	memfs.AddFile("templates/auth/oauth/base.patch", []byte("oauth"))
+	applyAndRecord(t, memfs, "auth/oauth")
+	exec := &executor.FakeExecutor{}
+
+	_, err := RemoveFeatures(context.Background(), memfs, exec, "templates", "project", []string{"auth"}, ApplyOptions{})
+
+	assert.EqualError(t, err, "cannot remove auth: auth/oauth depends on it")
+	assert.Empty(t, exec.Commands)
+}
+
+func TestRemoveFeatures_RemovesDependentsFirst(t *testing.T) {
+	memfs := fs.NewMemoryFS()
+	memfs.AddDir("templates")
+	memfs.AddDir("templates/auth")
+	memfs.AddFile("templates/auth/base.patch", []byte(authPatch))
+	memfs.AddDir("project")
+	applyAndRecord(t, memfs, "auth")
+	memfs.AddDir("templates/auth/oauth")
+	memfs.AddFile("templates/auth/oauth/base.patch", []byte("oauth"))
+	applyAndRecord(t, memfs, "auth/oauth")
+	exec := &executor.FakeExecutor{}
+
+	result, err := RemoveFeatures(context.Background(), memfs, exec, "templates", "project", []string{"auth", "auth/oauth"}, ApplyOptions{})
+	require.NoError(t, err)
+
+	assert.Equal(t, []string{"auth/oauth", "auth"}, result.Removed)
+	assert.Equal(t, []string{
+		reverseCommand("project", patchCachePath("project", checksum([]byte("oauth")))),
+		reverseCommand("project", patchCachePath("project", checksum([]byte(authPatch)))),
+	}, commandsOf(exec))
+}
+
+func TestRemoveFeatures_ReappliesOnReverseFailure(t *testing.T) {
+	memfs := fs.NewMemoryFS()
+	memfs.AddDir("templates")
+	memfs.AddDir("templates/auth")
+	memfs.AddFile("templates/auth/base.patch", []byte(authPatch))
+	memfs.AddDir("templates/auth/files")
+	memfs.AddDir("templates/auth/files/assets")
+	memfs.AddFile("templates/auth/files/assets/logo.png", logo)
+	memfs.AddFile("templates/auth/files/LICENSE", []byte("MIT\n"))
+	memfs.AddDir("project")
+	memfs.AddFile("templates/auth/extra.patch", []byte("extra"))
+	applyAndRecord(t, memfs, "auth")
+	exec := &executor.FakeExecutor{ExitCodes: map[string]int{reverseCommand("project", patchCachePath("project", checksum([]byte(authPatch)))): 1}}
+
+	_, err := RemoveFeatures(context.Background(), memfs, exec, "templates", "project", []string{"auth"}, ApplyOptions{})
+	require.Error(t, err)
+
+	applied, err := ReadApplied(memfs, "project")
+	require.NoError(t, err)
+	assert.Equal(t, []string{"auth"}, applied)
+	_, err = memfs.ReadFile("project/LICENSE")
+	assert.NoError(t, err)
+}
+
+func TestRemoveFeatures_RestoresFeatureWhenCleanupFails(t *testing.T) {
+	memfs := fs.NewMemoryFS()
+	memfs.AddDir("templates")
+	memfs.AddDir("templates/auth")
+	memfs.AddFile("templates/auth/base.patch", []byte(authPatch))
+	memfs.AddDir("templates/auth/files")
+	memfs.AddFile("templates/auth/files/LICENSE", []byte("MIT\n"))
+	memfs.AddDir("templates/auth/merge")
+	memfs.AddFile("templates/auth/merge/package.json", []byte(`{"keywords": ["auth"]}`))
+	memfs.AddDir("project")
+	memfs.AddFile("project/package.json", []byte("{}\n"))
+	applyAndRecord(t, memfs, "auth")
+	stateBefore, err := memfs.ReadFile(statePath("project"))
+	require.NoError(t, err)
+	memfs.AddFile("project/package.json", []byte("{"))
+	exec := &executor.FakeExecutor{}
+
+	_, err = RemoveFeatures(context.Background(), memfs, exec, "templates", "project", []string{"auth"}, ApplyOptions{})
+	require.Error(t, err)
+
+	assert.Empty(t, commandsOf(exec))
+	data, err := memfs.ReadFile("project/LICENSE")
+	require.NoError(t, err)
+	assert.Equal(t, "MIT\n", string(data))
+	stateAfter, err := memfs.ReadFile(statePath("project"))
+	require.NoError(t, err)
+	assert.Equal(t, string(stateBefore), string(stateAfter))
+	applied, err := ReadApplied(memfs, "project")
+	require.NoError(t, err)
+	assert.Equal(t, []string{"auth"}, applied)
+}
+
+func TestRemoveFeatures_EjectsInjectionsBeforeReversingPatches(t *testing.T) {
+	routerPatch := "diff --git a/router.go b/router.go\n" +
+		"--- a/router.go\n" +
+		"+++ b/router.go\n" +
+		"@@ -1 +1 @@\n" +
+		"-package main\n" +
+		"+package app\n"
+	memfs := fs.NewMemoryFS()
+	memfs.AddDir("templates")
+	memfs.AddDir("templates/auth")
+	memfs.AddFile("templates/auth/base.patch", []byte(routerPatch))
+	memfs.AddFile("templates/auth/feature.yml", []byte("inject:\n  - file: router.go\n    anchor: // templater:routes\n    snippet: |\n      r.Handle(\"/login\", login)\n"))
+	memfs.AddDir("project")
+	memfs.AddFile("project/router.go", []byte(routerGo))
+	applyAndRecord(t, memfs, "auth")
+	var atReverse string
+	exec := &executor.FakeExecutor{OnExecute: func(command string) {
+		data, err := memfs.ReadFile("project/router.go")
+		require.NoError(t, err)
+		atReverse = string(data)
+	}}
+
+	_, err := RemoveFeatures(context.Background(), memfs, exec, "templates", "project", []string{"auth"}, ApplyOptions{})
+	require.NoError(t, err)
+
+	assert.Equal(t, []string{reverseCommand("project", patchCachePath("project", checksum([]byte(routerPatch))))}, commandsOf(exec))
+	assert.Equal(t, routerGo, atReverse)
+}
@@ -0,0 +1,87 @@
+package template
+
+import (
+	"bytes"
+	"fmt"
+	"path"
+	"strings"
+	"text/template"
+
+	"templater/internal/fs"
+)
+
+const templateSuffix = ".tmpl"
+
+func renderTemplates(fileSystem fs.FileSystem, templatePath string, ref FeatureRef, vars map[string]string) ([]overlayFile, error) {
+	root := path.Join(templatePath, ref.Name, templatesDir)
+	names, err := walkFiles(fileSystem, root)
+	if err != nil {
+		return nil, err
+	}
+
+	data, err := templateData(ref, vars)
+	if err != nil {
+		return nil, err
+	}
+	var files []overlayFile
+	for _, name := range names {
+		source := path.Join(root, name)
+		target, err := render(source+" (path)", name, data)
+		if err != nil {
+			return nil, err
+		}
+		target = strings.TrimSuffix(target, templateSuffix)
+		if err := validateRenderedPath(source, target); err != nil {
+			return nil, err
+		}
+
+		text, err := fileSystem.ReadFile(source)
+		if err != nil {
+			return nil, err
+		}
+		content, err := render(source, string(text), data)
+		if err != nil {
+			return nil, err
+		}
+		info, err := fileSystem.Stat(source)
+		if err != nil {
+			return nil, err
+		}
+		files = append(files, overlayFile{Source: source, Path: target, Data: []byte(content), Mode: info.Mode().Perm()})
+	}
+	return files, nil
+}
+
+func templateData(ref FeatureRef, vars map[string]string) (map[string]any, error) {
+	data := map[string]any{
+		"Feature":  ref.Name,
+		"Variants": ref.Variants,
+	}
+	for name, value := range vars {
+		if _, reserved := data[name]; reserved {
+			return nil, fmt.Errorf("variable %q of %s is reserved for templates; rename it", name, ref.Name)
+		}
+		data[name] = value
+	}
+	return data, nil
+}
+
+func render(name, text string, data map[string]any) (string, error) {
+	tmpl, err := template.New(name).Option("missingkey=error").Parse(text)
+	if err != nil {
+		return "", fmt.Errorf("failed to parse %w", err)
+	}
+	var out bytes.Buffer
+	if err := tmpl.Execute(&out, data); err != nil {
+		return "", fmt.Errorf("failed to render %w", err)
+	}
+	return out.String(), nil
+}
+
+func validateRenderedPath(source, target string) error {
+	cleaned := path.Clean(target)
+	if target == "" || cleaned != target || path.IsAbs(target) || cleaned == ".." || strings.HasPrefix(cleaned, "../") {
+		return fmt.Errorf("%s: rendered path %q is not a relative path inside the target", source, target)
+	}
+	return nil
+}
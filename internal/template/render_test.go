@@ -0,0 +1,163 @@
+package template
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	"templater/internal/testutil/executor"
+	"templater/internal/testutil/fs"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRenderTemplates_RendersPathsAndContent(t *testing.T) {
+	memfs := fs.NewMemoryFS()
+	memfs.AddDir("templates")
+	memfs.AddDir("templates/auth")
+	memfs.MkdirAll("templates/auth/templates/cmd/{{.Name}}")
+	memfs.AddFile("templates/auth/templates/cmd/{{.Name}}/main.go.tmpl", []byte("package main\n\n// {{.Name}} uses {{.Feature}}\n"))
+	memfs.AddFile("templates/auth/templates/README.md", []byte("# {{.Name}}\n"))
+
+	files, err := renderTemplates(memfs, "templates", FeatureRef{Name: "auth"}, map[string]string{"Name": "api"})
+	require.NoError(t, err)
+
+	assert.Equal(t, []overlayFile{
+		{Source: "templates/auth/templates/README.md", Path: "README.md", Data: []byte("# api\n")},
+		{Source: "templates/auth/templates/cmd/{{.Name}}/main.go.tmpl", Path: "cmd/api/main.go", Data: []byte("package main\n\n// api uses auth\n")},
+	}, files)
+}
+
+func TestRenderTemplates_MissingVariable(t *testing.T) {
+	memfs := fs.NewMemoryFS()
+	memfs.AddDir("templates")
+	memfs.AddDir("templates/auth")
+	memfs.MkdirAll("templates/auth/templates")
+	memfs.AddFile("templates/auth/templates/README.md", []byte("# {{.Name}}\n"))
+
+	_, err := renderTemplates(memfs, "templates", FeatureRef{Name: "auth"}, nil)
+
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), `map has no entry for key "Name"`)
+}
+
+func TestRenderTemplates_RejectsReservedVariable(t *testing.T) {
+	memfs := fs.NewMemoryFS()
+	memfs.AddDir("templates")
+	memfs.AddDir("templates/auth")
+	memfs.MkdirAll("templates/auth/templates")
+	memfs.AddFile("templates/auth/templates/README.md", []byte("# {{.Name}}\n"))
+
+	for _, name := range []string{"Feature", "Variants"} {
+		_, err := renderTemplates(memfs, "templates", FeatureRef{Name: "auth"}, map[string]string{"Name": "api", name: "x"})
+
+		assert.EqualError(t, err, fmt.Sprintf("variable %q of auth is reserved for templates; rename it", name))
+	}
+}
+
+func TestRenderTemplates_RejectsPathOutsideTarget(t *testing.T) {
+	memfs := fs.NewMemoryFS()
+	memfs.MkdirAll("templates/auth/templates/{{.Dir}}")
+	memfs.AddFile("templates/auth/templates/{{.Dir}}/x.txt", []byte("x"))
+
+	_, err := renderTemplates(memfs, "templates", FeatureRef{Name: "auth"}, map[string]string{"Dir": ".."})
+
+	assert.EqualError(t, err, `templates/auth/templates/{{.Dir}}/x.txt: rendered path "../x.txt" is not a relative path inside the target`)
+}
+
+func TestOverlayFiles_RejectsPathProvidedTwice(t *testing.T) {
+	memfs := fs.NewMemoryFS()
+	memfs.AddDir("templates")
+	memfs.AddDir("templates/auth")
+	memfs.MkdirAll("templates/auth/templates")
+	memfs.AddFile("templates/auth/templates/README.md", []byte("# {{.Name}}\n"))
+	memfs.AddDir("templates/auth/files")
+	memfs.AddFile("templates/auth/files/README.md", []byte("# static\n"))
+
+	_, err := overlayFiles(memfs, "templates", FeatureRef{Name: "auth"}, map[string]string{"Name": "api"})
+
+	assert.EqualError(t, err, "templates/auth/templates/README.md: README.md is also provided by templates/auth/files/README.md")
+}
+
+func TestApplyFeatures_WritesRenderedTemplates(t *testing.T) {
+	memfs := fs.NewMemoryFS()
+	memfs.AddDir("templates")
+	memfs.AddDir("templates/auth")
+	memfs.AddFile("templates/auth/base.patch", []byte(authPatch))
+	memfs.MkdirAll("templates/auth/templates/cmd/{{.Name}}")
+	memfs.AddFile("templates/auth/templates/cmd/{{.Name}}/main.go.tmpl", []byte("package main\n\n// {{.Name}} uses {{.Feature}}\n"))
+	memfs.AddFile("templates/auth/templates/README.md", []byte("# {{.Name}}\n"))
+	memfs.AddDir("project")
+
+	_, err := ApplyFeatures(context.Background(), memfs, &executor.FakeExecutor{}, "templates", "project", []string{"auth"}, ApplyOptions{Vars: map[string]map[string]string{"auth": {"Name": "api"}}})
+	require.NoError(t, err)
+
+	data, err := memfs.ReadFile("project/cmd/api/main.go")
+	require.NoError(t, err)
+	assert.Equal(t, "package main\n\n// api uses auth\n", string(data))
+
+	state, err := ReadState(memfs, "project")
+	require.NoError(t, err)
+	assert.Equal(t, []FileState{
+		{Path: "README.md", Feature: "auth", SHA256: checksum([]byte("# api\n"))},
+		{Path: "cmd/api/main.go", Feature: "auth", SHA256: checksum(data)},
+	}, state.Files)
+}
+
+func TestApplyFeatures_RenderErrorRunsNothing(t *testing.T) {
+	memfs := fs.NewMemoryFS()
+	memfs.AddDir("templates")
+	memfs.AddDir("templates/auth")
+	memfs.AddFile("templates/auth/base.patch", []byte(authPatch))
+	memfs.MkdirAll("templates/auth/templates/cmd/{{.Name}}")
+	memfs.AddFile("templates/auth/templates/cmd/{{.Name}}/main.go.tmpl", []byte("package main\n\n// {{.Name}} uses {{.Feature}}\n"))
+	memfs.AddFile("templates/auth/templates/README.md", []byte("# {{.Name}}\n"))
+	memfs.AddDir("project")
+	exec := &executor.FakeExecutor{}
+
+	_, err := ApplyFeatures(context.Background(), memfs, exec, "templates", "project", []string{"auth"}, ApplyOptions{})
+
+	assert.Error(t, err)
+	assert.Empty(t, exec.Commands)
+}
+
+func TestRemoveFeatures_DeletesRenderedTemplates(t *testing.T) {
+	memfs := fs.NewMemoryFS()
+	memfs.AddDir("templates")
+	memfs.AddDir("templates/auth")
+	memfs.AddFile("templates/auth/base.patch", []byte(authPatch))
+	memfs.MkdirAll("templates/auth/templates/cmd/{{.Name}}")
+	memfs.AddFile("templates/auth/templates/cmd/{{.Name}}/main.go.tmpl", []byte("package main\n\n// {{.Name}} uses {{.Feature}}\n"))
+	memfs.AddFile("templates/auth/templates/README.md", []byte("# {{.Name}}\n"))
+	memfs.AddDir("project")
+	result, err := ApplyFeatures(context.Background(), memfs, &executor.FakeExecutor{}, "templates", "project", []string{"auth"}, ApplyOptions{Vars: map[string]map[string]string{"auth": {"Name": "api"}}})
+	require.NoError(t, err)
+	require.NoError(t, RecordApplied(memfs, "project", result.Applied, result.TemplateVersions))
+
+	_, err = RemoveFeatures(context.Background(), memfs, &executor.FakeExecutor{}, "templates", "project", []string{"auth"}, ApplyOptions{})
+	require.NoError(t, err)
+
+	_, err = memfs.ReadFile("project/cmd/api/main.go")
+	assert.Error(t, err)
+}
+
+func TestPreviewFeatures_IncludesRenderedTemplates(t *testing.T) {
+	memfs := fs.NewMemoryFS()
+	memfs.AddDir("templates")
+	memfs.AddDir("templates/auth")
+	memfs.AddFile("templates/auth/base.patch", []byte(authPatch))
+	memfs.MkdirAll("templates/auth/templates/cmd/{{.Name}}")
+	memfs.AddFile("templates/auth/templates/cmd/{{.Name}}/main.go.tmpl", []byte("package main\n\n// {{.Name}} uses {{.Feature}}\n"))
+	memfs.AddFile("templates/auth/templates/README.md", []byte("# {{.Name}}\n"))
+	memfs.AddDir("project")
+
+	preview, err := PreviewFeatures(memfs, "templates", "project", []string{"auth"}, map[string]map[string]string{"auth": {"Name": "api"}})
+	require.NoError(t, err)
+
+	require.Len(t, preview.Changes, 3)
+	assert.Equal(t, "README.md", preview.Changes[0].Path)
+	assert.Equal(t, "# api\n", preview.Changes[0].New)
+	assert.Equal(t, "auth.go", preview.Changes[1].Path)
+	assert.Equal(t, "cmd/api/main.go", preview.Changes[2].Path)
+}
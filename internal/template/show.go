@@ -0,0 +1,130 @@
+package template
+
+import (
+	"path"
+	"slices"
+	"sort"
+	"strings"
+
+	"templater/internal/fs"
+	"templater/internal/patch"
+)
+
+type FeatureInfo struct {
+	Feature      string
+	Manifest     *Manifest
+	Dependencies []string
+	Patches      []string
+	Variants     []string
+	Files        []TouchedFile
+	Hooks        []string
+	SharedWith   map[string][]string
+}
+
+type TouchedFile struct {
+	Path      string
+	Added     int
+	Removed   int
+	IsNew     bool
+	IsDeleted bool
+	IsBinary  bool
+}
+
+func ShowFeature(fileSystem fs.FileSystem, templatePath, feature string) (*FeatureInfo, error) {
+	available, err := ListFeatures(fileSystem, templatePath)
+	if err != nil {
+		return nil, err
+	}
+	if !slices.Contains(available, feature) {
+		return nil, &FeatureNotFoundError{Feature: feature}
+	}
+
+	manifest, err := ReadManifest(fileSystem, templatePath, feature)
+	if err != nil {
+		return nil, err
+	}
+
+	files, err := readFeaturePatch(fileSystem, templatePath, feature)
+	if err != nil {
+		return nil, err
+	}
+
+	patches, err := featurePatches(fileSystem, templatePath, feature)
+	if err != nil {
+		return nil, err
+	}
+
+	variants, err := featureVariants(fileSystem, templatePath, feature)
+	if err != nil {
+		return nil, err
+	}
+
+	hooks, err := featureHooks(fileSystem, templatePath, feature)
+	if err != nil {
+		return nil, err
+	}
+
+	info := &FeatureInfo{
+		Feature:      feature,
+		Manifest:     manifest,
+		Dependencies: ResolveDependencies(feature, available, hasRootPatch(fileSystem, templatePath)),
+		Patches:      relativePaths(path.Join(templatePath, feature), patches),
+		Variants:     variants,
+		Hooks:        hooks,
+		SharedWith:   make(map[string][]string),
+	}
+	info.Files = touchedFiles(files)
+
+	touched := make(map[string]bool, len(info.Files))
+	for _, f := range info.Files {
+		touched[f.Path] = true
+	}
+
+	others := available
+	if hasRootPatch(fileSystem, templatePath) {
+		others = append([]string{""}, available...)
+	}
+	for _, other := range others {
+		if other == feature {
+			continue
+		}
+		otherFiles, err := readFeaturePatch(fileSystem, templatePath, other)
+		if err != nil {
+			return nil, err
+		}
+		for _, f := range otherFiles {
+			if touched[f.Path()] && !slices.Contains(info.SharedWith[f.Path()], other) {
+				info.SharedWith[f.Path()] = append(info.SharedWith[f.Path()], other)
+			}
+		}
+	}
+
+	return info, nil
+}
+
+func touchedFiles(files []patch.File) []TouchedFile {
+	index := make(map[string]int)
+	var touched []TouchedFile
+	for _, f := range files {
+		i, ok := index[f.Path()]
+		if !ok {
+			i = len(touched)
+			index[f.Path()] = i
+			touched = append(touched, TouchedFile{Path: f.Path(), IsNew: f.IsNew})
+		}
+		touched[i].Added += f.Added()
+		touched[i].Removed += f.Removed()
+		touched[i].IsDeleted = f.IsDeleted
+		touched[i].IsBinary = touched[i].IsBinary || f.IsBinary
+	}
+	sort.Slice(touched, func(i, j int) bool { return touched[i].Path < touched[j].Path })
+	return touched
+}
+
+func relativePaths(dir string, paths []string) []string {
+	relative := make([]string, len(paths))
+	for i, p := range paths {
+		relative[i] = strings.TrimPrefix(p, dir+"/")
+	}
+	return relative
+}
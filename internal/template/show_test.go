@@ -0,0 +1,50 @@
+package template
+
+import (
+	"testing"
+
+	"templater/internal/testutil/fs"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestShowFeature(t *testing.T) {
+	memfs := fs.NewMemoryFS()
+	memfs.AddDir("templates")
+	memfs.AddDir("templates/auth")
+	memfs.AddDir("templates/auth/oauth")
+	memfs.AddDir("templates/auth/oauth/hooks")
+	memfs.AddDir("templates/billing")
+	memfs.AddFile("templates/auth/base.patch", []byte(newAuthFilePatch))
+	memfs.AddFile("templates/auth/oauth/base.patch", []byte(modifyAuthFilePatch))
+	memfs.AddFile("templates/auth/oauth/feature.yml", []byte("description: OAuth login\ntimeout: 2m\n"))
+	memfs.AddFile("templates/auth/oauth/hooks/post-apply", []byte("echo done\n"))
+	memfs.AddDir("templates/auth/oauth/variants")
+	memfs.AddFile("templates/auth/oauth/variants/pkce.patch", []byte("patch"))
+	memfs.AddFile("templates/billing/base.patch", []byte(conflictingPatch))
+
+	info, err := ShowFeature(memfs, "templates", "auth/oauth")
+	require.NoError(t, err)
+
+	assert.Equal(t, "auth/oauth", info.Feature)
+	assert.Equal(t, "OAuth login", info.Manifest.Description)
+	assert.Equal(t, "2m", info.Manifest.Timeout)
+	assert.Equal(t, []string{"auth", "auth/oauth"}, info.Dependencies)
+	assert.Equal(t, []string{"base.patch"}, info.Patches)
+	assert.Equal(t, []string{"pkce"}, info.Variants)
+	assert.Equal(t, []TouchedFile{{Path: "auth.txt", Added: 1, Removed: 0}}, info.Files)
+	assert.Equal(t, []string{"post-apply"}, info.Hooks)
+	assert.Equal(t, map[string][]string{"auth.txt": {"auth"}}, info.SharedWith)
+}
+
+func TestShowFeature_NotFound(t *testing.T) {
+	memfs := fs.NewMemoryFS()
+	memfs.AddDir("templates")
+
+	_, err := ShowFeature(memfs, "templates", "missing")
+
+	var notFound *FeatureNotFoundError
+	require.ErrorAs(t, err, &notFound)
+	assert.Equal(t, "missing", notFound.Feature)
+}
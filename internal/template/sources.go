@@ -0,0 +1,216 @@
+package template
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path"
+	"slices"
+	"sort"
+	"strings"
+
+	"templater/internal/fs"
+
+	"gopkg.in/yaml.v3"
+)
+
+type Sources map[string]string
+
+type sourcesYml struct {
+	Sources map[string]string `yaml:"sources"`
+}
+
+func sourcesPath(targetPath string) string {
+	return path.Join(targetPath, ".templater/sources.yml")
+}
+
+func ReadSources(fileSystem fs.FileSystem, templatePath, targetPath string) (Sources, error) {
+	sources := Sources{"": templatePath}
+	data, err := fileSystem.ReadFile(sourcesPath(targetPath))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return sources, nil
+		}
+		return nil, err
+	}
+
+	var doc sourcesYml
+	if err := yaml.Unmarshal(data, &doc); err != nil {
+		return nil, fmt.Errorf("%s: %w", sourcesPath(targetPath), err)
+	}
+	for name, repo := range doc.Sources {
+		if !variantNamePattern.MatchString(name) || repo == "" {
+			return nil, fmt.Errorf("%s: invalid source %q: expected name: <template-repo>", sourcesPath(targetPath), name)
+		}
+		if !path.IsAbs(repo) {
+			repo = path.Join(targetPath, repo)
+		}
+		sources[name] = repo
+	}
+	return sources, nil
+}
+
+func AddSource(fileSystem fs.FileSystem, targetPath, name, repo string) error {
+	if !variantNamePattern.MatchString(name) {
+		return fmt.Errorf("invalid source name %q", name)
+	}
+	doc := sourcesYml{Sources: make(map[string]string)}
+	data, err := fileSystem.ReadFile(sourcesPath(targetPath))
+	switch {
+	case err == nil:
+		if err := yaml.Unmarshal(data, &doc); err != nil {
+			return fmt.Errorf("%s: %w", sourcesPath(targetPath), err)
+		}
+		if doc.Sources == nil {
+			doc.Sources = make(map[string]string)
+		}
+	case !os.IsNotExist(err):
+		return err
+	}
+
+	doc.Sources[name] = repo
+	data, err = yaml.Marshal(doc)
+	if err != nil {
+		return err
+	}
+	return fileSystem.WriteFile(sourcesPath(targetPath), data)
+}
+
+func (s Sources) Names() []string {
+	var names []string
+	for name := range s {
+		if name != "" {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	return names
+}
+
+func (s Sources) check(feature string) error {
+	source, _ := splitSource(feature)
+	if _, ok := s[source]; !ok {
+		return fmt.Errorf("unknown template source %q in %s (add it with templater source add)", source, displayFeature(feature))
+	}
+	return nil
+}
+
+func (s Sources) locate(ref FeatureRef) (string, FeatureRef) {
+	source, name := splitSource(ref.Name)
+	return s[source], FeatureRef{Name: name, Variants: ref.Variants}
+}
+
+func splitSource(feature string) (string, string) {
+	if source, name, ok := strings.Cut(feature, ":"); ok {
+		return source, name
+	}
+	return "", feature
+}
+
+func qualify(source, feature string) string {
+	if source == "" || strings.Contains(feature, ":") {
+		return feature
+	}
+	return source + ":" + feature
+}
+
+type catalog struct {
+	fileSystem fs.FileSystem
+	sources    Sources
+	available  map[string][]string
+	hasRoot    map[string]bool
+	configs    map[string]*RepoConfig
+}
+
+func newCatalog(fileSystem fs.FileSystem, sources Sources) *catalog {
+	return &catalog{
+		fileSystem: fileSystem,
+		sources:    sources,
+		available:  make(map[string][]string),
+		hasRoot:    make(map[string]bool),
+		configs:    make(map[string]*RepoConfig),
+	}
+}
+
+// config reads and validates a source's templater.yml the first time the
+// source is used.
+func (c *catalog) config(source string) (*RepoConfig, error) {
+	if config, ok := c.configs[source]; ok {
+		return config, nil
+	}
+	config, err := ReadRepoConfig(c.fileSystem, c.sources[source])
+	if err != nil {
+		return nil, err
+	}
+	c.configs[source] = config
+	return config, nil
+}
+
+func (c *catalog) features(source string) ([]string, error) {
+	if available, ok := c.available[source]; ok {
+		return available, nil
+	}
+	if _, err := c.config(source); err != nil {
+		return nil, err
+	}
+	available, err := ListFeatures(c.fileSystem, c.sources[source])
+	if err != nil {
+		return nil, err
+	}
+	c.available[source] = available
+	c.hasRoot[source] = hasRootPatch(c.fileSystem, c.sources[source])
+	return available, nil
+}
+
+func (c *catalog) dependencies(feature string, requiredBy []string) ([]string, error) {
+	if i := slices.Index(requiredBy, feature); i >= 0 {
+		return nil, fmt.Errorf("dependency cycle: %s", strings.Join(append(requiredBy[i:], feature), " -> "))
+	}
+	if err := c.sources.check(feature); err != nil {
+		return nil, err
+	}
+	source, name := splitSource(feature)
+	available, err := c.features(source)
+	if err != nil {
+		return nil, err
+	}
+	deps := ResolveDependencies(name, available, c.hasRoot[source])
+	if len(deps) == 0 {
+		return nil, &FeatureNotFoundError{Feature: feature}
+	}
+
+	var chain []string
+	for _, dep := range deps {
+		manifest, err := ReadManifest(c.fileSystem, c.sources[source], dep)
+		if err != nil {
+			return nil, err
+		}
+		for _, required := range manifest.Requires {
+			requiredChain, err := c.dependencies(qualify(source, required), append(slices.Clone(requiredBy), feature))
+			if err != nil {
+				return nil, err
+			}
+			for _, r := range requiredChain {
+				if !slices.Contains(chain, r) {
+					chain = append(chain, r)
+				}
+			}
+		}
+		if q := qualify(source, dep); !slices.Contains(chain, q) {
+			chain = append(chain, q)
+		}
+	}
+	return chain, nil
+}
+
+func (c *catalog) dependsOn(feature, dep string) (bool, error) {
+	deps, err := c.dependencies(feature, nil)
+	var notFound *FeatureNotFoundError
+	if errors.As(err, &notFound) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	return dep != feature && slices.Contains(deps, dep), nil
+}
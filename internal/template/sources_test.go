@@ -0,0 +1,194 @@
+package template
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"templater/internal/testutil/executor"
+	"templater/internal/testutil/fs"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestReadSources_ResolvesRelativePaths(t *testing.T) {
+	memfs := fs.NewMemoryFS()
+	memfs.AddFile("project/.templater/sources.yml", []byte("sources:\n  infra: ../infra\n"))
+
+	sources, err := ReadSources(memfs, "templates", "project")
+	require.NoError(t, err)
+
+	assert.Equal(t, Sources{"": "templates", "infra": "infra"}, sources)
+	assert.Equal(t, []string{"infra"}, sources.Names())
+}
+
+func TestAddSource_KeepsExistingSources(t *testing.T) {
+	memfs := fs.NewMemoryFS()
+	memfs.AddFile("project/.templater/sources.yml", []byte("sources:\n  infra: ../infra\n"))
+	require.NoError(t, AddSource(memfs, "project", "ops", "/srv/ops"))
+
+	sources, err := ReadSources(memfs, "templates", "project")
+	require.NoError(t, err)
+	assert.Equal(t, Sources{"": "templates", "infra": "infra", "ops": "/srv/ops"}, sources)
+
+	assert.EqualError(t, AddSource(memfs, "project", "my ops", "ops"), `invalid source name "my ops"`)
+}
+
+func TestCatalog_ResolvesRequiresAcrossSources(t *testing.T) {
+	memfs := fs.NewMemoryFS()
+	memfs.AddDir("templates")
+	memfs.AddDir("templates/web")
+	memfs.AddFile("templates/web/base.patch", []byte("web"))
+	memfs.AddFile("templates/web/feature.yml", []byte("requires:\n  - infra:ci/github\n"))
+	memfs.AddDir("infra")
+	memfs.AddDir("infra/ci")
+	memfs.AddFile("infra/ci/base.patch", []byte("ci"))
+	memfs.AddDir("infra/ci/github")
+	memfs.AddFile("infra/ci/github/base.patch", []byte("github"))
+	memfs.AddDir("project/.templater")
+	memfs.AddFile("project/.templater/sources.yml", []byte("sources:\n  infra: ../infra\n"))
+	sources, err := ReadSources(memfs, "templates", "project")
+	require.NoError(t, err)
+
+	deps, err := newCatalog(memfs, sources).dependencies("web", nil)
+	require.NoError(t, err)
+
+	assert.Equal(t, []string{"infra:ci", "infra:ci/github", "web"}, deps)
+}
+
+func TestCatalog_UnknownSource(t *testing.T) {
+	memfs := fs.NewMemoryFS()
+	memfs.AddDir("templates")
+	memfs.AddDir("templates/web")
+	memfs.AddFile("templates/web/base.patch", []byte("web"))
+	memfs.AddFile("templates/web/feature.yml", []byte("requires:\n  - ops:deploy\n"))
+	memfs.AddDir("infra")
+	memfs.AddDir("infra/ci")
+	memfs.AddFile("infra/ci/base.patch", []byte("ci"))
+	memfs.AddDir("infra/ci/github")
+	memfs.AddFile("infra/ci/github/base.patch", []byte("github"))
+	memfs.AddDir("project/.templater")
+	memfs.AddFile("project/.templater/sources.yml", []byte("sources:\n  infra: ../infra\n"))
+	sources, err := ReadSources(memfs, "templates", "project")
+	require.NoError(t, err)
+
+	_, err = newCatalog(memfs, sources).dependencies("web", nil)
+
+	assert.EqualError(t, err, `unknown template source "ops" in ops:deploy (add it with templater source add)`)
+}
+
+func TestCatalog_DependencyCycle(t *testing.T) {
+	memfs := fs.NewMemoryFS()
+	memfs.AddDir("templates")
+	memfs.AddDir("templates/web")
+	memfs.AddFile("templates/web/base.patch", []byte("web"))
+	memfs.AddFile("templates/web/feature.yml", []byte("requires:\n  - infra:ci/github\n"))
+	memfs.AddDir("infra")
+	memfs.AddDir("infra/ci")
+	memfs.AddFile("infra/ci/base.patch", []byte("ci"))
+	memfs.AddDir("infra/ci/github")
+	memfs.AddFile("infra/ci/github/base.patch", []byte("github"))
+	memfs.AddDir("project/.templater")
+	memfs.AddFile("project/.templater/sources.yml", []byte("sources:\n  infra: ../infra\n"))
+	memfs.AddFile("infra/ci/feature.yml", []byte("requires:\n  - auth\n"))
+	memfs.AddDir("infra/auth")
+	memfs.AddFile("infra/auth/base.patch", []byte("auth"))
+	memfs.AddFile("infra/auth/feature.yml", []byte("requires:\n  - ci/github\n"))
+	sources, err := ReadSources(memfs, "templates", "project")
+	require.NoError(t, err)
+
+	_, err = newCatalog(memfs, sources).dependencies("web", nil)
+
+	assert.EqualError(t, err, "dependency cycle: infra:ci/github -> infra:auth -> infra:ci/github")
+}
+
+func TestApplyFeatures_AppliesFeaturesFromNamedSources(t *testing.T) {
+	memfs := fs.NewMemoryFS()
+	memfs.AddDir("templates")
+	memfs.AddDir("templates/web")
+	memfs.AddFile("templates/web/base.patch", []byte("web"))
+	memfs.AddFile("templates/web/feature.yml", []byte("requires:\n  - infra:ci/github\n"))
+	memfs.AddDir("infra")
+	memfs.AddDir("infra/ci")
+	memfs.AddFile("infra/ci/base.patch", []byte("ci"))
+	memfs.AddDir("infra/ci/github")
+	memfs.AddFile("infra/ci/github/base.patch", []byte("github"))
+	memfs.AddDir("project/.templater")
+	memfs.AddFile("project/.templater/sources.yml", []byte("sources:\n  infra: ../infra\n"))
+	exec := &executor.FakeExecutor{}
+
+	result, err := ApplyFeatures(context.Background(), memfs, exec, "templates", "project", []string{"web"}, ApplyOptions{})
+	require.NoError(t, err)
+	require.NoError(t, RecordApplied(memfs, "project", result.Applied, result.TemplateVersions))
+
+	assert.Equal(t, []string{"infra:ci", "infra:ci/github", "web"}, result.Applied)
+	assert.Equal(t, []string{
+		applyCommand("project", "infra/ci/base.patch"),
+		applyCommand("project", "infra/ci/github/base.patch"),
+		applyCommand("project", "templates/web/base.patch"),
+	}, commandsOf(exec))
+
+	applied, err := ReadApplied(memfs, "project")
+	require.NoError(t, err)
+	assert.Equal(t, []string{"infra:ci", "infra:ci/github", "web"}, applied)
+
+	state, err := ReadState(memfs, "project")
+	require.NoError(t, err)
+	assert.Equal(t, PatchState{Path: "ci/github/base.patch", Feature: "infra:ci/github", SHA256: checksum([]byte("github"))}, state.Patches[1])
+}
+
+func TestRemoveFeatures_RemovesDependentsBeforeRequiredFeatures(t *testing.T) {
+	memfs := fs.NewMemoryFS()
+	memfs.AddDir("templates")
+	memfs.AddDir("templates/web")
+	memfs.AddFile("templates/web/base.patch", []byte("web"))
+	memfs.AddFile("templates/web/feature.yml", []byte("requires:\n  - infra:ci/github\n"))
+	memfs.AddDir("infra")
+	memfs.AddDir("infra/ci")
+	memfs.AddFile("infra/ci/base.patch", []byte("ci"))
+	memfs.AddDir("infra/ci/github")
+	memfs.AddFile("infra/ci/github/base.patch", []byte("github"))
+	memfs.AddDir("project/.templater")
+	memfs.AddFile("project/.templater/sources.yml", []byte("sources:\n  infra: ../infra\n"))
+	result, err := ApplyFeatures(context.Background(), memfs, &executor.FakeExecutor{}, "templates", "project", []string{"web"}, ApplyOptions{})
+	require.NoError(t, err)
+	require.NoError(t, RecordApplied(memfs, "project", result.Applied, result.TemplateVersions))
+
+	_, err = RemoveFeatures(context.Background(), memfs, &executor.FakeExecutor{}, "templates", "project", []string{"infra:ci/github"}, ApplyOptions{})
+	assert.EqualError(t, err, "cannot remove infra:ci/github: web depends on it")
+
+	removed, err := RemoveFeatures(context.Background(), memfs, &executor.FakeExecutor{}, "templates", "project", []string{"infra:ci/github", "web"}, ApplyOptions{})
+	require.NoError(t, err)
+	assert.Equal(t, []string{"web", "infra:ci/github"}, removed.Removed)
+}
+
+func TestApplyFeatures_CommitRecordsTemplateCommitOfEachSource(t *testing.T) {
+	memfs := fs.NewMemoryFS()
+	memfs.AddDir("templates")
+	memfs.AddDir("templates/web")
+	memfs.AddFile("templates/web/base.patch", []byte("web"))
+	memfs.AddFile("templates/web/feature.yml", []byte("requires:\n  - infra:ci\n"))
+	memfs.AddDir("infra")
+	memfs.AddDir("infra/ci")
+	memfs.AddFile("infra/ci/base.patch", []byte("ci"))
+	memfs.AddDir("project/.templater")
+	memfs.AddFile("project/.templater/sources.yml", []byte("sources:\n  infra: ../infra\n"))
+	exec := &executor.FakeExecutor{Outputs: map[string]string{
+		"git -C templates rev-parse HEAD": "aaa111\n",
+		"git -C infra rev-parse HEAD":     "bbb222\n",
+	}}
+	var messages []string
+	exec.OnExecute = func(command string) {
+		if strings.Contains(command, " commit ") {
+			messages = append(messages, exec.StdinReceived)
+		}
+	}
+
+	_, err := ApplyFeatures(context.Background(), memfs, exec, "templates", "project", []string{"web"}, ApplyOptions{Commit: true})
+	require.NoError(t, err)
+
+	require.Len(t, messages, 2)
+	assert.Contains(t, messages[0], "Templater-Feature: infra:ci\nTemplater-Template-Commit: bbb222\n")
+	assert.Contains(t, messages[1], "Templater-Feature: web\nTemplater-Template-Commit: aaa111\n")
+}
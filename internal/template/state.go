@@ -0,0 +1,264 @@
+package template
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"os"
+	"path"
+	"sort"
+
+	"templater/internal/fs"
+
+	"gopkg.in/yaml.v3"
+)
+
+type State struct {
+	Patches    []PatchState     `yaml:"patches,omitempty"`
+	Files      []FileState      `yaml:"files,omitempty"`
+	Merges     []MergeState     `yaml:"merges,omitempty"`
+	Injections []InjectionState `yaml:"injections,omitempty"`
+}
+
+type PatchState struct {
+	Path    string `yaml:"path"`
+	Feature string `yaml:"feature"`
+	SHA256  string `yaml:"sha256"`
+}
+
+type FileState struct {
+	Path    string `yaml:"path"`
+	Feature string `yaml:"feature"`
+	SHA256  string `yaml:"sha256"`
+}
+
+type MergeState struct {
+	Path    string        `yaml:"path"`
+	Feature string        `yaml:"feature"`
+	Created bool          `yaml:"created,omitempty"`
+	Changes []MergeChange `yaml:"changes"`
+}
+
+type MergeChange struct {
+	Key   []string    `yaml:"key,flow"`
+	Value yaml.Node   `yaml:"value,omitempty"`
+	Items []yaml.Node `yaml:"items,omitempty"`
+}
+
+type InjectionState struct {
+	Path    string `yaml:"path"`
+	Anchor  string `yaml:"anchor"`
+	Feature string `yaml:"feature"`
+	SHA256  string `yaml:"sha256"`
+}
+
+type Drift struct {
+	File    FileState
+	Missing bool
+}
+
+func statePath(targetPath string) string {
+	return path.Join(targetPath, ".templater/state.yml")
+}
+
+func ReadState(fileSystem fs.FileSystem, targetPath string) (*State, error) {
+	data, err := fileSystem.ReadFile(statePath(targetPath))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return &State{}, nil
+		}
+		return nil, err
+	}
+
+	var state State
+	if err := yaml.Unmarshal(data, &state); err != nil {
+		return nil, err
+	}
+	return &state, nil
+}
+
+func WriteState(fileSystem fs.FileSystem, targetPath string, state *State) error {
+	if len(state.Patches) == 0 && len(state.Files) == 0 && len(state.Merges) == 0 && len(state.Injections) == 0 {
+		if err := fileSystem.Remove(statePath(targetPath)); err != nil && !os.IsNotExist(err) {
+			return err
+		}
+		return nil
+	}
+	sort.Slice(state.Files, func(i, j int) bool { return state.Files[i].Path < state.Files[j].Path })
+
+	data, err := yaml.Marshal(state)
+	if err != nil {
+		return err
+	}
+	return fileSystem.WriteFile(statePath(targetPath), data)
+}
+
+func (s *State) put(entry FileState) {
+	for i := range s.Files {
+		if s.Files[i].Path == entry.Path {
+			s.Files[i] = entry
+			return
+		}
+	}
+	s.Files = append(s.Files, entry)
+}
+
+func (s *State) featurePatchStates(feature string) []PatchState {
+	var patches []PatchState
+	for _, p := range s.Patches {
+		if p.Feature == feature {
+			patches = append(patches, p)
+		}
+	}
+	return patches
+}
+
+func (s *State) featureFiles(feature string) []FileState {
+	var files []FileState
+	for _, f := range s.Files {
+		if f.Feature == feature {
+			files = append(files, f)
+		}
+	}
+	return files
+}
+
+func (s *State) featureMerges(feature string) []MergeState {
+	var merges []MergeState
+	for _, m := range s.Merges {
+		if m.Feature == feature {
+			merges = append(merges, m)
+		}
+	}
+	return merges
+}
+
+func (s *State) featureInjections(feature string) []InjectionState {
+	var injections []InjectionState
+	for _, i := range s.Injections {
+		if i.Feature == feature {
+			injections = append(injections, i)
+		}
+	}
+	return injections
+}
+
+func (s *State) forget(feature string) {
+	keptPatches := s.Patches[:0]
+	for _, p := range s.Patches {
+		if p.Feature != feature {
+			keptPatches = append(keptPatches, p)
+		}
+	}
+	s.Patches = keptPatches
+
+	kept := s.Files[:0]
+	for _, f := range s.Files {
+		if f.Feature != feature {
+			kept = append(kept, f)
+		}
+	}
+	s.Files = kept
+
+	keptMerges := s.Merges[:0]
+	for _, m := range s.Merges {
+		if m.Feature != feature {
+			keptMerges = append(keptMerges, m)
+		}
+	}
+	s.Merges = keptMerges
+
+	keptInjections := s.Injections[:0]
+	for _, i := range s.Injections {
+		if i.Feature != feature {
+			keptInjections = append(keptInjections, i)
+		}
+	}
+	s.Injections = keptInjections
+}
+
+func CheckDrift(fileSystem fs.FileSystem, targetPath string) ([]Drift, error) {
+	state, err := ReadState(fileSystem, targetPath)
+	if err != nil {
+		return nil, err
+	}
+
+	var drifted []Drift
+	for _, f := range state.Files {
+		data, err := fileSystem.ReadFile(path.Join(targetPath, f.Path))
+		switch {
+		case os.IsNotExist(err):
+			drifted = append(drifted, Drift{File: f, Missing: true})
+		case err != nil:
+			return nil, err
+		case checksum(data) != f.SHA256:
+			drifted = append(drifted, Drift{File: f})
+		}
+	}
+	return drifted, nil
+}
+
+func checksum(data []byte) string {
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
+
+type fileBackup struct {
+	path    string
+	data    []byte
+	existed bool
+}
+
+func (a *applier) backup(ref FeatureRef, filePath string, data []byte, existed bool) {
+	a.backups[ref.Name] = append(a.backups[ref.Name], fileBackup{path: filePath, data: data, existed: existed})
+}
+
+func (a *applier) restoreBackups(ref FeatureRef) error {
+	backups := a.backups[ref.Name]
+	for i := len(backups) - 1; i >= 0; i-- {
+		b := backups[i]
+		var err error
+		if b.existed {
+			err = a.fileSystem.WriteFile(b.path, b.data)
+		} else {
+			err = a.fileSystem.Remove(b.path)
+		}
+		if err != nil && !os.IsNotExist(err) {
+			return err
+		}
+	}
+	delete(a.backups, ref.Name)
+	return nil
+}
+
+func (a *applier) snapshotMetadata() error {
+	a.metadata = make(map[string][]byte)
+	for _, p := range []string{appliedPath(a.targetPath), statePath(a.targetPath)} {
+		data, err := a.fileSystem.ReadFile(p)
+		switch {
+		case err == nil:
+			a.metadata[p] = data
+		case !os.IsNotExist(err):
+			return err
+		}
+	}
+	return nil
+}
+
+func (a *applier) restoreMetadata() error {
+	if a.metadata == nil {
+		return nil
+	}
+	for _, p := range []string{appliedPath(a.targetPath), statePath(a.targetPath)} {
+		data, ok := a.metadata[p]
+		if ok {
+			if err := a.fileSystem.WriteFile(p, data); err != nil {
+				return err
+			}
+			continue
+		}
+		if err := a.fileSystem.Remove(p); err != nil && !os.IsNotExist(err) {
+			return err
+		}
+	}
+	return nil
+}
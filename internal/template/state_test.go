@@ -0,0 +1,54 @@
+package template
+
+import (
+	"testing"
+
+	"templater/internal/testutil/fs"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestReadState_MissingFileIsEmpty(t *testing.T) {
+	state, err := ReadState(fs.NewMemoryFS(), "project")
+	require.NoError(t, err)
+
+	assert.Empty(t, state.Files)
+}
+
+func TestWriteState_SortsByPath(t *testing.T) {
+	memfs := fs.NewMemoryFS()
+	memfs.AddDir("project")
+
+	err := WriteState(memfs, "project", &State{Files: []FileState{
+		{Path: "b.txt", Feature: "auth", SHA256: "2"},
+		{Path: "a.txt", Feature: "auth", SHA256: "1"},
+	}})
+	require.NoError(t, err)
+
+	state, err := ReadState(memfs, "project")
+	require.NoError(t, err)
+	assert.Equal(t, "a.txt", state.Files[0].Path)
+	assert.Equal(t, "b.txt", state.Files[1].Path)
+}
+
+func TestCheckDrift_ReportsModifiedAndMissingFiles(t *testing.T) {
+	memfs := fs.NewMemoryFS()
+	memfs.AddDir("project")
+	memfs.AddFile("project/same.txt", []byte("same"))
+	memfs.AddFile("project/changed.txt", []byte("edited"))
+	require.NoError(t, WriteState(memfs, "project", &State{Files: []FileState{
+		{Path: "same.txt", Feature: "auth", SHA256: checksum([]byte("same"))},
+		{Path: "changed.txt", Feature: "auth", SHA256: checksum([]byte("original"))},
+		{Path: "gone.txt", Feature: "", SHA256: checksum([]byte("gone"))},
+	}}))
+
+	drifted, err := CheckDrift(memfs, "project")
+	require.NoError(t, err)
+
+	require.Len(t, drifted, 2)
+	assert.Equal(t, "changed.txt", drifted[0].File.Path)
+	assert.False(t, drifted[0].Missing)
+	assert.Equal(t, "gone.txt", drifted[1].File.Path)
+	assert.True(t, drifted[1].Missing)
+}
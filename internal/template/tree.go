@@ -6,10 +6,34 @@ import (
 
 type treeNode struct {
 	name      string
+	path      string
 	isFeature bool
 	children  []*treeNode
 }
 
+type FeatureStatus int
+
+const (
+	StatusUnknown FeatureStatus = iota
+	StatusAvailable
+	StatusApplied
+	StatusConflicting
+)
+
+type Annotation struct {
+	Description string
+	Files       int
+	Lines       int
+	Status      FeatureStatus
+}
+
+// TreeLine is one row of a rendered feature tree: the label drawn with its
+// branch connectors and the path of the feature it stands for.
+type TreeLine struct {
+	Label string
+	Path  string
+}
+
 func (n *treeNode) findChild(name string) *treeNode {
 	for _, c := range n.children {
 		if c.name == name {
@@ -20,17 +44,25 @@ func (n *treeNode) findChild(name string) *treeNode {
 }
 
 func RenderTree(features []string) string {
+	var sb strings.Builder
+	for _, line := range TreeLines(features, false) {
+		sb.WriteString(line.Label + "\n")
+	}
+	return sb.String()
+}
+
+func TreeLines(features []string, ascii bool) []TreeLine {
 	if len(features) == 0 {
-		return ""
+		return nil
 	}
 
 	featureSet := toSet(features)
 	root := buildTree(features, featureSet)
 	collapseNonFeatures(root, "")
 
-	var sb strings.Builder
-	renderChildren(&sb, root.children, "")
-	return sb.String()
+	var lines []TreeLine
+	collectLines(&lines, root.children, "", ascii)
+	return lines
 }
 
 func toSet(items []string) map[string]bool {
@@ -50,7 +82,7 @@ func buildTree(features []string, featureSet map[string]bool) *treeNode {
 			fullPath := strings.Join(parts[:i+1], "/")
 			child := node.findChild(part)
 			if child == nil {
-				child = &treeNode{name: part, isFeature: featureSet[fullPath]}
+				child = &treeNode{name: part, path: fullPath, isFeature: featureSet[fullPath]}
 				node.children = append(node.children, child)
 			}
 			node = child
@@ -85,18 +117,23 @@ func joinPath(prefix, name string) string {
 	return prefix + "/" + name
 }
 
-func renderChildren(sb *strings.Builder, nodes []*treeNode, prefix string) {
+func collectLines(lines *[]TreeLine, nodes []*treeNode, prefix string, ascii bool) {
 	for i, node := range nodes {
 		isLast := i == len(nodes)-1
-		connector, childPrefix := linePrefixes(prefix, isLast)
+		connector, childPrefix := linePrefixes(prefix, isLast, ascii)
 
-		sb.WriteString(connector + node.name + "\n")
-		renderChildren(sb, node.children, childPrefix)
+		*lines = append(*lines, TreeLine{Label: connector + node.name, Path: node.path})
+		collectLines(lines, node.children, childPrefix, ascii)
 	}
 }
 
-func linePrefixes(prefix string, isLast bool) (connector, childPrefix string) {
-	if isLast {
+func linePrefixes(prefix string, isLast, ascii bool) (connector, childPrefix string) {
+	switch {
+	case ascii && isLast:
+		return prefix + "`-- ", prefix + "    "
+	case ascii:
+		return prefix + "|-- ", prefix + "|   "
+	case isLast:
 		return prefix + "└── ", prefix + "    "
 	}
 	return prefix + "├── ", prefix + "│   "
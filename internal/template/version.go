@@ -0,0 +1,149 @@
+package template
+
+import (
+	"fmt"
+	"os"
+	"path"
+	"strconv"
+	"strings"
+
+	"templater/internal/fs"
+
+	"gopkg.in/yaml.v3"
+)
+
+const SchemaVersion = 1
+
+var Version = "dev"
+
+type RepoConfig struct {
+	Schema              int    `yaml:"schema"`
+	MinTemplaterVersion string `yaml:"min_templater_version"`
+	Version             string `yaml:"version"`
+}
+
+func repoConfigPath(templatePath string) string {
+	return path.Join(templatePath, "templater.yml")
+}
+
+func ReadRepoConfig(fileSystem fs.FileSystem, templatePath string) (*RepoConfig, error) {
+	configPath := repoConfigPath(templatePath)
+	data, err := fileSystem.ReadFile(configPath)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return &RepoConfig{}, nil
+		}
+		return nil, err
+	}
+
+	var config RepoConfig
+	if err := yaml.Unmarshal(data, &config); err != nil {
+		return nil, fmt.Errorf("%s: %w", configPath, err)
+	}
+	if err := config.check(Version); err != nil {
+		return nil, fmt.Errorf("%s: %w", configPath, err)
+	}
+	return &config, nil
+}
+
+func (c *RepoConfig) check(running string) error {
+	switch {
+	case c.Schema == 0:
+		return fmt.Errorf("schema is required (add \"schema: %d\")", SchemaVersion)
+	case c.Schema < 0:
+		return fmt.Errorf("schema %d is not valid (expected %d)", c.Schema, SchemaVersion)
+	case c.Schema > SchemaVersion:
+		return fmt.Errorf("schema %d is newer than this templater supports (%d); upgrade templater to use this template repository", c.Schema, SchemaVersion)
+	}
+	if c.Version != "" {
+		if _, ok := parseVersion(c.Version); !ok {
+			return fmt.Errorf("version %q is not a semantic version (expected MAJOR.MINOR.PATCH)", c.Version)
+		}
+	}
+	if c.MinTemplaterVersion == "" {
+		return nil
+	}
+	minimum, ok := parseVersion(c.MinTemplaterVersion)
+	if !ok {
+		return fmt.Errorf("min_templater_version %q is not a semantic version (expected MAJOR.MINOR.PATCH)", c.MinTemplaterVersion)
+	}
+	current, ok := parseVersion(running)
+	if !ok {
+		// Development builds carry no release version; trust them to be
+		// recent enough rather than locking their developers out.
+		return nil
+	}
+	if compareVersions(current, minimum) < 0 {
+		return fmt.Errorf("requires templater %s or newer, but this is %s; upgrade templater to use this template repository", c.MinTemplaterVersion, running)
+	}
+	return nil
+}
+
+type semver struct {
+	major, minor, patch int
+	prerelease          string
+}
+
+func parseVersion(v string) (semver, bool) {
+	core, _, _ := strings.Cut(strings.TrimPrefix(v, "v"), "+")
+	core, prerelease, hasPrerelease := strings.Cut(core, "-")
+	if hasPrerelease && prerelease == "" {
+		return semver{}, false
+	}
+	parts := strings.Split(core, ".")
+	if len(parts) != 3 {
+		return semver{}, false
+	}
+	var numbers [3]int
+	for i, part := range parts {
+		n, err := strconv.Atoi(part)
+		if err != nil || n < 0 || (len(part) > 1 && part[0] == '0') {
+			return semver{}, false
+		}
+		numbers[i] = n
+	}
+	return semver{major: numbers[0], minor: numbers[1], patch: numbers[2], prerelease: prerelease}, true
+}
+
+func compareVersions(a, b semver) int {
+	for _, d := range []int{a.major - b.major, a.minor - b.minor, a.patch - b.patch} {
+		if d != 0 {
+			return d
+		}
+	}
+	switch {
+	case a.prerelease == b.prerelease:
+		return 0
+	case a.prerelease == "":
+		return 1
+	case b.prerelease == "":
+		return -1
+	}
+	return comparePrerelease(a.prerelease, b.prerelease)
+}
+
+// comparePrerelease orders dot-separated prerelease identifiers as semver
+// does: numeric identifiers numerically and below alphanumeric ones, and a
+// shorter list below a longer one it prefixes.
+func comparePrerelease(a, b string) int {
+	as, bs := strings.Split(a, "."), strings.Split(b, ".")
+	for i := 0; i < len(as) && i < len(bs); i++ {
+		an, aErr := strconv.Atoi(as[i])
+		bn, bErr := strconv.Atoi(bs[i])
+		switch {
+		case aErr == nil && bErr == nil:
+			if an != bn {
+				return an - bn
+			}
+		case aErr == nil:
+			return -1
+		case bErr == nil:
+			return 1
+		default:
+			if c := strings.Compare(as[i], bs[i]); c != 0 {
+				return c
+			}
+		}
+	}
+	return len(as) - len(bs)
+}
@@ -0,0 +1,181 @@
+package template
+
+import (
+	"context"
+	"testing"
+
+	"templater/internal/testutil/executor"
+	"templater/internal/testutil/fs"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestReadRepoConfig_Missing(t *testing.T) {
+	memfs := fs.NewMemoryFS()
+	memfs.AddDir("templates")
+	memfs.AddDir("templates/auth")
+	memfs.AddFile("templates/auth/base.patch", []byte(authPatch))
+
+	config, err := ReadRepoConfig(memfs, "templates")
+	require.NoError(t, err)
+
+	assert.Equal(t, &RepoConfig{}, config)
+}
+
+func TestReadRepoConfig(t *testing.T) {
+	defer func(version string) { Version = version }(Version)
+	Version = "1.0.0"
+	memfs := fs.NewMemoryFS()
+	memfs.AddDir("templates")
+	memfs.AddDir("templates/auth")
+	memfs.AddFile("templates/auth/base.patch", []byte(authPatch))
+	memfs.AddFile("templates/templater.yml", []byte("schema: 1\nmin_templater_version: 0.1.0\nversion: 2.3.0\n"))
+
+	config, err := ReadRepoConfig(memfs, "templates")
+	require.NoError(t, err)
+
+	assert.Equal(t, &RepoConfig{Schema: 1, MinTemplaterVersion: "0.1.0", Version: "2.3.0"}, config)
+}
+
+func TestReadRepoConfig_DevelopmentBuildSatisfiesMinimum(t *testing.T) {
+	defer func(version string) { Version = version }(Version)
+	Version = "dev"
+	memfs := fs.NewMemoryFS()
+	memfs.AddDir("templates")
+	memfs.AddFile("templates/templater.yml", []byte("schema: 1\nmin_templater_version: 99.0.0\n"))
+
+	config, err := ReadRepoConfig(memfs, "templates")
+	require.NoError(t, err)
+
+	assert.Equal(t, "99.0.0", config.MinTemplaterVersion)
+}
+
+func TestReadRepoConfig_Errors(t *testing.T) {
+	tests := []struct {
+		name   string
+		config string
+		want   string
+	}{
+		{"missing schema", "version: 1.0.0\n", `templates/templater.yml: schema is required (add "schema: 1")`},
+		{"newer schema", "schema: 2\n", "templates/templater.yml: schema 2 is newer than this templater supports (1); upgrade templater to use this template repository"},
+		{"bad version", "schema: 1\nversion: latest\n", `templates/templater.yml: version "latest" is not a semantic version (expected MAJOR.MINOR.PATCH)`},
+		{"bad minimum", "schema: 1\nmin_templater_version: \"1.2\"\n", `templates/templater.yml: min_templater_version "1.2" is not a semantic version (expected MAJOR.MINOR.PATCH)`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			memfs := fs.NewMemoryFS()
+			memfs.AddDir("templates")
+			memfs.AddDir("templates/auth")
+			memfs.AddFile("templates/auth/base.patch", []byte(authPatch))
+			memfs.AddFile("templates/templater.yml", []byte(tt.config))
+
+			_, err := ReadRepoConfig(memfs, "templates")
+
+			assert.EqualError(t, err, tt.want)
+		})
+	}
+}
+
+func TestRepoConfigCheck_MinimumTemplaterVersion(t *testing.T) {
+	config := &RepoConfig{Schema: 1, MinTemplaterVersion: "1.4.0"}
+
+	assert.EqualError(t, config.check("1.2.0"), "requires templater 1.4.0 or newer, but this is 1.2.0; upgrade templater to use this template repository")
+	assert.Error(t, config.check("v1.4.0-rc.1"))
+	assert.NoError(t, config.check("1.4.0"))
+	assert.NoError(t, config.check("v1.10.0"))
+	assert.NoError(t, config.check("dev"))
+	assert.NoError(t, (&RepoConfig{Schema: 1}).check("dev"))
+}
+
+func TestCompareVersions(t *testing.T) {
+	parse := func(v string) semver {
+		parsed, ok := parseVersion(v)
+		require.True(t, ok, v)
+		return parsed
+	}
+
+	assert.Negative(t, compareVersions(parse("1.9.0"), parse("1.10.0")))
+	assert.Negative(t, compareVersions(parse("2.0.0-beta"), parse("2.0.0")))
+	assert.Zero(t, compareVersions(parse("v2.0.0+build.5"), parse("2.0.0")))
+	assert.Positive(t, compareVersions(parse("2.0.1"), parse("2.0.0")))
+	assert.Negative(t, compareVersions(parse("2.0.0-rc.9"), parse("2.0.0-rc.10")))
+	assert.Negative(t, compareVersions(parse("2.0.0-alpha"), parse("2.0.0-alpha.1")))
+	assert.Negative(t, compareVersions(parse("2.0.0-1"), parse("2.0.0-alpha")))
+	assert.Negative(t, compareVersions(parse("2.0.0-alpha.beta"), parse("2.0.0-beta")))
+}
+
+func TestApplyFeatures_RejectsIncompatibleTemplateRepo(t *testing.T) {
+	memfs := fs.NewMemoryFS()
+	memfs.AddDir("templates")
+	memfs.AddDir("templates/auth")
+	memfs.AddFile("templates/auth/base.patch", []byte(authPatch))
+	memfs.AddFile("templates/templater.yml", []byte("schema: 3\n"))
+	exec := &executor.FakeExecutor{}
+
+	_, err := ApplyFeatures(context.Background(), memfs, exec, "templates", "project", []string{"auth"}, ApplyOptions{})
+
+	assert.Empty(t, exec.Commands)
+
+	assert.ErrorContains(t, err, "schema 3 is newer than this templater supports")
+}
+
+func TestApplyFeatures_RecordsTemplateVersion(t *testing.T) {
+	memfs := fs.NewMemoryFS()
+	memfs.AddDir("templates")
+	memfs.AddDir("templates/auth")
+	memfs.AddFile("templates/auth/base.patch", []byte(authPatch))
+	memfs.AddFile("templates/templater.yml", []byte("schema: 1\nversion: 2.3.0\n"))
+
+	applyAndRecord(t, memfs, "auth")
+
+	versions, err := ReadTemplateVersions(memfs, "project")
+	require.NoError(t, err)
+	assert.Equal(t, map[string]string{"": "2.3.0"}, versions)
+
+	require.NoError(t, WriteApplied(memfs, "project", nil))
+	versions, err = ReadTemplateVersions(memfs, "project")
+	require.NoError(t, err)
+	assert.Equal(t, map[string]string{"": "2.3.0"}, versions)
+}
+
+func TestApplyFeatures_RecordsTemplateVersionPerSource(t *testing.T) {
+	memfs := fs.NewMemoryFS()
+	memfs.AddDir("templates")
+	memfs.AddDir("templates/auth")
+	memfs.AddFile("templates/auth/base.patch", []byte(authPatch))
+	memfs.AddFile("templates/templater.yml", []byte("schema: 1\nversion: 2.3.0\n"))
+	memfs.AddDir("infra/ci")
+	memfs.AddFile("infra/ci/base.patch", []byte("ci"))
+	memfs.AddFile("infra/templater.yml", []byte("schema: 1\nversion: 0.4.1\n"))
+	memfs.AddFile("project/.templater/sources.yml", []byte("sources:\n  infra: ../infra\n"))
+
+	result, err := ApplyFeatures(context.Background(), memfs, &executor.FakeExecutor{}, "templates", "project", []string{"infra:ci"}, ApplyOptions{})
+	require.NoError(t, err)
+	assert.Equal(t, map[string]string{"infra": "0.4.1"}, result.TemplateVersions)
+	require.NoError(t, RecordApplied(memfs, "project", result.Applied, result.TemplateVersions))
+
+	applyAndRecord(t, memfs, "auth")
+
+	versions, err := ReadTemplateVersions(memfs, "project")
+	require.NoError(t, err)
+	assert.Equal(t, map[string]string{"": "2.3.0", "infra": "0.4.1"}, versions)
+	data, err := memfs.ReadFile(appliedPath("project"))
+	require.NoError(t, err)
+	assert.Contains(t, string(data), "template_version: 2.3.0\nsource_versions:\n    infra: 0.4.1\n")
+}
+
+func TestApplyFeatures_CommitRecordsTemplateVersion(t *testing.T) {
+	memfs := fs.NewMemoryFS()
+	memfs.AddDir("templates")
+	memfs.AddDir("templates/auth")
+	memfs.AddFile("templates/auth/base.patch", []byte(authPatch))
+	memfs.AddFile("templates/templater.yml", []byte("schema: 1\nversion: 2.3.0\n"))
+
+	_, err := ApplyFeatures(context.Background(), memfs, &executor.FakeExecutor{}, "templates", "project", []string{"auth"}, ApplyOptions{Commit: true})
+	require.NoError(t, err)
+
+	data, err := memfs.ReadFile(appliedPath("project"))
+	require.NoError(t, err)
+	assert.Equal(t, "applied:\n    - auth\ntemplate_version: 2.3.0\n", string(data))
+}
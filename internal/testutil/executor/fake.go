@@ -1,6 +1,9 @@
 package executor
 
 import (
+	"context"
+	"strings"
+
 	"templater/internal/executor"
 )
 
@@ -13,20 +16,35 @@ type ExecutedCommand struct {
 type FakeExecutor struct {
 	Commands         []ExecutedCommand
 	Stdout           string
+	Outputs          map[string]string
 	Stderr           string
 	DefaultExitCode  int
 	ExitCodes        map[string]int
 	TimeoutCommands  map[string]bool
 	TimeoutExitCodes map[string]int
 	StdinReceived    string
+	OnExecute        func(command string)
 }
 
-func (fake *FakeExecutor) Execute(command string, timeout string, env map[string]string) (stdout, stderr string, exitCode int, err error) {
+func (fake *FakeExecutor) Execute(ctx context.Context, command string, timeout string, env map[string]string) (stdout, stderr string, exitCode int, err error) {
+	if err := ctx.Err(); err != nil {
+		return "", "", -1, err
+	}
 	fake.Commands = append(fake.Commands, ExecutedCommand{Command: command, Timeout: timeout, Env: env})
 	if fake.shouldTimeout(command) {
 		return "", "", fake.timeoutExitCode(command), executor.ErrTimeout
 	}
-	return fake.Stdout, fake.Stderr, fake.exitCodeFor(command), nil
+	if fake.OnExecute != nil {
+		fake.OnExecute(command)
+	}
+	return fake.stdoutFor(command), fake.Stderr, fake.exitCodeFor(command), nil
+}
+
+func (fake *FakeExecutor) stdoutFor(command string) string {
+	if stdout, ok := fake.Outputs[command]; ok {
+		return stdout
+	}
+	return fake.Stdout
 }
 
 func (fake *FakeExecutor) shouldTimeout(command string) bool {
@@ -53,7 +71,25 @@ func (fake *FakeExecutor) exitCodeFor(command string) int {
 	return fake.DefaultExitCode
 }
 
-func (fake *FakeExecutor) ExecuteWithStdin(command string, timeout string, env map[string]string, stdin string) (stdout, stderr string, exitCode int, err error) {
+func (fake *FakeExecutor) ExecuteWithStdin(ctx context.Context, command string, timeout string, env map[string]string, stdin string) (stdout, stderr string, exitCode int, err error) {
 	fake.StdinReceived = stdin
-	return fake.Execute(command, timeout, env)
+	return fake.Execute(ctx, command, timeout, env)
+}
+
+func (fake *FakeExecutor) ExecuteStreaming(ctx context.Context, command string, timeout string, env map[string]string, output executor.OutputFunc) (stdout, stderr string, exitCode int, err error) {
+	stdout, stderr, exitCode, err = fake.Execute(ctx, command, timeout, env)
+	if output != nil {
+		emitLines(output, executor.Stdout, stdout)
+		emitLines(output, executor.Stderr, stderr)
+	}
+	return stdout, stderr, exitCode, err
+}
+
+func emitLines(output executor.OutputFunc, stream executor.Stream, text string) {
+	if text == "" {
+		return
+	}
+	for _, line := range strings.Split(strings.TrimSuffix(text, "\n"), "\n") {
+		output(stream, line)
+	}
 }
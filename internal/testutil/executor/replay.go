@@ -0,0 +1,275 @@
+package executor
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"maps"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+
+	"templater/internal/executor"
+
+	"gopkg.in/yaml.v3"
+)
+
+const RecordEnv = "TEMPLATER_RECORD"
+
+var ErrUnexpectedCommand = errors.New("replay: unexpected command")
+
+type Interaction struct {
+	Command  string            `yaml:"command"`
+	Env      map[string]string `yaml:"env,omitempty"`
+	Stdin    string            `yaml:"stdin,omitempty"`
+	Stdout   string            `yaml:"stdout"`
+	Stderr   string            `yaml:"stderr"`
+	ExitCode int               `yaml:"exit_code"`
+	Error    string            `yaml:"error,omitempty"`
+}
+
+type cassette struct {
+	Interactions []Interaction `yaml:"interactions"`
+}
+
+const timeoutError = "timeout"
+
+func Golden(t testing.TB, path string, placeholders map[string]string) executor.Executor {
+	t.Helper()
+	if os.Getenv(RecordEnv) != "" {
+		absPath, err := filepath.Abs(path)
+		if err != nil {
+			t.Fatalf("resolving %s: %v", path, err)
+		}
+		recorder := NewRecordingExecutor(executor.NewShellExecutor(), placeholders)
+		t.Cleanup(func() {
+			if err := recorder.Save(absPath); err != nil {
+				t.Errorf("saving %s: %v", path, err)
+			}
+		})
+		// git apply ignores paths outside the work tree it is run from, so
+		// record from a directory that is not inside this repository.
+		chdir(t, t.TempDir())
+		return recorder
+	}
+	return NewReplayExecutor(t, path, placeholders)
+}
+
+func chdir(t testing.TB, dir string) {
+	previous, err := os.Getwd()
+	if err != nil {
+		t.Fatalf("getwd: %v", err)
+	}
+	if err := os.Chdir(dir); err != nil {
+		t.Fatalf("chdir %s: %v", dir, err)
+	}
+	t.Cleanup(func() { os.Chdir(previous) })
+}
+
+type RecordingExecutor struct {
+	mu           sync.Mutex
+	inner        executor.Executor
+	placeholders map[string]string
+	Interactions []Interaction
+	Commands     []ExecutedCommand
+}
+
+func NewRecordingExecutor(inner executor.Executor, placeholders map[string]string) *RecordingExecutor {
+	return &RecordingExecutor{inner: inner, placeholders: placeholders}
+}
+
+func (r *RecordingExecutor) Execute(ctx context.Context, command string, timeout string, env map[string]string) (string, string, int, error) {
+	stdout, stderr, exitCode, err := r.inner.Execute(ctx, command, timeout, env)
+	r.record(command, timeout, env, "", stdout, stderr, exitCode, err)
+	return stdout, stderr, exitCode, err
+}
+
+func (r *RecordingExecutor) ExecuteWithStdin(ctx context.Context, command string, timeout string, env map[string]string, stdin string) (string, string, int, error) {
+	stdout, stderr, exitCode, err := r.inner.ExecuteWithStdin(ctx, command, timeout, env, stdin)
+	r.record(command, timeout, env, stdin, stdout, stderr, exitCode, err)
+	return stdout, stderr, exitCode, err
+}
+
+func (r *RecordingExecutor) ExecuteStreaming(ctx context.Context, command string, timeout string, env map[string]string, output executor.OutputFunc) (string, string, int, error) {
+	stdout, stderr, exitCode, err := r.inner.ExecuteStreaming(ctx, command, timeout, env, output)
+	r.record(command, timeout, env, "", stdout, stderr, exitCode, err)
+	return stdout, stderr, exitCode, err
+}
+
+func (r *RecordingExecutor) record(command, timeout string, env map[string]string, stdin, stdout, stderr string, exitCode int, err error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.Commands = append(r.Commands, ExecutedCommand{Command: command, Timeout: timeout, Env: env})
+
+	interaction := Interaction{
+		Command:  abstract(command, r.placeholders),
+		Env:      abstractEnv(env, r.placeholders),
+		Stdin:    abstract(stdin, r.placeholders),
+		Stdout:   abstract(stdout, r.placeholders),
+		Stderr:   abstract(stderr, r.placeholders),
+		ExitCode: exitCode,
+	}
+	switch {
+	case errors.Is(err, executor.ErrTimeout):
+		interaction.Error = timeoutError
+	case err != nil:
+		interaction.Error = abstract(err.Error(), r.placeholders)
+	}
+	r.Interactions = append(r.Interactions, interaction)
+}
+
+func (r *RecordingExecutor) Save(path string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	data, err := yaml.Marshal(cassette{Interactions: r.Interactions})
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(path, data, 0644)
+}
+
+type ReplayExecutor struct {
+	mu           sync.Mutex
+	t            testing.TB
+	path         string
+	placeholders map[string]string
+	interactions []Interaction
+	used         []bool
+	Commands     []ExecutedCommand
+}
+
+func NewReplayExecutor(t testing.TB, path string, placeholders map[string]string) *ReplayExecutor {
+	t.Helper()
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("reading recording %s: %v (record it with %s=1)", path, err, RecordEnv)
+	}
+	var recorded cassette
+	if err := yaml.Unmarshal(data, &recorded); err != nil {
+		t.Fatalf("parsing recording %s: %v", path, err)
+	}
+
+	replay := &ReplayExecutor{
+		t:            t,
+		path:         path,
+		placeholders: placeholders,
+		interactions: recorded.Interactions,
+		used:         make([]bool, len(recorded.Interactions)),
+	}
+	t.Cleanup(replay.assertExhausted)
+	return replay
+}
+
+func (r *ReplayExecutor) Execute(ctx context.Context, command string, timeout string, env map[string]string) (string, string, int, error) {
+	return r.ExecuteWithStdin(ctx, command, timeout, env, "")
+}
+
+func (r *ReplayExecutor) ExecuteWithStdin(ctx context.Context, command string, timeout string, env map[string]string, stdin string) (string, string, int, error) {
+	if err := ctx.Err(); err != nil {
+		return "", "", -1, err
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.Commands = append(r.Commands, ExecutedCommand{Command: command, Timeout: timeout, Env: env})
+
+	key := abstract(command, r.placeholders)
+	keyEnv := abstractEnv(env, r.placeholders)
+	keyStdin := abstract(stdin, r.placeholders)
+	for i, interaction := range r.interactions {
+		if r.used[i] || interaction.Command != key || !maps.Equal(interaction.Env, keyEnv) || interaction.Stdin != keyStdin {
+			continue
+		}
+		r.used[i] = true
+		return r.serve(interaction)
+	}
+
+	r.t.Errorf("%s: no recorded interaction for command %q with env %v and stdin %q\nremaining:\n%s", r.path, key, keyEnv, keyStdin, r.remaining())
+	return "", "", -1, fmt.Errorf("%w: %s", ErrUnexpectedCommand, command)
+}
+
+func (r *ReplayExecutor) ExecuteStreaming(ctx context.Context, command string, timeout string, env map[string]string, output executor.OutputFunc) (string, string, int, error) {
+	stdout, stderr, exitCode, err := r.Execute(ctx, command, timeout, env)
+	if output != nil {
+		emitLines(output, executor.Stdout, stdout)
+		emitLines(output, executor.Stderr, stderr)
+	}
+	return stdout, stderr, exitCode, err
+}
+
+func (r *ReplayExecutor) serve(interaction Interaction) (string, string, int, error) {
+	stdout := expand(interaction.Stdout, r.placeholders)
+	stderr := expand(interaction.Stderr, r.placeholders)
+	switch interaction.Error {
+	case "":
+		return stdout, stderr, interaction.ExitCode, nil
+	case timeoutError:
+		return stdout, stderr, interaction.ExitCode, executor.ErrTimeout
+	default:
+		return stdout, stderr, interaction.ExitCode, errors.New(expand(interaction.Error, r.placeholders))
+	}
+}
+
+func (r *ReplayExecutor) remaining() string {
+	var lines []string
+	for i, interaction := range r.interactions {
+		if !r.used[i] {
+			lines = append(lines, "  "+interaction.Command)
+		}
+	}
+	if len(lines) == 0 {
+		return "  (none)"
+	}
+	return strings.Join(lines, "\n")
+}
+
+func (r *ReplayExecutor) assertExhausted() {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for _, used := range r.used {
+		if !used {
+			r.t.Errorf("%s: recorded interactions were never replayed:\n%s", r.path, r.remaining())
+			return
+		}
+	}
+}
+
+func abstract(text string, placeholders map[string]string) string {
+	for _, name := range byValueLength(placeholders) {
+		text = strings.ReplaceAll(text, placeholders[name], name)
+	}
+	return text
+}
+
+func expand(text string, placeholders map[string]string) string {
+	for _, name := range byValueLength(placeholders) {
+		text = strings.ReplaceAll(text, name, placeholders[name])
+	}
+	return text
+}
+
+func abstractEnv(env map[string]string, placeholders map[string]string) map[string]string {
+	if len(env) == 0 {
+		return nil
+	}
+	abstracted := make(map[string]string, len(env))
+	for key, value := range env {
+		abstracted[key] = abstract(value, placeholders)
+	}
+	return abstracted
+}
+
+func byValueLength(placeholders map[string]string) []string {
+	names := make([]string, 0, len(placeholders))
+	for name := range placeholders {
+		names = append(names, name)
+	}
+	sort.Slice(names, func(i, j int) bool {
+		return len(placeholders[names[i]]) > len(placeholders[names[j]])
+	})
+	return names
+}
@@ -1,6 +1,7 @@
 package fs
 
 import (
+	"fmt"
 	"io/fs"
 	"os"
 	"strings"
@@ -10,12 +11,14 @@ import (
 type MemoryFS struct {
 	files map[string][]byte
 	dirs  map[string]bool
+	modes map[string]os.FileMode
 }
 
 func NewMemoryFS() *MemoryFS {
 	return &MemoryFS{
 		files: make(map[string][]byte),
 		dirs:  make(map[string]bool),
+		modes: make(map[string]os.FileMode),
 	}
 }
 
@@ -27,6 +30,21 @@ func (m *MemoryFS) AddDir(path string) {
 	m.dirs[path] = true
 }
 
+func (m *MemoryFS) MkdirAll(path string) error {
+	for p := path; p != "." && p != "/" && p != ""; p = parentDir(p) {
+		m.dirs[p] = true
+	}
+	return nil
+}
+
+func parentDir(path string) string {
+	i := strings.LastIndex(path, "/")
+	if i < 0 {
+		return ""
+	}
+	return path[:i]
+}
+
 func (m *MemoryFS) ReadFile(path string) ([]byte, error) {
 	data, ok := m.files[path]
 	if !ok {
@@ -40,15 +58,36 @@ func (m *MemoryFS) WriteFile(path string, data []byte) error {
 	return nil
 }
 
+func (m *MemoryFS) Remove(path string) error {
+	if m.dirs[path] {
+		if entries, _ := m.ReadDir(path); len(entries) > 0 {
+			return fmt.Errorf("remove %s: directory not empty", path)
+		}
+		delete(m.dirs, path)
+		return nil
+	}
+	if _, ok := m.files[path]; !ok {
+		return os.ErrNotExist
+	}
+	delete(m.files, path)
+	delete(m.modes, path)
+	return nil
+}
+
+func (m *MemoryFS) Chmod(path string, mode os.FileMode) error {
+	if _, ok := m.files[path]; !ok {
+		return os.ErrNotExist
+	}
+	m.modes[path] = mode
+	return nil
+}
+
 func (m *MemoryFS) AppendFile(path string, data []byte) error {
 	m.files[path] = append(m.files[path], data...)
 	return nil
 }
 
 func (m *MemoryFS) ReadDir(path string) ([]os.DirEntry, error) {
-	if !m.dirs[path] {
-		return nil, os.ErrNotExist
-	}
 	var entries []os.DirEntry
 	seen := make(map[string]bool)
 	prefix := path + "/"
@@ -72,12 +111,15 @@ func (m *MemoryFS) ReadDir(path string) ([]os.DirEntry, error) {
 			}
 		}
 	}
+	if !m.dirs[path] && len(entries) == 0 {
+		return nil, os.ErrNotExist
+	}
 	return entries, nil
 }
 
 func (m *MemoryFS) Stat(path string) (os.FileInfo, error) {
 	if _, ok := m.files[path]; ok {
-		return &memFileInfo{name: path, isDir: false}, nil
+		return &memFileInfo{name: path, isDir: false, mode: m.modes[path]}, nil
 	}
 	if m.dirs[path] {
 		return &memFileInfo{name: path, isDir: true}, nil
@@ -98,11 +140,12 @@ func (e *memDirEntry) Info() (fs.FileInfo, error) { return nil, nil }
 type memFileInfo struct {
 	name  string
 	isDir bool
+	mode  fs.FileMode
 }
 
 func (f *memFileInfo) Name() string       { return f.name }
 func (f *memFileInfo) Size() int64        { return 0 }
-func (f *memFileInfo) Mode() fs.FileMode  { return 0 }
+func (f *memFileInfo) Mode() fs.FileMode  { return f.mode }
 func (f *memFileInfo) ModTime() time.Time { return time.Time{} }
 func (f *memFileInfo) IsDir() bool        { return f.isDir }
 func (f *memFileInfo) Sys() any           { return nil }
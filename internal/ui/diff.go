@@ -0,0 +1,133 @@
+package ui
+
+import (
+	"fmt"
+	"strings"
+
+	"templater/internal/patch"
+	"templater/internal/template"
+)
+
+const (
+	ansiReset = "\033[0m"
+	ansiBold  = "\033[1m"
+	ansiDim   = "\033[2m"
+	ansiRed   = "\033[31m"
+	ansiGreen = "\033[32m"
+	ansiCyan  = "\033[36m"
+)
+
+func RenderDiff(changes []template.FileChange) string {
+	var sb strings.Builder
+	for _, change := range changes {
+		fmt.Fprintf(&sb, "diff --git a/%s b/%s\n", change.Path, change.Path)
+		from, to := "a/"+change.Path, "b/"+change.Path
+		switch {
+		case change.IsAdded():
+			sb.WriteString("new file mode 100644\n")
+			from = "/dev/null"
+		case change.IsDeleted():
+			sb.WriteString("deleted file mode 100644\n")
+			to = "/dev/null"
+		}
+		if change.Binary {
+			fmt.Fprintf(&sb, "Binary files %s and %s differ\n", from, to)
+			continue
+		}
+		sb.WriteString(patch.Unified(from, to, change.Old, change.New))
+	}
+	return sb.String()
+}
+
+func ColorizeDiff(diff string) string {
+	lines := strings.SplitAfter(diff, "\n")
+	var sb strings.Builder
+	for _, line := range lines {
+		text := strings.TrimSuffix(line, "\n")
+		newline := line[len(text):]
+		switch {
+		case text == "":
+			sb.WriteString(line)
+			continue
+		case strings.HasPrefix(text, "diff --git"), strings.HasPrefix(text, "--- "), strings.HasPrefix(text, "+++ "),
+			strings.HasPrefix(text, "new file mode"), strings.HasPrefix(text, "deleted file mode"):
+			sb.WriteString(ansiBold + text + ansiReset)
+		case strings.HasPrefix(text, "@@"):
+			sb.WriteString(ansiCyan + text + ansiReset)
+		case strings.HasPrefix(text, "+"):
+			sb.WriteString(ansiGreen + text + ansiReset)
+		case strings.HasPrefix(text, "-"):
+			sb.WriteString(ansiRed + text + ansiReset)
+		default:
+			sb.WriteString(text)
+		}
+		sb.WriteString(newline)
+	}
+	return sb.String()
+}
+
+func RenderStat(changes []template.FileChange, color bool) string {
+	if len(changes) == 0 {
+		return ""
+	}
+
+	width := 0
+	for _, change := range changes {
+		width = max(width, len(change.Path))
+	}
+
+	var sb strings.Builder
+	var added, modified, deleted, insertions, deletions int
+	for _, change := range changes {
+		status := "M"
+		switch {
+		case change.IsAdded():
+			status = "A"
+			added++
+		case change.IsDeleted():
+			status = "D"
+			deleted++
+		default:
+			modified++
+		}
+
+		if change.Binary {
+			fmt.Fprintf(&sb, " %s %-*s | Bin\n", status, width, change.Path)
+			continue
+		}
+		plus, minus := patch.Count(change.Old, change.New)
+		insertions += plus
+		deletions += minus
+		fmt.Fprintf(&sb, " %s %-*s | %d %s\n", status, width, change.Path, plus+minus, statBar(plus, minus, color))
+	}
+
+	fmt.Fprintf(&sb, " %d %s changed (%d added, %d modified, %d deleted), %d insertions(+), %d deletions(-)\n",
+		len(changes), plural(len(changes), "file", "files"), added, modified, deleted, insertions, deletions)
+	return sb.String()
+}
+
+func statBar(plus, minus int, color bool) string {
+	const maxBar = 40
+	if total := plus + minus; total > maxBar {
+		plus = plus * maxBar / total
+		minus = maxBar - plus
+	}
+	bar := strings.Repeat("+", plus)
+	bars := strings.Repeat("-", minus)
+	if color {
+		if bar != "" {
+			bar = ansiGreen + bar + ansiReset
+		}
+		if bars != "" {
+			bars = ansiRed + bars + ansiReset
+		}
+	}
+	return bar + bars
+}
+
+func plural(n int, singular, pluralForm string) string {
+	if n == 1 {
+		return singular
+	}
+	return pluralForm
+}
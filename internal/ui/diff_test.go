@@ -0,0 +1,59 @@
+package ui
+
+import (
+	"testing"
+
+	"templater/internal/template"
+
+	"github.com/stretchr/testify/assert"
+)
+
+var sampleChanges = []template.FileChange{
+	{Path: "auth.txt", New: "auth\n", NewExists: true},
+	{Path: "main.go", Old: "a\nb\n", New: "a\nB\n", OldExists: true, NewExists: true},
+	{Path: "old.txt", Old: "gone\n", OldExists: true},
+}
+
+func TestRenderDiff(t *testing.T) {
+	assert.Equal(t,
+		"diff --git a/auth.txt b/auth.txt\n"+
+			"new file mode 100644\n"+
+			"--- /dev/null\n"+
+			"+++ b/auth.txt\n"+
+			"@@ -0,0 +1 @@\n"+
+			"+auth\n"+
+			"diff --git a/main.go b/main.go\n"+
+			"--- a/main.go\n"+
+			"+++ b/main.go\n"+
+			"@@ -1,2 +1,2 @@\n"+
+			" a\n"+
+			"-b\n"+
+			"+B\n"+
+			"diff --git a/old.txt b/old.txt\n"+
+			"deleted file mode 100644\n"+
+			"--- a/old.txt\n"+
+			"+++ /dev/null\n"+
+			"@@ -1 +0,0 @@\n"+
+			"-gone\n",
+		RenderDiff(sampleChanges))
+}
+
+func TestColorizeDiff(t *testing.T) {
+	colored := ColorizeDiff("@@ -1 +1 @@\n context\n-old\n+new\n")
+
+	assert.Equal(t,
+		ansiCyan+"@@ -1 +1 @@"+ansiReset+"\n"+
+			" context\n"+
+			ansiRed+"-old"+ansiReset+"\n"+
+			ansiGreen+"+new"+ansiReset+"\n",
+		colored)
+}
+
+func TestRenderStat(t *testing.T) {
+	assert.Equal(t,
+		" A auth.txt | 1 +\n"+
+			" M main.go  | 2 +-\n"+
+			" D old.txt  | 1 -\n"+
+			" 3 files changed (1 added, 1 modified, 1 deleted), 2 insertions(+), 2 deletions(-)\n",
+		RenderStat(sampleChanges, false))
+}
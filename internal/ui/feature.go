@@ -0,0 +1,93 @@
+package ui
+
+import (
+	"fmt"
+	"strings"
+
+	"templater/internal/template"
+)
+
+func RenderFeature(info *template.FeatureInfo) string {
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "Feature: %s\n", displayName(info.Feature))
+	if info.Manifest.Description != "" {
+		fmt.Fprintf(&sb, "Description: %s\n", info.Manifest.Description)
+	}
+	if info.Manifest.Timeout != "" {
+		fmt.Fprintf(&sb, "Timeout: %s\n", info.Manifest.Timeout)
+	}
+
+	sb.WriteString("\nDependency chain:\n")
+	for i, feature := range info.Dependencies {
+		fmt.Fprintf(&sb, "  %d. %s\n", i+1, displayName(feature))
+	}
+
+	sb.WriteString("\nPatches:\n")
+	if len(info.Patches) == 0 {
+		sb.WriteString("  (none)\n")
+	}
+	for _, patchPath := range info.Patches {
+		fmt.Fprintf(&sb, "  %s\n", patchPath)
+	}
+
+	if len(info.Variants) > 0 {
+		sb.WriteString("\nVariants:\n")
+		for _, variant := range info.Variants {
+			fmt.Fprintf(&sb, "  %s\n", variant)
+		}
+	}
+
+	sb.WriteString("\nFiles:\n")
+	if len(info.Files) == 0 {
+		sb.WriteString("  (none)\n")
+	}
+	width := 0
+	for _, file := range info.Files {
+		width = max(width, len(file.Path))
+	}
+	for _, file := range info.Files {
+		status := "M"
+		switch {
+		case file.IsNew:
+			status = "A"
+		case file.IsDeleted:
+			status = "D"
+		}
+		if file.IsBinary {
+			fmt.Fprintf(&sb, "  %s %-*s  binary\n", status, width, file.Path)
+			continue
+		}
+		fmt.Fprintf(&sb, "  %s %-*s  +%d -%d\n", status, width, file.Path, file.Added, file.Removed)
+	}
+
+	if len(info.Manifest.Inject) > 0 {
+		sb.WriteString("\nInjections:\n")
+		for _, inj := range info.Manifest.Inject {
+			fmt.Fprintf(&sb, "  %s at %s\n", inj.File, inj.Anchor)
+		}
+	}
+
+	sb.WriteString("\nHooks:\n")
+	if len(info.Hooks) == 0 {
+		sb.WriteString("  (none)\n")
+	}
+	for _, hook := range info.Hooks {
+		fmt.Fprintf(&sb, "  %s\n", hook)
+	}
+
+	if len(info.SharedWith) > 0 {
+		sb.WriteString("\nAlso touched by:\n")
+		for _, file := range info.Files {
+			others, ok := info.SharedWith[file.Path]
+			if !ok {
+				continue
+			}
+			names := make([]string, len(others))
+			for i, other := range others {
+				names[i] = displayName(other)
+			}
+			fmt.Fprintf(&sb, "  %s: %s\n", file.Path, strings.Join(names, ", "))
+		}
+	}
+	return sb.String()
+}
@@ -0,0 +1,74 @@
+package ui
+
+import (
+	"testing"
+
+	"templater/internal/template"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRenderFeature(t *testing.T) {
+	info := &template.FeatureInfo{
+		Feature:      "auth/oauth",
+		Manifest:     &template.Manifest{Description: "OAuth login", Timeout: "2m"},
+		Dependencies: []string{"", "auth", "auth/oauth"},
+		Patches:      []string{"base.patch", "patches/0001-main-go.patch"},
+		Variants:     []string{"pkce"},
+		Files: []template.TouchedFile{
+			{Path: "auth.txt", Added: 1, Removed: 0, IsNew: true},
+			{Path: "main.go", Added: 3, Removed: 1},
+		},
+		Hooks:      []string{"post-apply"},
+		SharedWith: map[string][]string{"main.go": {"", "database"}},
+	}
+
+	expected := "Feature: auth/oauth\n" +
+		"Description: OAuth login\n" +
+		"Timeout: 2m\n" +
+		"\n" +
+		"Dependency chain:\n" +
+		"  1. (root)\n" +
+		"  2. auth\n" +
+		"  3. auth/oauth\n" +
+		"\n" +
+		"Patches:\n" +
+		"  base.patch\n" +
+		"  patches/0001-main-go.patch\n" +
+		"\n" +
+		"Variants:\n" +
+		"  pkce\n" +
+		"\n" +
+		"Files:\n" +
+		"  A auth.txt  +1 -0\n" +
+		"  M main.go   +3 -1\n" +
+		"\n" +
+		"Hooks:\n" +
+		"  post-apply\n" +
+		"\n" +
+		"Also touched by:\n" +
+		"  main.go: (root), database\n"
+	assert.Equal(t, expected, RenderFeature(info))
+}
+
+func TestRenderFeature_NoFilesOrHooks(t *testing.T) {
+	info := &template.FeatureInfo{
+		Feature:      "auth",
+		Manifest:     &template.Manifest{},
+		Dependencies: []string{"auth"},
+		SharedWith:   map[string][]string{},
+	}
+
+	assert.Equal(t, "Feature: auth\n\nDependency chain:\n  1. auth\n\nPatches:\n  (none)\n\nFiles:\n  (none)\n\nHooks:\n  (none)\n", RenderFeature(info))
+}
+
+func TestRenderFeature_Injections(t *testing.T) {
+	info := &template.FeatureInfo{
+		Feature:      "auth",
+		Manifest:     &template.Manifest{Inject: []template.Injection{{File: "router.go", Anchor: "// templater:routes"}}},
+		Dependencies: []string{"auth"},
+		SharedWith:   map[string][]string{},
+	}
+
+	assert.Contains(t, RenderFeature(info), "\nInjections:\n  router.go at // templater:routes\n\nHooks:\n")
+}
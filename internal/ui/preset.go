@@ -0,0 +1,23 @@
+package ui
+
+import (
+	"fmt"
+	"strings"
+
+	"templater/internal/template"
+)
+
+func RenderPresets(presets []template.Preset) string {
+	width := 0
+	for _, preset := range presets {
+		width = max(width, len(preset.Name))
+	}
+
+	var sb strings.Builder
+	for _, preset := range presets {
+		line := fmt.Sprintf("%-*s  %s", width, preset.Name, preset.Description)
+		sb.WriteString(strings.TrimRight(line, " ") + "\n")
+		fmt.Fprintf(&sb, "%s  %s\n", strings.Repeat(" ", width), strings.Join(preset.Features, ", "))
+	}
+	return sb.String()
+}
@@ -0,0 +1,26 @@
+package ui
+
+import (
+	"testing"
+
+	"templater/internal/template"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRenderPresets(t *testing.T) {
+	presets := []template.Preset{
+		{Name: "api", Features: []string{"auth"}},
+		{Name: "web-service", Description: "Standard HTTP service", Features: []string{"auth/oauth", "ci/github[cache]"}},
+	}
+
+	expected := "api\n" +
+		"             auth\n" +
+		"web-service  Standard HTTP service\n" +
+		"             auth/oauth, ci/github[cache]\n"
+	assert.Equal(t, expected, RenderPresets(presets))
+}
+
+func TestRenderPresets_Empty(t *testing.T) {
+	assert.Equal(t, "", RenderPresets(nil))
+}
@@ -0,0 +1,170 @@
+package ui
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"templater/internal/template"
+)
+
+const refreshInterval = 100 * time.Millisecond
+
+type Progress struct {
+	mu      sync.Mutex
+	w       io.Writer
+	live    bool
+	order   []string
+	rows    map[string]*progressRow
+	drawn   int
+	ticker  *time.Ticker
+	stopped chan struct{}
+}
+
+type progressRow struct {
+	state   template.FeatureState
+	started time.Time
+	elapsed time.Duration
+	output  string
+}
+
+func IsTerminal(f *os.File) bool {
+	info, err := f.Stat()
+	if err != nil {
+		return false
+	}
+	return info.Mode()&os.ModeCharDevice != 0
+}
+
+func NewProgress(w io.Writer, live bool) *Progress {
+	p := &Progress{
+		w:       w,
+		live:    live,
+		rows:    make(map[string]*progressRow),
+		stopped: make(chan struct{}),
+	}
+	if live {
+		p.ticker = time.NewTicker(refreshInterval)
+		go p.refresh()
+	}
+	return p
+}
+
+func (p *Progress) Update(event template.ProgressEvent) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	row, ok := p.rows[event.Feature]
+	if !ok {
+		row = &progressRow{}
+		p.rows[event.Feature] = row
+		p.order = append(p.order, event.Feature)
+	}
+	if event.State == template.FeatureRunning && row.state != template.FeatureRunning {
+		row.started = time.Now()
+	}
+	row.state = event.State
+	row.elapsed = event.Elapsed
+	if event.Output != "" {
+		row.output = event.Output
+	}
+
+	if p.live {
+		p.draw()
+	} else {
+		p.printPlain(event)
+	}
+}
+
+func (p *Progress) Stop() {
+	if !p.live {
+		return
+	}
+	p.ticker.Stop()
+	close(p.stopped)
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.draw()
+}
+
+func (p *Progress) refresh() {
+	for {
+		select {
+		case <-p.stopped:
+			return
+		case <-p.ticker.C:
+			p.mu.Lock()
+			p.draw()
+			p.mu.Unlock()
+		}
+	}
+}
+
+func (p *Progress) printPlain(event template.ProgressEvent) {
+	switch {
+	case event.Output != "":
+		fmt.Fprintf(p.w, "    | %s\n", event.Output)
+	case event.State == template.FeatureDone:
+		fmt.Fprintf(p.w, "Applying %s... done\n", displayName(event.Feature))
+	case event.State == template.FeatureFailed:
+		fmt.Fprintf(p.w, "Applying %s... failed\n", displayName(event.Feature))
+	case event.State == template.FeatureRolledBack:
+		fmt.Fprintf(p.w, "Rolled back %s\n", displayName(event.Feature))
+	}
+}
+
+func (p *Progress) draw() {
+	if p.drawn > 0 {
+		fmt.Fprintf(p.w, "\033[%dA", p.drawn)
+	}
+
+	width := 0
+	for _, feature := range p.order {
+		width = max(width, len(displayName(feature)))
+	}
+
+	for _, feature := range p.order {
+		row := p.rows[feature]
+		line := fmt.Sprintf("%s %-*s  %s", stateGlyph(row.state), width, displayName(feature), row.state)
+		if elapsed := p.elapsed(row); elapsed > 0 {
+			line += fmt.Sprintf(" (%s)", elapsed.Round(100*time.Millisecond))
+		}
+		if row.state == template.FeatureRunning && row.output != "" {
+			line += "  " + strings.TrimSpace(row.output)
+		}
+		fmt.Fprintf(p.w, "\033[2K%s\n", line)
+	}
+	p.drawn = len(p.order)
+}
+
+func (p *Progress) elapsed(row *progressRow) time.Duration {
+	if row.state == template.FeatureRunning && !row.started.IsZero() {
+		return time.Since(row.started)
+	}
+	return row.elapsed
+}
+
+func displayName(feature string) string {
+	if feature == "" {
+		return "(root)"
+	}
+	return feature
+}
+
+func stateGlyph(state template.FeatureState) string {
+	switch state {
+	case template.FeatureRunning:
+		return "…"
+	case template.FeatureDone:
+		return "✓"
+	case template.FeatureFailed:
+		return "✗"
+	case template.FeatureRolledBack:
+		return "↺"
+	}
+	return "·"
+}
@@ -0,0 +1,50 @@
+package ui
+
+import (
+	"bytes"
+	"testing"
+	"time"
+
+	"templater/internal/template"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestProgress_PlainOutput(t *testing.T) {
+	var out bytes.Buffer
+	progress := NewProgress(&out, false)
+
+	progress.Update(template.ProgressEvent{Feature: "auth", State: template.FeaturePending})
+	progress.Update(template.ProgressEvent{Feature: "database", State: template.FeaturePending})
+	progress.Update(template.ProgressEvent{Feature: "auth", State: template.FeatureRunning})
+	progress.Update(template.ProgressEvent{Feature: "auth", State: template.FeatureDone})
+	progress.Update(template.ProgressEvent{Feature: "database", State: template.FeatureRunning})
+	progress.Update(template.ProgressEvent{Feature: "database", State: template.FeatureRunning, Output: "migrating"})
+	progress.Update(template.ProgressEvent{Feature: "database", State: template.FeatureFailed})
+	progress.Update(template.ProgressEvent{Feature: "auth", State: template.FeatureRolledBack})
+	progress.Stop()
+
+	assert.Equal(t,
+		"Applying auth... done\n"+
+			"    | migrating\n"+
+			"Applying database... failed\n"+
+			"Rolled back auth\n",
+		out.String())
+}
+
+func TestProgress_LiveOutputRedrawsRows(t *testing.T) {
+	var out bytes.Buffer
+	progress := NewProgress(&out, true)
+
+	progress.Update(template.ProgressEvent{Feature: "", State: template.FeaturePending})
+	progress.Update(template.ProgressEvent{Feature: "auth", State: template.FeaturePending})
+	progress.Update(template.ProgressEvent{Feature: "", State: template.FeatureDone, Elapsed: 1200 * time.Millisecond})
+	progress.Update(template.ProgressEvent{Feature: "auth", State: template.FeatureDone})
+	progress.Stop()
+
+	final := out.String()[bytes.LastIndex(out.Bytes(), []byte("\033[2A"))+len("\033[2A"):]
+	assert.Equal(t,
+		"\033[2K✓ (root)  done (1.2s)\n"+
+			"\033[2K✓ auth    done\n",
+		final)
+}
@@ -0,0 +1,97 @@
+package ui
+
+import (
+	"fmt"
+	"strings"
+	"unicode/utf8"
+
+	"templater/internal/template"
+)
+
+type TreeOptions struct {
+	Annotations     map[string]template.Annotation
+	ShowDescription bool
+	ShowSize        bool
+	ShowStatus      bool
+	ASCII           bool
+	Color           bool
+}
+
+func RenderTree(features []string, opts TreeOptions) string {
+	lines := template.TreeLines(features, opts.ASCII)
+
+	width := 0
+	sizeWidth := 0
+	for _, line := range lines {
+		width = max(width, utf8.RuneCountInString(line.Label))
+		sizeWidth = max(sizeWidth, len(formatSize(opts.Annotations[line.Path])))
+	}
+
+	var sb strings.Builder
+	for _, line := range lines {
+		annotation := opts.Annotations[line.Path]
+		row := line.Label
+		if opts.ShowStatus || opts.ShowSize || opts.ShowDescription {
+			row += strings.Repeat(" ", width-utf8.RuneCountInString(line.Label))
+		}
+		if opts.ShowStatus {
+			row += "  " + colorize(statusGlyph(annotation.Status, opts.ASCII), statusColor(annotation.Status), opts.Color)
+		}
+		if opts.ShowSize {
+			row += fmt.Sprintf("  %-*s", sizeWidth, formatSize(annotation))
+		}
+		if opts.ShowDescription && annotation.Description != "" {
+			row += "  " + colorize(annotation.Description, ansiDim, opts.Color)
+		}
+		sb.WriteString(strings.TrimRight(row, " ") + "\n")
+	}
+	return sb.String()
+}
+
+func statusGlyph(status template.FeatureStatus, ascii bool) string {
+	glyphs := map[template.FeatureStatus][2]string{
+		template.StatusAvailable:   {"○", "[ ]"},
+		template.StatusApplied:     {"✓", "[x]"},
+		template.StatusConflicting: {"✗", "[!]"},
+	}
+	glyph, ok := glyphs[status]
+	if !ok {
+		glyph = [2]string{" ", "   "}
+	}
+	if ascii {
+		return glyph[1]
+	}
+	return glyph[0]
+}
+
+func statusColor(status template.FeatureStatus) string {
+	switch status {
+	case template.StatusApplied:
+		return ansiGreen
+	case template.StatusConflicting:
+		return ansiRed
+	}
+	return ansiDim
+}
+
+func formatSize(annotation template.Annotation) string {
+	if annotation.Files == 0 {
+		return "-"
+	}
+	files := "files"
+	if annotation.Files == 1 {
+		files = "file"
+	}
+	lines := "lines"
+	if annotation.Lines == 1 {
+		lines = "line"
+	}
+	return fmt.Sprintf("%d %s, %d %s", annotation.Files, files, annotation.Lines, lines)
+}
+
+func colorize(text, color string, enabled bool) string {
+	if !enabled {
+		return text
+	}
+	return color + text + ansiReset
+}
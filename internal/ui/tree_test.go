@@ -0,0 +1,59 @@
+package ui
+
+import (
+	"testing"
+
+	"templater/internal/template"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRenderTree_StatusGlyphs(t *testing.T) {
+	features := []string{"auth", "auth/oauth/github", "auth/oauth/google", "database"}
+	opts := TreeOptions{
+		ShowStatus: true,
+		Annotations: map[string]template.Annotation{
+			"auth":              {Status: template.StatusApplied},
+			"auth/oauth/github": {Status: template.StatusApplied},
+			"auth/oauth/google": {Status: template.StatusAvailable},
+			"database":          {Status: template.StatusConflicting},
+		},
+	}
+	expected := "" +
+		"├── auth              ✓\n" +
+		"│   ├── oauth/github  ✓\n" +
+		"│   └── oauth/google  ○\n" +
+		"└── database          ✗\n"
+	assert.Equal(t, expected, RenderTree(features, opts))
+}
+
+func TestRenderTree_ASCIIWithSizeAndDescription(t *testing.T) {
+	features := []string{"auth", "auth/oauth", "database"}
+	opts := TreeOptions{
+		ShowDescription: true,
+		ShowSize:        true,
+		ShowStatus:      true,
+		ASCII:           true,
+		Annotations: map[string]template.Annotation{
+			"auth":       {Description: "User accounts", Files: 3, Lines: 120, Status: template.StatusApplied},
+			"auth/oauth": {Description: "OAuth login", Files: 1, Lines: 1, Status: template.StatusAvailable},
+			"database":   {Status: template.StatusConflicting},
+		},
+	}
+	expected := "" +
+		"|-- auth       [x]  3 files, 120 lines  User accounts\n" +
+		"|   `-- oauth  [ ]  1 file, 1 line      OAuth login\n" +
+		"`-- database   [!]  -\n"
+	assert.Equal(t, expected, RenderTree(features, opts))
+}
+
+func TestRenderTree_Color(t *testing.T) {
+	opts := TreeOptions{
+		ShowDescription: true,
+		ShowStatus:      true,
+		Color:           true,
+		Annotations:     map[string]template.Annotation{"auth": {Description: "User accounts", Status: template.StatusApplied}},
+	}
+	expected := "└── auth  \033[32m✓\033[0m  \033[2mUser accounts\033[0m\n"
+	assert.Equal(t, expected, RenderTree([]string{"auth"}, opts))
+}
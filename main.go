@@ -1,12 +1,20 @@
 package main
 
 import (
+	"context"
 	"fmt"
+	"io"
 	"os"
+	"os/signal"
+	"path/filepath"
+	"sort"
+	"syscall"
 
+	"templater/internal/config"
 	"templater/internal/executor"
 	"templater/internal/fs"
 	"templater/internal/template"
+	"templater/internal/ui"
 
 	"github.com/spf13/cobra"
 )
@@ -16,6 +24,15 @@ var rootCmd = &cobra.Command{
 	Short: "A CLI tool for applying patch-based features to projects",
 }
 
+var (
+	listFilter     string
+	listTags       []string
+	listDepth      int
+	listApplied    string
+	listLeavesOnly bool
+	listPresets    bool
+)
+
 var listCmd = &cobra.Command{
 	Use:   "list <template-repo>",
 	Short: "Display available features as an ASCII tree",
@@ -24,17 +41,94 @@ var listCmd = &cobra.Command{
 		repoPath := args[0]
 		fileSystem := fs.OSFileSystem{}
 
+		if _, err := template.ReadRepoConfig(fileSystem, repoPath); err != nil {
+			return err
+		}
+
+		if listPresets {
+			presets, err := template.ListPresets(fileSystem, repoPath)
+			if err != nil {
+				return err
+			}
+			fmt.Print(ui.RenderPresets(presets))
+			return nil
+		}
+
 		features, err := template.ListFeatures(fileSystem, repoPath)
 		if err != nil {
 			return err
 		}
 
-		tree := template.RenderTree(features)
+		features, err = template.FilterFeatures(fileSystem, repoPath, features, template.FeatureFilter{
+			Pattern:    listFilter,
+			Tags:       listTags,
+			Depth:      listDepth,
+			LeavesOnly: listLeavesOnly,
+		})
+		if err != nil {
+			return err
+		}
+
+		opts, err := treeOptions(fileSystem, repoPath, listApplied, features)
+		if err != nil {
+			return err
+		}
+
+		tree := ui.RenderTree(features, opts)
 		fmt.Print(tree)
 		return nil
 	},
 }
 
+var (
+	treeDescribe bool
+	treeSize     bool
+	treeASCII    bool
+	treeColor    string
+)
+
+func treeOptions(fileSystem fs.FileSystem, templatePath, targetPath string, features []string) (ui.TreeOptions, error) {
+	color, err := useColor(treeColor)
+	if err != nil {
+		return ui.TreeOptions{}, err
+	}
+
+	opts := ui.TreeOptions{
+		ShowDescription: treeDescribe,
+		ShowSize:        treeSize,
+		ShowStatus:      targetPath != "",
+		ASCII:           treeASCII,
+		Color:           color,
+	}
+	if opts.ShowDescription || opts.ShowSize || opts.ShowStatus {
+		opts.Annotations, err = template.AnnotateFeatures(fileSystem, templatePath, targetPath, features)
+		if err != nil {
+			return ui.TreeOptions{}, err
+		}
+	}
+	return opts, nil
+}
+
+func addFeatureFlags(cmd *cobra.Command) {
+	cmd.Flags().StringVarP(&featuresFile, "file", "f", "", "Read features from a file: one per line with optional name=value variables, # comments and include directives, or YAML (.yml)")
+	cmd.Flags().StringVar(&preset, "preset", "", "Use the features of a preset defined in the template's presets/ directory, plus any positional features")
+}
+
+func addApplyFlags(cmd *cobra.Command) {
+	addFeatureFlags(cmd)
+	cmd.Flags().BoolVar(&sandbox, "sandbox", false, "Run patch commands with a scrubbed environment, confined to the target directory")
+	cmd.Flags().StringVar(&timeout, "timeout", "", "Timeout for each patch command, e.g. 30s or 2m (default 30s, overridable per feature in feature.yml)")
+}
+
+func addTreeFlags(cmd *cobra.Command) {
+	cmd.Flags().BoolVar(&treeDescribe, "describe", false, "Show each feature's description from feature.yml")
+	cmd.Flags().BoolVar(&treeSize, "size", false, "Show the number of files and lines each feature's patch touches")
+	cmd.Flags().BoolVar(&treeASCII, "ascii", false, "Draw the tree and status markers with ASCII characters only")
+	cmd.Flags().StringVar(&treeColor, "color", "auto", "Colourise output: auto, always or never")
+}
+
+var statusTemplate string
+
 var statusCmd = &cobra.Command{
 	Use:   "status <target-dir>",
 	Short: "Show features applied to a target project",
@@ -43,6 +137,22 @@ var statusCmd = &cobra.Command{
 		targetPath := args[0]
 		fileSystem := fs.OSFileSystem{}
 
+		if statusTemplate != "" {
+			if _, err := template.ReadRepoConfig(fileSystem, statusTemplate); err != nil {
+				return err
+			}
+			features, err := template.ListFeatures(fileSystem, statusTemplate)
+			if err != nil {
+				return err
+			}
+			opts, err := treeOptions(fileSystem, statusTemplate, targetPath, features)
+			if err != nil {
+				return err
+			}
+			fmt.Print(ui.RenderTree(features, opts))
+			return nil
+		}
+
 		applied, err := template.ReadApplied(fileSystem, targetPath)
 		if err != nil {
 			return err
@@ -53,17 +163,103 @@ var statusCmd = &cobra.Command{
 			return nil
 		}
 
+		commits, err := template.ReadFeatureCommits(cmd.Context(), executor.NewShellExecutor(), targetPath, "")
+		if err != nil {
+			return err
+		}
+		committed := make(map[string]string)
+		for _, commit := range commits {
+			ref, err := template.ParseFeatureRef(commit.Feature)
+			if err != nil {
+				continue
+			}
+			if _, ok := committed[ref.Name]; !ok {
+				committed[ref.Name] = commit.Commit
+			}
+		}
+
+		drifted, err := template.CheckDrift(fileSystem, targetPath)
+		if err != nil {
+			return err
+		}
+		patchDrift, err := template.CheckPatchDrift(fileSystem, targetPath)
+		if err != nil {
+			return err
+		}
+
+		templateVersions, err := template.ReadTemplateVersions(fileSystem, targetPath)
+		if err != nil {
+			return err
+		}
+		if version, ok := templateVersions[""]; ok {
+			fmt.Printf("Template version: %s\n", version)
+		}
+		var sources []string
+		for source := range templateVersions {
+			if source != "" {
+				sources = append(sources, source)
+			}
+		}
+		sort.Strings(sources)
+		for _, source := range sources {
+			fmt.Printf("Template version (%s): %s\n", source, templateVersions[source])
+		}
+		if len(templateVersions) > 0 {
+			fmt.Println()
+		}
+
 		fmt.Println("Applied features:")
 		for _, feature := range applied {
+			ref, _ := template.ParseFeatureRef(feature)
+			if hash, ok := committed[ref.Name]; ok && len(hash) >= 7 {
+				fmt.Printf("  - %s (commit %s)\n", feature, hash[:7])
+				continue
+			}
 			fmt.Printf("  - %s\n", feature)
 		}
+
+		if len(drifted) > 0 {
+			fmt.Println("\nFiles changed since applied:")
+			for _, drift := range drifted {
+				state := "modified"
+				if drift.Missing {
+					state = "missing"
+				}
+				feature := drift.File.Feature
+				if feature == "" {
+					feature = "(root)"
+				}
+				fmt.Printf("  %s: %s (from %s)\n", state, drift.File.Path, feature)
+			}
+		}
+
+		if len(patchDrift) > 0 {
+			fmt.Println("\nPatches that no longer reverse cleanly:")
+			for _, drift := range patchDrift {
+				feature := drift.Patch.Feature
+				if feature == "" {
+					feature = "(root)"
+				}
+				if drift.File == "" {
+					fmt.Printf("  %s: %s (from %s)\n", drift.Patch.Path, drift.Reason, feature)
+					continue
+				}
+				fmt.Printf("  %s: %s in %s (from %s)\n", drift.File, drift.Reason, drift.Patch.Path, feature)
+			}
+		}
 		return nil
 	},
 }
 
 var (
 	dryRun       bool
+	commit       bool
+	allowDirty   bool
+	stash        bool
 	featuresFile string
+	preset       string
+	timeout      string
+	sandbox      bool
 )
 
 var applyCmd = &cobra.Command{
@@ -73,20 +269,11 @@ var applyCmd = &cobra.Command{
 	RunE: func(cmd *cobra.Command, args []string) error {
 		templatePath := args[0]
 		targetPath := args[1]
-		features := args[2:]
-
-		if featuresFile != "" && len(features) > 0 {
-			return fmt.Errorf("cannot use both -f and positional feature arguments")
-		}
-
 		fileSystem := fs.OSFileSystem{}
 
-		if featuresFile != "" {
-			var err error
-			features, err = template.ParseFeaturesFile(fileSystem, featuresFile)
-			if err != nil {
-				return fmt.Errorf("failed to read features file: %w", err)
-			}
+		features, vars, err := collectFeatures(fileSystem, templatePath, args[2:])
+		if err != nil {
+			return err
 		}
 
 		if len(features) == 0 {
@@ -94,7 +281,7 @@ var applyCmd = &cobra.Command{
 		}
 
 		if dryRun {
-			result, err := template.DryRun(fileSystem, templatePath, targetPath, features)
+			result, err := template.DryRun(fileSystem, templatePath, targetPath, features, vars)
 			if err != nil {
 				return err
 			}
@@ -103,17 +290,43 @@ var applyCmd = &cobra.Command{
 			for i, feature := range result.WouldApply {
 				fmt.Printf("  %d. %s\n", i+1, feature)
 			}
-			return nil
+
+			shared := false
+			for _, overlap := range result.Overlaps {
+				if overlap.Kind != template.OverlapShared {
+					continue
+				}
+				if !shared {
+					fmt.Println("\nTouched by more than one feature:")
+					shared = true
+				}
+				fmt.Printf("  %s\n", overlap)
+			}
+			return template.CheckOverlaps(result.Overlaps)
 		}
 
-		exec := executor.NewShellExecutor()
-		result, err := template.ApplyFeatures(fileSystem, exec, templatePath, targetPath, features)
+		resolvedTimeout, err := resolveTimeout(fileSystem)
 		if err != nil {
 			return err
 		}
 
-		for _, feature := range result.Applied {
-			fmt.Printf("Applying %s... done\n", feature)
+		exec, templatePath, targetPath, err := newExecutor(templatePath, targetPath)
+		if err != nil {
+			return err
+		}
+
+		progress := ui.NewProgress(os.Stdout, ui.IsTerminal(os.Stdout))
+		result, err := template.ApplyFeatures(cmd.Context(), fileSystem, exec, templatePath, targetPath, features, template.ApplyOptions{
+			Progress:   progress.Update,
+			Timeout:    resolvedTimeout,
+			Vars:       vars,
+			Commit:     commit,
+			AllowDirty: allowDirty,
+			Stash:      stash,
+		})
+		progress.Stop()
+		if err != nil {
+			return err
 		}
 
 		appliedCount := len(result.Applied)
@@ -126,10 +339,15 @@ var applyCmd = &cobra.Command{
 		if len(result.AlreadyApplied) > 0 {
 			fmt.Printf(" (%d already applied: %s)", len(result.AlreadyApplied), joinFeatures(result.AlreadyApplied))
 		}
+		if commit && appliedCount > 0 {
+			fmt.Printf(" Created %d %s.", appliedCount, pluralize(appliedCount, "commit", "commits"))
+		}
 		fmt.Println()
+		if result.StashKept {
+			fmt.Fprintln(os.Stderr, "warning: your stashed changes conflict with the applied features and were kept in the stash; resolve them with git stash pop")
+		}
 
-		allApplied := append(result.AlreadyApplied, result.Applied...)
-		if err := template.WriteApplied(fileSystem, targetPath, allApplied); err != nil {
+		if err := template.RecordApplied(fileSystem, targetPath, result.Applied, result.TemplateVersions); err != nil {
 			return fmt.Errorf("failed to update applied.yml: %w", err)
 		}
 
@@ -137,6 +355,301 @@ var applyCmd = &cobra.Command{
 	},
 }
 
+var removeCmd = &cobra.Command{
+	Use:   "remove <template-repo> <target-dir> <features...>",
+	Short: "Reverse applied features, deleting their files/ overlay unless modified since applied",
+	Args:  cobra.MinimumNArgs(3),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		templatePath := args[0]
+		targetPath := args[1]
+		fileSystem := fs.OSFileSystem{}
+
+		resolvedTimeout, err := resolveTimeout(fileSystem)
+		if err != nil {
+			return err
+		}
+
+		exec, templatePath, targetPath, err := newExecutor(templatePath, targetPath)
+		if err != nil {
+			return err
+		}
+
+		result, err := template.RemoveFeatures(cmd.Context(), fileSystem, exec, templatePath, targetPath, args[2:], template.ApplyOptions{
+			Timeout: resolvedTimeout,
+		})
+		if result != nil {
+			for _, feature := range result.Removed {
+				fmt.Printf("Removed %s\n", feature)
+			}
+			for _, path := range result.Kept {
+				fmt.Printf("Kept %s (modified since it was applied)\n", path)
+			}
+		}
+		return err
+	},
+}
+
+var (
+	diffStat  bool
+	diffColor string
+)
+
+var diffCmd = &cobra.Command{
+	Use:   "diff <template-repo> <target-dir> [features...]",
+	Short: "Show the changes features would make to a target without applying them",
+	Args:  cobra.MinimumNArgs(2),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		templatePath := args[0]
+		targetPath := args[1]
+
+		color, err := useColor(diffColor)
+		if err != nil {
+			return err
+		}
+
+		fileSystem := fs.OSFileSystem{}
+		features, vars, err := collectFeatures(fileSystem, templatePath, args[2:])
+		if err != nil {
+			return err
+		}
+		if len(features) == 0 {
+			return fmt.Errorf("no features specified")
+		}
+
+		preview, err := template.PreviewFeatures(fileSystem, templatePath, targetPath, features, vars)
+		if err != nil {
+			return err
+		}
+
+		if diffStat {
+			fmt.Print(ui.RenderStat(preview.Changes, color))
+			return nil
+		}
+
+		diff := ui.RenderDiff(preview.Changes)
+		if color {
+			diff = ui.ColorizeDiff(diff)
+		}
+		fmt.Print(diff)
+		return nil
+	},
+}
+
+var showCmd = &cobra.Command{
+	Use:   "show <template-repo> <feature>",
+	Short: "Show a feature's metadata, dependencies, files and hooks",
+	Args:  cobra.ExactArgs(2),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		if _, err := template.ReadRepoConfig(fs.OSFileSystem{}, args[0]); err != nil {
+			return err
+		}
+		info, err := template.ShowFeature(fs.OSFileSystem{}, args[0], args[1])
+		if err != nil {
+			return err
+		}
+
+		fmt.Print(ui.RenderFeature(info))
+		return nil
+	},
+}
+
+var sourceCmd = &cobra.Command{
+	Use:   "source",
+	Short: "Manage the named template repositories a project composes features from",
+}
+
+var sourceAddCmd = &cobra.Command{
+	Use:   "add <target-dir> <name> <template-repo>",
+	Short: "Add a named template repository whose features are applied as name:feature; a relative path is resolved from <target-dir>",
+	Args:  cobra.ExactArgs(3),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		if err := template.AddSource(fs.OSFileSystem{}, args[0], args[1], args[2]); err != nil {
+			return err
+		}
+		fmt.Printf("Added source %s: %s\n", args[1], args[2])
+		return nil
+	},
+}
+
+var sourceListCmd = &cobra.Command{
+	Use:   "list <target-dir>",
+	Short: "List the named template repositories of a project",
+	Args:  cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		sources, err := template.ReadSources(fs.OSFileSystem{}, "", args[0])
+		if err != nil {
+			return err
+		}
+		for _, name := range sources.Names() {
+			fmt.Printf("%s: %s\n", name, sources[name])
+		}
+		return nil
+	},
+}
+
+var splitCmd = &cobra.Command{
+	Use:   "split <diff-file> <feature-dir>",
+	Short: "Split a diff into a numbered patch series, one patch per file, under <feature-dir>/patches",
+	Args:  cobra.ExactArgs(2),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		var data []byte
+		var err error
+		if args[0] == "-" {
+			data, err = io.ReadAll(os.Stdin)
+		} else {
+			data, err = os.ReadFile(args[0])
+		}
+		if err != nil {
+			return err
+		}
+
+		written, err := template.SplitPatch(fs.OSFileSystem{}, args[1], data)
+		for _, patchPath := range written {
+			fmt.Printf("Wrote %s\n", patchPath)
+		}
+		return err
+	},
+}
+
+var (
+	initNoGit    bool
+	initNoCommit bool
+)
+
+var initCmd = &cobra.Command{
+	Use:   "init <template-repo> <new-dir> [features...]",
+	Short: "Create a new project from a template, applying the root patch and requested features",
+	Args:  cobra.MinimumNArgs(2),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		templatePath := args[0]
+		targetPath := args[1]
+		fileSystem := fs.OSFileSystem{}
+
+		features, vars, err := collectFeatures(fileSystem, templatePath, args[2:])
+		if err != nil {
+			return err
+		}
+
+		resolvedTimeout, err := resolveTimeout(fileSystem)
+		if err != nil {
+			return err
+		}
+
+		exec, templatePath, targetPath, err := newExecutor(templatePath, targetPath)
+		if err != nil {
+			return err
+		}
+
+		progress := ui.NewProgress(os.Stdout, ui.IsTerminal(os.Stdout))
+		result, err := template.InitProject(cmd.Context(), fileSystem, exec, templatePath, targetPath, features, template.InitOptions{
+			Git:    !initNoGit,
+			Commit: !initNoCommit,
+			Apply: template.ApplyOptions{
+				Progress: progress.Update,
+				Timeout:  resolvedTimeout,
+				Vars:     vars,
+			},
+		})
+		progress.Stop()
+		if err != nil {
+			return err
+		}
+
+		fmt.Printf("\nInitialized %s with %d %s.", targetPath, len(result.Applied), pluralize(len(result.Applied), "feature", "features"))
+		if result.Committed {
+			fmt.Print(" Created initial commit.")
+		}
+		fmt.Println()
+		return nil
+	},
+}
+
+func collectFeatures(fileSystem fs.FileSystem, templatePath string, features []string) ([]string, map[string]map[string]string, error) {
+	if featuresFile != "" && len(features) > 0 {
+		return nil, nil, fmt.Errorf("cannot use both -f and positional feature arguments")
+	}
+	if featuresFile != "" && preset != "" {
+		return nil, nil, fmt.Errorf("cannot use both -f and --preset")
+	}
+
+	var vars map[string]map[string]string
+	if featuresFile != "" {
+		list, err := template.ParseFeaturesFile(fileSystem, featuresFile)
+		if err != nil {
+			return nil, nil, fmt.Errorf("failed to read features file: %w", err)
+		}
+		features, vars = list.Features, list.Vars
+	}
+	if preset != "" {
+		list, err := template.LoadPreset(fileSystem, templatePath, preset)
+		if err != nil {
+			return nil, nil, err
+		}
+		features, vars = append(list.Features, features...), list.Vars
+	}
+	return features, vars, nil
+}
+
+func pluralize(n int, singular, plural string) string {
+	if n == 1 {
+		return singular
+	}
+	return plural
+}
+
+func useColor(mode string) (bool, error) {
+	switch mode {
+	case "auto":
+		return ui.IsTerminal(os.Stdout), nil
+	case "always":
+		return true, nil
+	case "never":
+		return false, nil
+	}
+	return false, fmt.Errorf("invalid --color value %q: expected auto, always or never", mode)
+}
+
+// newExecutor returns the executor for patch commands together with the
+// template and target paths to use with it. The sandbox confines writes to
+// the target by path, so it needs both paths absolute.
+func newExecutor(templatePath, targetPath string) (executor.Executor, string, string, error) {
+	if !sandbox {
+		return executor.NewShellExecutor(), templatePath, targetPath, nil
+	}
+
+	absTemplate, err := filepath.Abs(templatePath)
+	if err != nil {
+		return nil, "", "", err
+	}
+	absTarget, err := filepath.Abs(targetPath)
+	if err != nil {
+		return nil, "", "", err
+	}
+
+	sandboxed := executor.NewSandboxExecutor(absTarget)
+	if sandboxed.Isolation() != "landlock" {
+		fmt.Fprintln(os.Stderr, "warning: filesystem isolation unavailable, sandbox limited to environment scrubbing")
+	}
+	return sandboxed, absTemplate, absTarget, nil
+}
+
+func resolveTimeout(fileSystem fs.FileSystem) (string, error) {
+	configPath := config.Path()
+	cfg, err := config.Load(fileSystem, configPath)
+	if err != nil {
+		return "", err
+	}
+
+	setting := cfg.ResolveTimeout(timeout, os.Getenv(config.TimeoutEnv), configPath)
+	if setting.Value == "" {
+		return "", nil
+	}
+	if _, err := executor.ParseTimeout(setting.Value); err != nil {
+		return "", fmt.Errorf("%s: %w", setting.Source, err)
+	}
+	return setting.Value, nil
+}
+
 func joinFeatures(features []string) string {
 	if len(features) == 0 {
 		return ""
@@ -149,17 +662,59 @@ func joinFeatures(features []string) string {
 }
 
 func init() {
+	rootCmd.Version = template.Version
+
+	listCmd.Flags().StringVar(&listFilter, "filter", "", "Only show features matching a glob (e.g. auth/*) or a /regex/")
+	listCmd.Flags().StringArrayVar(&listTags, "tag", nil, "Only show features tagged with this tag in feature.yml (repeatable, all must match)")
+	listCmd.Flags().IntVar(&listDepth, "depth", 0, "Only show features up to this many path segments deep")
+	listCmd.Flags().StringVar(&listApplied, "applied", "", "Mark features as applied, available or conflicting against this target directory")
+	listCmd.Flags().BoolVar(&listLeavesOnly, "leaves-only", false, "Only show features without sub-features")
+	listCmd.Flags().BoolVar(&listPresets, "presets", false, "List the presets defined under presets/ instead of features")
+	addTreeFlags(listCmd)
+
+	statusCmd.Flags().StringVar(&statusTemplate, "template", "", "Show every feature of this template repo with its applied, available or conflicting status")
+	addTreeFlags(statusCmd)
+
 	applyCmd.Flags().BoolVar(&dryRun, "dry-run", false, "Show what would be applied without applying")
-	applyCmd.Flags().StringVarP(&featuresFile, "file", "f", "", "Read features from file (one per line)")
+	applyCmd.Flags().BoolVar(&commit, "commit", false, "Commit each applied feature separately in the target's git repository")
+	applyCmd.Flags().BoolVar(&allowDirty, "allow-dirty", false, "Apply even if the target has uncommitted or locally modified files; with --commit only files the features patch are committed")
+	applyCmd.Flags().BoolVar(&stash, "stash", false, "Stash uncommitted changes in the target before applying and restore them afterwards")
+	addApplyFlags(applyCmd)
+
+	initCmd.Flags().BoolVar(&initNoGit, "no-git", false, "Do not initialise a git repository in the new directory")
+	initCmd.Flags().BoolVar(&initNoCommit, "no-commit", false, "Do not create an initial commit")
+	addApplyFlags(initCmd)
+
+	removeCmd.Flags().BoolVar(&sandbox, "sandbox", false, "Run patch commands with a scrubbed environment, confined to the target directory")
+	removeCmd.Flags().StringVar(&timeout, "timeout", "", "Timeout for each patch command, e.g. 30s or 2m (default 30s)")
+
+	diffCmd.Flags().BoolVar(&diffStat, "stat", false, "Show a summary of files added, modified and deleted")
+	diffCmd.Flags().StringVar(&diffColor, "color", "auto", "Colourise output: auto, always or never")
+	addFeatureFlags(diffCmd)
 
 	rootCmd.AddCommand(listCmd)
 	rootCmd.AddCommand(statusCmd)
 	rootCmd.AddCommand(applyCmd)
+	rootCmd.AddCommand(initCmd)
+	rootCmd.AddCommand(removeCmd)
+	rootCmd.AddCommand(diffCmd)
+	rootCmd.AddCommand(showCmd)
+	rootCmd.AddCommand(splitCmd)
+	sourceCmd.AddCommand(sourceAddCmd)
+	sourceCmd.AddCommand(sourceListCmd)
+	rootCmd.AddCommand(sourceCmd)
 	rootCmd.CompletionOptions.DisableDefaultCmd = true
+	rootCmd.SilenceErrors = true
 }
 
 func main() {
-	if err := rootCmd.Execute(); err != nil {
-		os.Exit(1)
+	executor.RunSandboxHelper()
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	if err := rootCmd.ExecuteContext(ctx); err != nil {
+		printError(os.Stderr, err)
+		os.Exit(exitCode(err))
 	}
 }
@@ -0,0 +1,9 @@
+#!/bin/bash
+set -e
+"$(dirname "$0")/../../features_file/scripts/setup_features.sh" "$1"
+mkdir -p "$1/project"
+cd "$1/project"
+git init --quiet
+echo "# Project" > README.md
+git add README.md
+git -c user.name=Test -c user.email=test@test.com commit --quiet -m "Initial commit"
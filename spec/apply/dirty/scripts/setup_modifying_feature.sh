@@ -0,0 +1,13 @@
+#!/bin/bash
+set -e
+mkdir -p "$1/templates/auth"
+cat > "$1/templates/auth/base.patch" << 'PATCH'
+diff --git a/file.txt b/file.txt
+--- a/file.txt
++++ b/file.txt
+@@ -1 +1,2 @@
+-initial
+\ No newline at end of file
++initial
++auth enabled
+PATCH
@@ -0,0 +1,35 @@
+#!/bin/bash
+set -e
+mkdir -p "$1/templates/auth" "$1/templates/billing" "$1/templates/search"
+cat > "$1/project/main.go" << 'GO'
+package main
+
+func routes(r *Router) {
+	// templater:routes
+	r.Handle("/", index)
+}
+GO
+git -C "$1/project" add -A
+git -C "$1/project" commit --quiet -m "Add router"
+
+cat > "$1/templates/auth/feature.yml" << 'YML'
+inject:
+  - file: main.go
+    anchor: "// templater:routes"
+    snippet: |
+      r.Handle("/login", login)
+YML
+cat > "$1/templates/billing/feature.yml" << 'YML'
+inject:
+  - file: main.go
+    anchor: "// templater:routes"
+    snippet: |
+      r.Handle("/invoices", invoices)
+YML
+cat > "$1/templates/search/feature.yml" << 'YML'
+inject:
+  - file: main.go
+    anchor: "// templater:handlers"
+    snippet: |
+      r.Handle("/search", search)
+YML
@@ -0,0 +1,35 @@
+#!/bin/bash
+set -e
+mkdir -p "$1/templates/auth/merge" "$1/templates/database/merge" "$1/templates/legacy/merge"
+cat > "$1/project/package.json" << 'JSON'
+{
+  "name": "app",
+  "dependencies": {
+    "react": "^18.0.0"
+  }
+}
+JSON
+cat > "$1/project/go.mod" << 'MOD'
+module example.com/app
+
+go 1.22
+MOD
+cat > "$1/project/pyproject.toml" << 'TOML'
+# Build settings
+[tool.lint]
+rules = [
+  "E",
+]
+
+[[bin]]
+name = "app"
+TOML
+git -C "$1/project" add -A
+git -C "$1/project" commit --quiet -m "Add manifests"
+
+printf '%s\n' '{"dependencies": {"passport": "^0.7.0"}}' > "$1/templates/auth/merge/package.json"
+printf '%s\n' 'require github.com/golang-jwt/jwt/v5 v5.2.0' > "$1/templates/auth/merge/go.mod"
+printf '%s\n' 'auth:' '  provider: oauth' > "$1/templates/auth/merge/config.yml"
+printf '%s\n' '[tool.auth]' 'enabled = true' > "$1/templates/auth/merge/pyproject.toml"
+printf '%s\n' '{"dependencies": {"pg": "^8.11.0"}}' > "$1/templates/database/merge/package.json"
+printf '%s\n' '{"dependencies": {"react": "^17.0.0"}}' > "$1/templates/legacy/merge/package.json"
@@ -0,0 +1,28 @@
+#!/bin/bash
+set -e
+mkdir -p "$1/templates/database" "$1/templates/cache"
+cat > "$1/templates/database/base.patch" << 'PATCH'
+diff --git a/docker-compose.yml b/docker-compose.yml
+new file mode 100644
+--- /dev/null
++++ b/docker-compose.yml
+@@ -0,0 +1,2 @@
++services:
++  db: {}
+diff --git a/config/db.yml b/config/db.yml
+--- a/config/db.yml
++++ b/config/db.yml
+@@ -1 +1 @@
+-adapter: none
++adapter: postgres
+PATCH
+cat > "$1/templates/cache/base.patch" << 'PATCH'
+diff --git a/file.txt b/file.txt
+--- a/file.txt
++++ b/file.txt
+@@ -1 +1,2 @@
+-initial
+\ No newline at end of file
++initial
++cache
+PATCH
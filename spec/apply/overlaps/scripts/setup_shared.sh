@@ -0,0 +1,22 @@
+#!/bin/bash
+set -e
+mkdir -p "$1/templates/logging" "$1/templates/metrics"
+cat > "$1/templates/logging/base.patch" << 'PATCH'
+diff --git a/file.txt b/file.txt
+--- a/file.txt
++++ b/file.txt
+@@ -1 +1,2 @@
+-initial
+\ No newline at end of file
++initial
++logging
+PATCH
+cat > "$1/templates/metrics/base.patch" << 'PATCH'
+diff --git a/file.txt b/file.txt
+--- a/file.txt
++++ b/file.txt
+@@ -1,2 +1,3 @@
+ initial
+ logging
++metrics
+PATCH
@@ -0,0 +1,15 @@
+#!/bin/bash
+set -e
+mkdir -p "$1/templates/branding/files/assets"
+cat > "$1/templates/branding/base.patch" << 'PATCH'
+diff --git a/file.txt b/file.txt
+--- a/file.txt
++++ b/file.txt
+@@ -1 +1,2 @@
+-initial
+\ No newline at end of file
++initial
++branding
+PATCH
+printf '%s\n' "MIT License" > "$1/templates/branding/files/LICENSE"
+printf '\x89PNG\x00\x01' > "$1/templates/branding/files/assets/logo.png"
@@ -0,0 +1,14 @@
+#!/bin/bash
+set -e
+"$(dirname "$0")/../../features_file/scripts/setup_features.sh" "$1"
+mkdir -p "$1/templates/database/templates" "$1/templates/presets"
+printf '%s\n' 'engine={{ index . "engine" }}' > "$1/templates/database/templates/db.env.tmpl"
+cat > "$1/templates/presets/web-service.yml" << 'YAML'
+description: Standard HTTP service
+features:
+  - auth
+  - name: database
+    vars:
+      engine: postgres
+YAML
+printf '%s\n' "auth" > "$1/templates/presets/minimal.txt"
@@ -0,0 +1,11 @@
+#!/bin/bash
+set -e
+"$(dirname "$0")/setup_series.sh" "$1"
+cat > "$1/templates/auth/patches/0003-broken.patch" << 'PATCH'
+diff --git a/file.txt b/file.txt
+--- a/file.txt
++++ b/file.txt
+@@ -1 +1 @@
+-something else
++changed
+PATCH
@@ -0,0 +1,25 @@
+#!/bin/bash
+set -e
+mkdir -p "$1/templates/auth/patches"
+cat > "$1/templates/auth/patches/0001-models.patch" << 'PATCH'
+diff --git a/models.txt b/models.txt
+new file mode 100644
+--- /dev/null
++++ b/models.txt
+@@ -0,0 +1 @@
++user model
+PATCH
+cat > "$1/templates/auth/patches/0002-routes.patch" << 'PATCH'
+diff --git a/models.txt b/models.txt
+--- a/models.txt
++++ b/models.txt
+@@ -1 +1,2 @@
+ user model
++session model
+diff --git a/routes.txt b/routes.txt
+new file mode 100644
+--- /dev/null
++++ b/routes.txt
+@@ -0,0 +1 @@
++/login
+PATCH
@@ -0,0 +1,23 @@
+#!/bin/bash
+set -e
+mkdir -p "$1/templates/web" "$1/infra/ci"
+cat > "$1/templates/web/feature.yml" << 'YML'
+requires:
+  - infra:ci
+YML
+cat > "$1/templates/web/base.patch" << 'PATCH'
+diff --git a/web.txt b/web.txt
+new file mode 100644
+--- /dev/null
++++ b/web.txt
+@@ -0,0 +1 @@
++web
+PATCH
+cat > "$1/infra/ci/base.patch" << 'PATCH'
+diff --git a/ci.txt b/ci.txt
+new file mode 100644
+--- /dev/null
++++ b/ci.txt
+@@ -0,0 +1 @@
++ci
+PATCH
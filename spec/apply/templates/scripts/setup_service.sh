@@ -0,0 +1,14 @@
+#!/bin/bash
+set -e
+mkdir -p "$1/templates/service/templates/cmd/{{.Name}}"
+cat > "$1/templates/service/templates/cmd/{{.Name}}/main.go.tmpl" << 'TMPL'
+package main
+
+import "fmt"
+
+func main() {
+	fmt.Println("{{.Name}} on port {{.Port}}")
+}
+TMPL
+printf '%s\n' "service Name=api Port=8080" > "$1/features.txt"
+printf '%s\n' "service Name=api" > "$1/missing.txt"
@@ -0,0 +1,27 @@
+#!/bin/bash
+set -e
+mkdir -p "$1/templates/ci/github/variants"
+cat > "$1/templates/ci/github/base.patch" << 'PATCH'
+diff --git a/ci.yml b/ci.yml
+new file mode 100644
+--- /dev/null
++++ b/ci.yml
+@@ -0,0 +1 @@
++steps: [test]
+PATCH
+cat > "$1/templates/ci/github/variants/cache.patch" << 'PATCH'
+diff --git a/cache.yml b/cache.yml
+new file mode 100644
+--- /dev/null
++++ b/cache.yml
+@@ -0,0 +1 @@
++cache: true
+PATCH
+cat > "$1/templates/ci/github/variants/matrix.patch" << 'PATCH'
+diff --git a/matrix.yml b/matrix.yml
+new file mode 100644
+--- /dev/null
++++ b/matrix.yml
+@@ -0,0 +1 @@
++matrix: [1.21, 1.22]
+PATCH
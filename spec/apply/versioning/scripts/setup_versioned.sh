@@ -0,0 +1,18 @@
+#!/bin/bash
+set -e
+mkdir -p "$1/templates/auth"
+cat > "$1/templates/templater.yml" << YML
+schema: ${2:-1}
+version: 1.4.2
+YML
+if [ -n "$3" ]; then
+  echo "min_templater_version: $3" >> "$1/templates/templater.yml"
+fi
+cat > "$1/templates/auth/base.patch" << 'PATCH'
+diff --git a/auth.txt b/auth.txt
+new file mode 100644
+--- /dev/null
++++ b/auth.txt
+@@ -0,0 +1 @@
++auth
+PATCH
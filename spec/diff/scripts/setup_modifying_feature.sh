@@ -0,0 +1,29 @@
+#!/bin/bash
+set -e
+mkdir -p "$1/templates/auth/oauth"
+cat > "$1/templates/auth/base.patch" << 'PATCH'
+diff --git a/auth.txt b/auth.txt
+new file mode 100644
+index 0000000..e69de29
+--- /dev/null
++++ b/auth.txt
+@@ -0,0 +1 @@
++auth feature
+PATCH
+cat > "$1/templates/auth/oauth/base.patch" << 'PATCH'
+diff --git a/auth.txt b/auth.txt
+index 1234567..abcdefg 100644
+--- a/auth.txt
++++ b/auth.txt
+@@ -1 +1,2 @@
+ auth feature
++oauth feature
+diff --git a/file.txt b/file.txt
+index 1234567..abcdefg 100644
+--- a/file.txt
++++ b/file.txt
+@@ -1 +1 @@
+-initial
+\ No newline at end of file
++changed
+PATCH
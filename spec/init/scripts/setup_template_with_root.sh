@@ -0,0 +1,11 @@
+#!/bin/bash
+set -e
+"$(dirname "$0")/../../apply/features_file/scripts/setup_features.sh" "$1"
+cat > "$1/templates/base.patch" << 'PATCH'
+diff --git a/README.md b/README.md
+new file mode 100644
+--- /dev/null
++++ b/README.md
+@@ -0,0 +1 @@
++# New service
+PATCH
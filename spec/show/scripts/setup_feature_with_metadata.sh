@@ -0,0 +1,11 @@
+#!/bin/bash
+set -e
+"$(dirname "$0")/../../diff/scripts/setup_modifying_feature.sh" "$1"
+mkdir -p "$1/templates/auth/oauth/hooks"
+cat > "$1/templates/auth/oauth/feature.yml" << 'YAML'
+description: Sign in with OAuth providers
+timeout: 2m
+YAML
+cat > "$1/templates/auth/oauth/hooks/post-apply" << 'HOOK'
+echo "oauth configured"
+HOOK